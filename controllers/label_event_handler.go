@@ -2,6 +2,8 @@ package controllers
 
 import (
 	"context"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
@@ -28,6 +30,20 @@ func MapToOwnerByLabel(namespace, label string) func(context context.Context, ob
 	}
 }
 
+// MapToOwnerByLabelInCluster is the variant of MapToOwnerByLabel for the cluster.NewMemberClusterSource:
+// the returned requests are annotated with the name of the member cluster the event originated in
+func MapToOwnerByLabelInCluster(namespace, label string) cluster.ClusterObjectMapFunc[cluster.ClusterRequest] {
+	mapToOwner := MapToOwnerByLabel(namespace, label)
+	return func(ctx context.Context, clusterName string, obj client.Object) []cluster.ClusterRequest {
+		requests := mapToOwner(ctx, obj)
+		clusterRequests := make([]cluster.ClusterRequest, len(requests))
+		for i, req := range requests {
+			clusterRequests[i] = cluster.ClusterRequest{Request: req, ClusterName: clusterName}
+		}
+		return clusterRequests
+	}
+}
+
 // MapToControllerByMatchingLabel returns an event handler will convert events on a resource to requests
 // if the resource matches a given label key and value
 // (if the label exists)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
@@ -59,6 +60,47 @@ func TestMapToOwnerByLabel(t *testing.T) {
 	})
 }
 
+func TestMapToOwnerByLabelInCluster(t *testing.T) {
+
+	t.Run("resource with expected label", func(t *testing.T) {
+		// given
+		obj := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "bar",
+				Labels: map[string]string{
+					"owner": "foo",
+				},
+			},
+		}
+		// when
+		result := MapToOwnerByLabelInCluster("ns", "owner")(context.TODO(), "member-1", obj)
+		// then
+		require.Len(t, result, 1)
+		assert.Equal(t, cluster.ClusterRequest{
+			Request: reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: "ns",
+					Name:      "foo",
+				},
+			},
+			ClusterName: "member-1",
+		}, result[0])
+	})
+
+	t.Run("resource without expected label", func(t *testing.T) {
+		// given
+		obj := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "bar",
+			},
+		}
+		// when
+		result := MapToOwnerByLabelInCluster("ns", "owner")(context.TODO(), "member-1", obj)
+		// then
+		require.Empty(t, result)
+	})
+}
+
 func TestMapToControllerByMatchingLabel(t *testing.T) {
 
 	t.Run("resource with expected label and value", func(t *testing.T) {
@@ -0,0 +1,166 @@
+package toolchaincluster
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	"github.com/codeready-toolchain/toolchain-common/pkg/configuration/memberoperatorconfig"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// DefaultFailureThreshold is the default number of consecutive failed health checks before a Ready cluster becomes not Ready
+	DefaultFailureThreshold = 3
+	// DefaultSuccessThreshold is the default number of consecutive successful health checks before a not Ready cluster becomes Ready
+	DefaultSuccessThreshold = 1
+	// DefaultMaxBackoffFactor is the default maximum backoff of the unreachable clusters as a multiple of the health check period
+	DefaultMaxBackoffFactor = 30
+	// DefaultJitterFactor is the default maximum jitter added to the backoff of the unreachable clusters (as a fraction of the backoff)
+	DefaultJitterFactor = 0.1
+)
+
+// HealthCheckPolicy dampens the flapping of the Ready condition and backs off the health checks of the unreachable clusters
+type HealthCheckPolicy struct {
+	// FailureThreshold is the number of consecutive failed health checks before a Ready cluster becomes not Ready
+	FailureThreshold int
+	// SuccessThreshold is the number of consecutive successful health checks before a not Ready cluster becomes Ready
+	SuccessThreshold int
+	// Timeout is the timeout of a single health check (no timeout if zero)
+	Timeout time.Duration
+	// MaxBackoff is the maximum period in between the health checks of an unreachable cluster.
+	// The period is doubled with each consecutive failure, starting at Reconciler.RequeAfter. No backoff if zero.
+	MaxBackoff time.Duration
+	// JitterFactor is the maximum jitter added to the backoff (as a fraction of the backoff)
+	JitterFactor float64
+}
+
+// NewHealthCheckPolicy returns the default HealthCheckPolicy using the health check period and timeout from the given configuration
+func NewHealthCheckPolicy(cfg memberoperatorconfig.ToolchainClusterConfig) *HealthCheckPolicy {
+	return &HealthCheckPolicy{
+		FailureThreshold: DefaultFailureThreshold,
+		SuccessThreshold: DefaultSuccessThreshold,
+		Timeout:          cfg.HealthCheckTimeout(),
+		MaxBackoff:       DefaultMaxBackoffFactor * cfg.HealthCheckPeriod(),
+		JitterFactor:     DefaultJitterFactor,
+	}
+}
+
+// healthStreak is the number of consecutive failed or successful health checks of a cluster
+type healthStreak struct {
+	failures  int
+	successes int
+	// nextCheck is when the next health check is scheduled and generation is the generation of the ToolchainCluster
+	// at the time of the last check. The streak is not advanced by the reconciles triggered before the next check
+	// (eg. by the status update) unless the generation changed.
+	nextCheck  time.Time
+	generation int64
+}
+
+// healthStreaks keeps the health streaks of all the clusters
+type healthStreaks struct {
+	sync.Mutex
+	streaks map[types.NamespacedName]healthStreak
+}
+
+func newHealthStreaks() *healthStreaks {
+	return &healthStreaks{streaks: map[types.NamespacedName]healthStreak{}}
+}
+
+// record records the result of the health check of the given cluster and returns the updated streak
+func (s *healthStreaks) record(name types.NamespacedName, healthy bool) healthStreak {
+	s.Lock()
+	defer s.Unlock()
+	streak := s.streaks[name]
+	if healthy {
+		streak = healthStreak{successes: streak.successes + 1}
+	} else {
+		streak = healthStreak{failures: streak.failures + 1}
+	}
+	s.streaks[name] = streak
+	return streak
+}
+
+// schedule stores when the next health check of the given cluster (having the given generation) is scheduled
+func (s *healthStreaks) schedule(name types.NamespacedName, generation int64, nextCheck time.Time) {
+	s.Lock()
+	defer s.Unlock()
+	streak := s.streaks[name]
+	streak.generation = generation
+	streak.nextCheck = nextCheck
+	s.streaks[name] = streak
+}
+
+// untilNextCheck returns the time remaining until the next scheduled health check of the given cluster,
+// or zero if the check is due (or if the generation of the cluster changed since the last check)
+func (s *healthStreaks) untilNextCheck(name types.NamespacedName, generation int64, now time.Time) time.Duration {
+	s.Lock()
+	defer s.Unlock()
+	streak, found := s.streaks[name]
+	if !found || streak.generation != generation || !now.Before(streak.nextCheck) {
+		return 0
+	}
+	return streak.nextCheck.Sub(now)
+}
+
+func (s *healthStreaks) forget(name types.NamespacedName) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.streaks, name)
+}
+
+// dampen returns the Ready condition that should be set based on the current health check result, the previous Ready condition
+// (if any) and the streak of the health check results
+func (p *HealthCheckPolicy) dampen(current toolchainv1alpha1.Condition, previousConditions []toolchainv1alpha1.Condition, streak healthStreak) toolchainv1alpha1.Condition {
+	previous, found := condition.FindConditionByType(previousConditions, toolchainv1alpha1.ConditionReady)
+	if current.Status == corev1.ConditionTrue {
+		if found && previous.Status != corev1.ConditionTrue && streak.successes < p.successThreshold() {
+			previous.Message = fmt.Sprintf("%d of %d consecutive health check(s) succeeded", streak.successes, p.successThreshold())
+			return previous
+		}
+		return current
+	}
+	if found && previous.Status == corev1.ConditionTrue && streak.failures < p.failureThreshold() {
+		previous.Message = fmt.Sprintf("%d of %d consecutive health check(s) failed: %s", streak.failures, p.failureThreshold(), current.Message)
+		return previous
+	}
+	current.Message = fmt.Sprintf("%s (failure streak: %d)", current.Message, streak.failures)
+	return current
+}
+
+// requeueAfter returns the period after which the cluster should be checked again. The period is extended exponentially (with jitter)
+// for the clusters that are not reachable.
+func (p *HealthCheckPolicy) requeueAfter(period time.Duration, ready toolchainv1alpha1.Condition, streak healthStreak) time.Duration {
+	if p.MaxBackoff <= 0 || streak.failures < 2 || ready.Reason != toolchainv1alpha1.ToolchainClusterClusterNotReachableReason {
+		return period
+	}
+	backoff := period
+	for i := 1; i < streak.failures && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	if p.JitterFactor > 0 {
+		backoff = wait.Jitter(backoff, p.JitterFactor)
+	}
+	return backoff
+}
+
+func (p *HealthCheckPolicy) failureThreshold() int {
+	if p.FailureThreshold < 1 {
+		return 1
+	}
+	return p.FailureThreshold
+}
+
+func (p *HealthCheckPolicy) successThreshold() int {
+	if p.SuccessThreshold < 1 {
+		return 1
+	}
+	return p.SuccessThreshold
+}
@@ -0,0 +1,247 @@
+package toolchaincluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	"github.com/codeready-toolchain/toolchain-common/pkg/configuration/memberoperatorconfig"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+	corev1 "k8s.io/api/core/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestNewHealthCheckPolicy(t *testing.T) {
+	// when
+	policy := NewHealthCheckPolicy(memberoperatorconfig.ToolchainClusterConfig{})
+
+	// then
+	assert.Equal(t, &HealthCheckPolicy{
+		FailureThreshold: DefaultFailureThreshold,
+		SuccessThreshold: DefaultSuccessThreshold,
+		Timeout:          3 * time.Second,
+		MaxBackoff:       300 * time.Second,
+		JitterFactor:     DefaultJitterFactor,
+	}, policy)
+}
+
+func TestHealthCheckPolicyRequeueAfter(t *testing.T) {
+	// given
+	policy := &HealthCheckPolicy{MaxBackoff: time.Minute}
+	offline := clusterOfflineCondition("connection refused")
+
+	t.Run("no backoff for the first failure", func(t *testing.T) {
+		assert.Equal(t, 10*time.Second, policy.requeueAfter(10*time.Second, offline, healthStreak{failures: 1}))
+	})
+
+	t.Run("exponential backoff", func(t *testing.T) {
+		assert.Equal(t, 20*time.Second, policy.requeueAfter(10*time.Second, offline, healthStreak{failures: 2}))
+		assert.Equal(t, 40*time.Second, policy.requeueAfter(10*time.Second, offline, healthStreak{failures: 3}))
+	})
+
+	t.Run("capped by max backoff", func(t *testing.T) {
+		assert.Equal(t, time.Minute, policy.requeueAfter(10*time.Second, offline, healthStreak{failures: 4}))
+		assert.Equal(t, time.Minute, policy.requeueAfter(10*time.Second, offline, healthStreak{failures: 1000}))
+	})
+
+	t.Run("no backoff for reachable clusters", func(t *testing.T) {
+		assert.Equal(t, 10*time.Second, policy.requeueAfter(10*time.Second, clusterNotReadyCondition(), healthStreak{failures: 5}))
+		assert.Equal(t, 10*time.Second, policy.requeueAfter(10*time.Second, clusterReadyCondition(), healthStreak{successes: 5}))
+	})
+
+	t.Run("no backoff when disabled", func(t *testing.T) {
+		assert.Equal(t, 10*time.Second, (&HealthCheckPolicy{}).requeueAfter(10*time.Second, offline, healthStreak{failures: 5}))
+	})
+
+	t.Run("with jitter", func(t *testing.T) {
+		// given
+		policy := &HealthCheckPolicy{MaxBackoff: time.Minute, JitterFactor: 0.5}
+
+		for i := 0; i < 100; i++ {
+			// when
+			requeueAfter := policy.requeueAfter(10*time.Second, offline, healthStreak{failures: 2})
+
+			// then
+			assert.GreaterOrEqual(t, requeueAfter, 20*time.Second)
+			assert.LessOrEqual(t, requeueAfter, 30*time.Second)
+		}
+	})
+}
+
+func TestHealthCheckPolicyDampensReadiness(t *testing.T) {
+	// given
+	defer gock.Off()
+	stable, sec := newToolchainCluster(t, "stable", "test-namespace", "https://cluster.com")
+	cl := test.NewFakeClient(t, stable, sec)
+	reset := setupCachedClusters(t, cl, stable)
+	defer reset()
+	controller, req := prepareReconcile(stable, cl, requeAfter)
+	controller.HealthCheckPolicy = &HealthCheckPolicy{
+		FailureThreshold: 3,
+		SuccessThreshold: 2,
+		MaxBackoff:       time.Minute,
+	}
+	var healthy bool
+	var healthErr error
+	controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (bool, error) {
+		return healthy, healthErr
+	}
+	now := time.Now()
+	controller.now = func() time.Time {
+		return now
+	}
+	reconcileAndAssert := func(t *testing.T, expectedRequeueAfter time.Duration, expectedReady toolchainv1alpha1.Condition) {
+		t.Helper()
+		// when
+		recResult, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, reconcile.Result{RequeueAfter: expectedRequeueAfter}, recResult)
+		assertClusterStatus(t, cl, "stable", expectedReady)
+		// the next reconcile happens when requeued
+		now = now.Add(expectedRequeueAfter)
+	}
+	withMessage := func(c toolchainv1alpha1.Condition, msg string) toolchainv1alpha1.Condition {
+		c.Message = msg
+		return c
+	}
+
+	// the first result is not dampened as there is no previous Ready condition
+	healthy = true
+	reconcileAndAssert(t, requeAfter, clusterReadyCondition())
+
+	// the failures are tolerated until the threshold is reached
+	healthy = false
+	healthErr = fmt.Errorf("connection refused")
+	reconcileAndAssert(t, requeAfter, withMessage(clusterReadyCondition(), "1 of 3 consecutive health check(s) failed: connection refused"))
+	reconcileAndAssert(t, 2*requeAfter, withMessage(clusterReadyCondition(), "2 of 3 consecutive health check(s) failed: connection refused"))
+	reconcileAndAssert(t, 4*requeAfter, clusterOfflineCondition("connection refused (failure streak: 3)"))
+	reconcileAndAssert(t, time.Minute, clusterOfflineCondition("connection refused (failure streak: 4)"))
+
+	// the cluster is reachable but still not healthy
+	healthErr = nil
+	reconcileAndAssert(t, requeAfter, withMessage(clusterNotReadyCondition(), healthzNotOk+" (failure streak: 5)"))
+
+	// the successes are required until the threshold is reached
+	healthy = true
+	reconcileAndAssert(t, requeAfter, withMessage(clusterNotReadyCondition(), "1 of 2 consecutive health check(s) succeeded"))
+	reconcileAndAssert(t, requeAfter, clusterReadyCondition())
+
+	// a single failure doesn't make the cluster not ready
+	healthy = false
+	reconcileAndAssert(t, requeAfter, withMessage(clusterReadyCondition(), "1 of 3 consecutive health check(s) failed: "+healthzNotOk))
+	healthy = true
+	reconcileAndAssert(t, requeAfter, clusterReadyCondition())
+}
+
+func TestHealthCheckPolicyIgnoresReconcilesTriggeredByStatusUpdate(t *testing.T) {
+	// given
+	defer gock.Off()
+	stable, sec := newToolchainCluster(t, "stable", "test-namespace", "https://cluster.com")
+	cl := test.NewFakeClient(t, stable, sec)
+	reset := setupCachedClusters(t, cl, stable)
+	defer reset()
+	controller, req := prepareReconcile(stable, cl, requeAfter)
+	controller.HealthCheckPolicy = &HealthCheckPolicy{
+		FailureThreshold: 3,
+		MaxBackoff:       time.Minute,
+	}
+	checks := 0
+	controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (bool, error) {
+		checks++
+		return checks == 1, nil
+	}
+	now := time.Now()
+	controller.now = func() time.Time {
+		return now
+	}
+	statusUpdates := 0
+	cl.MockStatusUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.SubResourceUpdateOption) error {
+		statusUpdates++
+		return cl.Client.Status().Update(ctx, obj, opts...)
+	}
+	_, err := controller.Reconcile(context.TODO(), req)
+	require.NoError(t, err)
+	now = now.Add(requeAfter)
+	_, err = controller.Reconcile(context.TODO(), req)
+	require.NoError(t, err)
+	require.Equal(t, 2, statusUpdates)
+
+	t.Run("status update doesn't advance the streak", func(t *testing.T) {
+		// when
+		// the status update done by the previous reconcile triggers another reconcile
+		now = now.Add(time.Second)
+		recResult, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, reconcile.Result{RequeueAfter: requeAfter - time.Second}, recResult)
+		assert.Equal(t, 2, checks)
+		assert.Equal(t, 2, statusUpdates)
+		c := clusterReadyCondition()
+		c.Message = "1 of 3 consecutive health check(s) failed: " + healthzNotOk
+		assertClusterStatus(t, cl, "stable", c)
+	})
+
+	t.Run("streak advances when the next check is due", func(t *testing.T) {
+		// given
+		now = now.Add(requeAfter)
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 3, checks)
+		c := clusterReadyCondition()
+		c.Message = "2 of 3 consecutive health check(s) failed: " + healthzNotOk
+		assertClusterStatus(t, cl, "stable", c)
+	})
+
+	t.Run("spec change triggers the check immediately", func(t *testing.T) {
+		// given
+		tc := &toolchainv1alpha1.ToolchainCluster{}
+		require.NoError(t, cl.Get(context.TODO(), req.NamespacedName, tc))
+		tc.Spec.SecretRef.Name = "rotated-secret"
+		require.NoError(t, cl.Update(context.TODO(), tc))
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 4, checks)
+	})
+	t.Run("failed status update is retried without waiting for the next check", func(t *testing.T) {
+		// given
+		now = now.Add(requeAfter)
+		cl.MockStatusUpdate = func(_ context.Context, _ runtimeclient.Object, _ ...runtimeclient.SubResourceUpdateOption) error {
+			return fmt.Errorf("some error")
+		}
+		_, err := controller.Reconcile(context.TODO(), req)
+		require.Error(t, err)
+		cl.MockStatusUpdate = nil
+
+		// when
+		recResult, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 6, checks)
+		assert.Positive(t, recResult.RequeueAfter)
+		tc := &toolchainv1alpha1.ToolchainCluster{}
+		require.NoError(t, cl.Get(context.TODO(), req.NamespacedName, tc))
+		ready, found := condition.FindConditionByType(tc.Status.Conditions, toolchainv1alpha1.ConditionReady)
+		require.True(t, found)
+		assert.Equal(t, corev1.ConditionFalse, ready.Status)
+	})
+}
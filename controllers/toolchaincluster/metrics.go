@@ -0,0 +1,75 @@
+package toolchaincluster
+
+import (
+	"sync"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	k8smetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	metricsPrefix = "toolchain_cluster_"
+
+	// healthzProbeName is the value of the "probe" label used for the "/healthz" check
+	healthzProbeName = "healthz"
+)
+
+var (
+	// HealthProbeDurationHistogramVec is the duration of the health probes, per cluster and probe
+	HealthProbeDurationHistogramVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    metricsPrefix + "health_probe_duration_seconds",
+		Help:    "Duration of the health probes executed against the ToolchainClusters",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 10),
+	}, []string{"cluster_name", "probe"})
+
+	// HealthProbeResultCounterVec is the number of the health probe results, per cluster, probe and reason of the result
+	HealthProbeResultCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + "health_probe_results_total",
+		Help: "Number of the results of the health probes executed against the ToolchainClusters",
+	}, []string{"cluster_name", "probe", "reason"})
+
+	// ReadyGaugeVec is 1 if the Ready condition of the cluster is true, 0 otherwise
+	ReadyGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: metricsPrefix + "ready",
+		Help: "Ready state of the ToolchainClusters (1 when ready, 0 otherwise)",
+	}, []string{"cluster_name"})
+
+	allMetrics = []prometheus.Collector{
+		HealthProbeDurationHistogramVec,
+		HealthProbeResultCounterVec,
+		ReadyGaugeVec,
+	}
+
+	registerMetrics sync.Once
+)
+
+// RegisterMetrics registers the metrics of the ToolchainCluster health checks in the controller-runtime metrics registry.
+// It's safe to call it multiple times - the metrics are registered only once.
+func RegisterMetrics() {
+	registerMetrics.Do(func() {
+		k8smetrics.Registry.MustRegister(allMetrics...)
+	})
+}
+
+func recordProbeResult(clusterName, probe string, seconds float64, result toolchainv1alpha1.Condition) {
+	HealthProbeDurationHistogramVec.WithLabelValues(clusterName, probe).Observe(seconds)
+	HealthProbeResultCounterVec.WithLabelValues(clusterName, probe, result.Reason).Inc()
+}
+
+func recordReady(clusterName string, ready toolchainv1alpha1.Condition) {
+	value := 0.0
+	if ready.Status == corev1.ConditionTrue {
+		value = 1
+	}
+	ReadyGaugeVec.WithLabelValues(clusterName).Set(value)
+}
+
+// forgetMetrics removes all the series of the given (deleted) cluster
+func forgetMetrics(clusterName string) {
+	labels := prometheus.Labels{"cluster_name": clusterName}
+	HealthProbeDurationHistogramVec.DeletePartialMatch(labels)
+	HealthProbeResultCounterVec.DeletePartialMatch(labels)
+	ReadyGaugeVec.DeletePartialMatch(labels)
+}
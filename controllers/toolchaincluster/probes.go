@@ -0,0 +1,190 @@
+package toolchaincluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// the types of the conditions set by the built-in health probes
+const (
+	ReadyzConditionType       toolchainv1alpha1.ConditionType = "Readyz"
+	LivezConditionType        toolchainv1alpha1.ConditionType = "Livez"
+	APIDiscoveryConditionType toolchainv1alpha1.ConditionType = "APIDiscovery"
+	AccessReviewConditionType toolchainv1alpha1.ConditionType = "AccessReview"
+	ClockSkewConditionType    toolchainv1alpha1.ConditionType = "ClockSkew"
+)
+
+// the reasons of the conditions set by the health probes
+const (
+	ProbeSucceededReason = "ProbeSucceeded"
+	ProbeFailedReason    = "ProbeFailed"
+)
+
+// ProbeResult is the result of a single health probe
+type ProbeResult struct {
+	// Healthy says if the probe succeeded
+	Healthy bool
+	// Message describes the result of the probe
+	Message string
+}
+
+// ProbeFunc checks a single aspect of the health of the cluster. The returned error means that the probe couldn't be
+// executed at all (eg. the cluster is not reachable).
+type ProbeFunc func(ctx context.Context, remoteClusterClientset *kubeclientset.Clientset) (ProbeResult, error)
+
+// HealthProbe is a health probe whose result is stored in a separate condition of the ToolchainCluster
+type HealthProbe struct {
+	// ConditionType is the type of the condition the result of the probe is stored in
+	ConditionType toolchainv1alpha1.ConditionType
+	// Critical says if the failure of the probe makes the whole cluster not Ready
+	Critical bool
+	// Probe is the function executing the probe
+	Probe ProbeFunc
+}
+
+// ReadyzProbe returns a probe requesting "/readyz?verbose". When it fails, the message contains the names and reasons of the failed checks.
+func ReadyzProbe(critical bool) HealthProbe {
+	return HealthProbe{
+		ConditionType: ReadyzConditionType,
+		Critical:      critical,
+		Probe:         verboseEndpointProbe("/readyz"),
+	}
+}
+
+// LivezProbe returns a probe requesting "/livez?verbose". When it fails, the message contains the names and reasons of the failed checks.
+func LivezProbe(critical bool) HealthProbe {
+	return HealthProbe{
+		ConditionType: LivezConditionType,
+		Critical:      critical,
+		Probe:         verboseEndpointProbe("/livez"),
+	}
+}
+
+func verboseEndpointProbe(path string) ProbeFunc {
+	return func(ctx context.Context, remoteClusterClientset *kubeclientset.Clientset) (ProbeResult, error) {
+		var statusCode int
+		body, err := remoteClusterClientset.DiscoveryClient.RESTClient().Get().AbsPath(path).Param("verbose", "true").Do(ctx).StatusCode(&statusCode).Raw()
+		if err != nil && statusCode == 0 {
+			return ProbeResult{}, err
+		}
+		if statusCode == http.StatusOK {
+			return ProbeResult{Healthy: true, Message: fmt.Sprintf("%s responded with ok", path)}, nil
+		}
+		var failed []string
+		for _, line := range strings.Split(string(body), "\n") {
+			if strings.HasPrefix(line, "[-]") {
+				failed = append(failed, strings.TrimPrefix(line, "[-]"))
+			}
+		}
+		if len(failed) == 0 {
+			return ProbeResult{Message: fmt.Sprintf("%s responded with status code %d", path, statusCode)}, nil
+		}
+		return ProbeResult{Message: fmt.Sprintf("%s failed checks: %s", path, strings.Join(failed, "; "))}, nil
+	}
+}
+
+// APIDiscoveryProbe returns a probe measuring the latency of the API discovery. The probe fails when the discovery takes longer than maxLatency.
+func APIDiscoveryProbe(critical bool, maxLatency time.Duration) HealthProbe {
+	return HealthProbe{
+		ConditionType: APIDiscoveryConditionType,
+		Critical:      critical,
+		Probe: func(_ context.Context, remoteClusterClientset *kubeclientset.Clientset) (ProbeResult, error) {
+			start := time.Now()
+			if _, err := remoteClusterClientset.Discovery().ServerGroups(); err != nil {
+				return ProbeResult{}, err
+			}
+			latency := time.Since(start).Round(time.Millisecond)
+			if latency > maxLatency {
+				return ProbeResult{Message: fmt.Sprintf("API discovery took %s which exceeds the limit of %s", latency, maxLatency)}, nil
+			}
+			return ProbeResult{Healthy: true, Message: fmt.Sprintf("API discovery took %s", latency)}, nil
+		},
+	}
+}
+
+// AccessReviewProbe returns a probe verifying (via SelfSubjectAccessReviews) that the operator is allowed to perform
+// all the given actions in the cluster. The probe fails when any of the actions is not allowed.
+func AccessReviewProbe(critical bool, requiredActions ...authorizationv1.ResourceAttributes) HealthProbe {
+	return HealthProbe{
+		ConditionType: AccessReviewConditionType,
+		Critical:      critical,
+		Probe: func(ctx context.Context, remoteClusterClientset *kubeclientset.Clientset) (ProbeResult, error) {
+			var denied []string
+			for _, action := range requiredActions {
+				review := &authorizationv1.SelfSubjectAccessReview{
+					Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+						ResourceAttributes: action.DeepCopy(),
+					},
+				}
+				result, err := remoteClusterClientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+				if err != nil {
+					return ProbeResult{}, err
+				}
+				if !result.Status.Allowed {
+					denied = append(denied, describeAction(action))
+				}
+			}
+			if len(denied) > 0 {
+				return ProbeResult{Message: fmt.Sprintf("the operator is not allowed to: %s", strings.Join(denied, ", "))}, nil
+			}
+			return ProbeResult{Healthy: true, Message: "the operator is allowed to perform all the required actions"}, nil
+		},
+	}
+}
+
+func describeAction(action authorizationv1.ResourceAttributes) string {
+	resource := action.Resource
+	if action.Group != "" {
+		resource = fmt.Sprintf("%s.%s", resource, action.Group)
+	}
+	if action.Namespace != "" {
+		return fmt.Sprintf("%s %s in %s", action.Verb, resource, action.Namespace)
+	}
+	return fmt.Sprintf("%s %s", action.Verb, resource)
+}
+
+// ClockSkewProbe returns a probe comparing the local time with the time in the Date header returned by the API server.
+// The probe fails when the difference is bigger than maxSkew.
+func ClockSkewProbe(critical bool, maxSkew time.Duration) HealthProbe {
+	return HealthProbe{
+		ConditionType: ClockSkewConditionType,
+		Critical:      critical,
+		Probe: func(ctx context.Context, remoteClusterClientset *kubeclientset.Clientset) (ProbeResult, error) {
+			restClient, ok := remoteClusterClientset.DiscoveryClient.RESTClient().(*rest.RESTClient)
+			if !ok || restClient.Client == nil {
+				return ProbeResult{}, fmt.Errorf("unable to get the HTTP client of the cluster")
+			}
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, restClient.Get().AbsPath("/version").URL().String(), nil)
+			if err != nil {
+				return ProbeResult{}, err
+			}
+			start := time.Now()
+			resp, err := restClient.Client.Do(req)
+			if err != nil {
+				return ProbeResult{}, err
+			}
+			defer resp.Body.Close()
+			end := time.Now()
+			serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+			if err != nil {
+				return ProbeResult{Message: fmt.Sprintf("unable to parse the Date header: %s", err)}, nil
+			}
+			// the Date header has a precision of one second, so let's compare it with the middle of the request
+			localTime := start.Add(end.Sub(start) / 2)
+			skew := serverTime.Sub(localTime).Round(time.Second)
+			if skew.Abs() > maxSkew {
+				return ProbeResult{Message: fmt.Sprintf("the clock skew %s exceeds the limit of %s", skew, maxSkew)}, nil
+			}
+			return ProbeResult{Healthy: true, Message: fmt.Sprintf("the clock skew is %s", skew)}, nil
+		},
+	}
+}
@@ -0,0 +1,217 @@
+package toolchaincluster
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestVerboseEndpointProbes(t *testing.T) {
+	// given
+	defer gock.Off()
+	gock.New("https://healthy.com").
+		Get("readyz").
+		MatchParam("verbose", "true").
+		Persist().
+		Reply(200).
+		BodyString("[+]ping ok\n[+]etcd ok\nreadyz check passed")
+	gock.New("https://failing.com").
+		Get("readyz").
+		MatchParam("verbose", "true").
+		Persist().
+		Reply(500).
+		BodyString("[+]ping ok\n[-]etcd failed: reason withheld\n[-]informer-sync failed: reason withheld\nreadyz check failed")
+	gock.New("https://failing.com").
+		Get("livez").
+		Persist().
+		Reply(503).
+		BodyString("service unavailable")
+
+	t.Run("readyz ok", func(t *testing.T) {
+		// when
+		result, err := ReadyzProbe(true).Probe(context.TODO(), newClientset(t, "https://healthy.com"))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, ProbeResult{Healthy: true, Message: "/readyz responded with ok"}, result)
+	})
+
+	t.Run("readyz with failed checks", func(t *testing.T) {
+		// when
+		result, err := ReadyzProbe(true).Probe(context.TODO(), newClientset(t, "https://failing.com"))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, ProbeResult{Message: "/readyz failed checks: etcd failed: reason withheld; informer-sync failed: reason withheld"}, result)
+	})
+
+	t.Run("livez failed without details", func(t *testing.T) {
+		// when
+		result, err := LivezProbe(false).Probe(context.TODO(), newClientset(t, "https://failing.com"))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, ProbeResult{Message: "/livez responded with status code 503"}, result)
+	})
+
+	t.Run("not reachable", func(t *testing.T) {
+		// when
+		_, err := LivezProbe(false).Probe(context.TODO(), newClientset(t, "https://unknown.com"))
+
+		// then
+		require.Error(t, err)
+	})
+}
+
+func TestAPIDiscoveryProbe(t *testing.T) {
+	// given
+	defer gock.Off()
+	gock.New("https://cluster.com").
+		Get("api").
+		Persist().
+		Reply(200).
+		BodyString(`{"kind":"APIVersions","versions":["v1"]}`)
+	gock.New("https://cluster.com").
+		Get("apis").
+		Persist().
+		Reply(200).
+		BodyString(`{"kind":"APIGroupList","groups":[]}`)
+	gock.New("https://slow.com").
+		Get("api").
+		Persist().
+		Reply(200).
+		Delay(50 * time.Millisecond).
+		BodyString(`{"kind":"APIVersions","versions":["v1"]}`)
+	gock.New("https://slow.com").
+		Get("apis").
+		Persist().
+		Reply(200).
+		BodyString(`{"kind":"APIGroupList","groups":[]}`)
+
+	t.Run("fast enough", func(t *testing.T) {
+		// when
+		result, err := APIDiscoveryProbe(true, time.Minute).Probe(context.TODO(), newClientset(t, "https://cluster.com"))
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, result.Healthy)
+		assert.Contains(t, result.Message, "API discovery took")
+	})
+
+	t.Run("too slow", func(t *testing.T) {
+		// when
+		result, err := APIDiscoveryProbe(true, time.Millisecond).Probe(context.TODO(), newClientset(t, "https://slow.com"))
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, result.Healthy)
+		assert.Contains(t, result.Message, "which exceeds the limit of 1ms")
+	})
+}
+
+func TestAccessReviewProbe(t *testing.T) {
+	// given
+	defer gock.Off()
+	gock.New("https://cluster.com").
+		Post("apis/authorization.k8s.io/v1/selfsubjectaccessreviews").
+		BodyString(`"verb":"delete"`).
+		Persist().
+		Reply(201).
+		SetHeader("Content-Type", "application/json").
+		BodyString(`{"kind":"SelfSubjectAccessReview","apiVersion":"authorization.k8s.io/v1","status":{"allowed":false}}`)
+	gock.New("https://cluster.com").
+		Post("apis/authorization.k8s.io/v1/selfsubjectaccessreviews").
+		Persist().
+		Reply(201).
+		SetHeader("Content-Type", "application/json").
+		BodyString(`{"kind":"SelfSubjectAccessReview","apiVersion":"authorization.k8s.io/v1","status":{"allowed":true}}`)
+	list := authorizationv1.ResourceAttributes{Verb: "list", Resource: "namespaces"}
+	deleteSpaces := authorizationv1.ResourceAttributes{Verb: "delete", Group: "toolchain.dev.openshift.com", Resource: "spaces", Namespace: "toolchain-host-operator"}
+
+	t.Run("all allowed", func(t *testing.T) {
+		// when
+		result, err := AccessReviewProbe(true, list).Probe(context.TODO(), newClientset(t, "https://cluster.com"))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, ProbeResult{Healthy: true, Message: "the operator is allowed to perform all the required actions"}, result)
+	})
+
+	t.Run("some denied", func(t *testing.T) {
+		// when
+		result, err := AccessReviewProbe(true, list, deleteSpaces).Probe(context.TODO(), newClientset(t, "https://cluster.com"))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, ProbeResult{Message: "the operator is not allowed to: delete spaces.toolchain.dev.openshift.com in toolchain-host-operator"}, result)
+	})
+}
+
+func TestClockSkewProbe(t *testing.T) {
+	// given
+	defer gock.Off()
+	gock.New("https://synced.com").
+		Get("version").
+		Persist().
+		Reply(200).
+		SetHeader("Date", time.Now().UTC().Format(http.TimeFormat)).
+		BodyString("{}")
+	gock.New("https://skewed.com").
+		Get("version").
+		Persist().
+		Reply(200).
+		SetHeader("Date", time.Now().Add(-10*time.Minute).UTC().Format(http.TimeFormat)).
+		BodyString("{}")
+	gock.New("https://nodate.com").
+		Get("version").
+		Persist().
+		Reply(200).
+		BodyString("{}")
+
+	t.Run("in sync", func(t *testing.T) {
+		// when
+		result, err := ClockSkewProbe(false, 5*time.Second).Probe(context.TODO(), newClientset(t, "https://synced.com"))
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, result.Healthy, result.Message)
+	})
+
+	t.Run("skewed", func(t *testing.T) {
+		// when
+		result, err := ClockSkewProbe(false, 5*time.Second).Probe(context.TODO(), newClientset(t, "https://skewed.com"))
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, result.Healthy)
+		assert.Contains(t, result.Message, "exceeds the limit of 5s")
+	})
+
+	t.Run("no date header", func(t *testing.T) {
+		// when
+		result, err := ClockSkewProbe(false, 5*time.Second).Probe(context.TODO(), newClientset(t, "https://nodate.com"))
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, result.Healthy)
+		assert.Contains(t, result.Message, "unable to parse the Date header")
+	})
+}
+
+func newClientset(t *testing.T, host string) *kubeclientset.Clientset {
+	clientset, err := kubeclientset.NewForConfig(&rest.Config{
+		Host: host,
+		// use JSON so the requests can be matched and the responses mocked by gock
+		ContentConfig: rest.ContentConfig{ContentType: "application/json"},
+	})
+	require.NoError(t, err)
+	return clientset
+}
@@ -3,6 +3,7 @@ package toolchaincluster
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
@@ -20,14 +21,29 @@ import (
 
 // Reconciler reconciles a ToolchainCluster object
 type Reconciler struct {
-	Client      client.Client
-	Scheme      *runtime.Scheme
-	RequeAfter  time.Duration
-	checkHealth func(context.Context, *kubeclientset.Clientset) (bool, error)
+	Client     client.Client
+	Scheme     *runtime.Scheme
+	RequeAfter time.Duration
+	// ClusterCache is the cache the cached clusters are looked up in. If nil, then the default cache is used.
+	ClusterCache *cluster.ClusterCache
+	// HealthProbes are the additional probes executed on top of the "/healthz" check. Each probe sets its own condition,
+	// and the failure of a critical probe makes the Ready condition false.
+	HealthProbes []HealthProbe
+	// HealthCheckPolicy dampens the flapping of the Ready condition and backs off the unreachable clusters.
+	// If nil, then the Ready condition reflects the result of the last health check and the clusters are checked every RequeAfter.
+	// The streaks of the health checks are kept by the Reconciler set up by SetupWithManager.
+	HealthCheckPolicy *HealthCheckPolicy
+	checkHealth       func(context.Context, *kubeclientset.Clientset) (bool, error)
+	now               func() time.Time
+	streaks           *healthStreaks
 }
 
-// SetupWithManager sets up the controller with the Manager.
+// SetupWithManager sets up the controller with the Manager and registers the metrics of the health checks.
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.streaks == nil {
+		r.streaks = newHealthStreaks()
+	}
+	RegisterMetrics()
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&toolchainv1alpha1.ToolchainCluster{}).
 		Complete(r)
@@ -48,39 +64,90 @@ func (r *Reconciler) Reconcile(ctx context.Context, request ctrl.Request) (ctrl.
 	if err != nil {
 		if kerrors.IsNotFound(err) {
 			// Stop monitoring the toolchain cluster as it is deleted
+			if r.streaks != nil {
+				r.streaks.forget(request.NamespacedName)
+			}
+			forgetMetrics(request.Name)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		return reconcile.Result{}, err
 	}
 
-	cachedCluster, ok := cluster.GetCachedToolchainCluster(toolchainCluster.Name)
+	cachedCluster, ok := r.clusterCache().GetCachedToolchainCluster(toolchainCluster.Name)
 	if !ok {
 		err := fmt.Errorf("cluster %s not found in cache", toolchainCluster.Name)
-		if err := r.updateStatus(ctx, toolchainCluster, nil, clusterOfflineCondition(err.Error())); err != nil {
+		offline := clusterOfflineCondition(err.Error())
+		recordReady(toolchainCluster.Name, offline)
+		if err := r.updateStatus(ctx, toolchainCluster, nil, offline); err != nil {
 			reqLogger.Error(err, "unable to update cluster status of ToolchainCluster")
 		}
 		return reconcile.Result{}, err
 	}
 
+	if r.HealthCheckPolicy != nil {
+		// the reconcile was triggered before the next scheduled health check, so the streak would advance too fast
+		if remaining := r.streaks.untilNextCheck(request.NamespacedName, toolchainCluster.Generation, r.currentTime()); remaining > 0 {
+			return reconcile.Result{RequeueAfter: remaining}, nil
+		}
+	}
+
+	// the RestConfig is not limited by the cluster.RateLimits of the cached client, so the health checks have their own lane
 	clientSet, err := kubeclientset.NewForConfig(cachedCluster.RestConfig)
 	if err != nil {
 		reqLogger.Error(err, "cannot create ClientSet for the ToolchainCluster")
-		if err := r.updateStatus(ctx, toolchainCluster, cachedCluster, clusterOfflineCondition(err.Error())); err != nil {
+		offline := clusterOfflineCondition(err.Error())
+		recordReady(toolchainCluster.Name, offline)
+		if err := r.updateStatus(ctx, toolchainCluster, cachedCluster, offline); err != nil {
 			reqLogger.Error(err, "unable to update cluster status of ToolchainCluster")
 		}
 		return reconcile.Result{}, err
 	}
 
 	// execute healthcheck
-	healthCheckResult := r.getClusterHealthCondition(ctx, clientSet)
+	healthCheckCtx := ctx
+	if r.HealthCheckPolicy != nil && r.HealthCheckPolicy.Timeout > 0 {
+		var cancel context.CancelFunc
+		healthCheckCtx, cancel = context.WithTimeout(ctx, r.HealthCheckPolicy.Timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	healthCheckResult := r.getClusterHealthCondition(healthCheckCtx, clientSet)
+	recordProbeResult(toolchainCluster.Name, healthzProbeName, time.Since(start).Seconds(), healthCheckResult)
+	probeResults := r.runHealthProbes(healthCheckCtx, toolchainCluster.Name, clientSet, &healthCheckResult)
+
+	requeueAfter := r.RequeAfter
+	if r.HealthCheckPolicy != nil {
+		streak := r.streaks.record(request.NamespacedName, healthCheckResult.Status == corev1.ConditionTrue)
+		requeueAfter = r.HealthCheckPolicy.requeueAfter(r.RequeAfter, healthCheckResult, streak)
+		healthCheckResult = r.HealthCheckPolicy.dampen(healthCheckResult, toolchainCluster.Status.Conditions, streak)
+	}
+	recordReady(toolchainCluster.Name, healthCheckResult)
 
 	// update the status of the individual cluster.
-	if err := r.updateStatus(ctx, toolchainCluster, cachedCluster, healthCheckResult); err != nil {
+	if err := r.updateStatus(ctx, toolchainCluster, cachedCluster, append([]toolchainv1alpha1.Condition{healthCheckResult}, probeResults...)...); err != nil {
 		reqLogger.Error(err, "unable to update cluster status of ToolchainCluster")
 		return reconcile.Result{}, err
 	}
-	return reconcile.Result{RequeueAfter: r.RequeAfter}, nil
+	if r.HealthCheckPolicy != nil {
+		// scheduled only when the result is stored, so the failed status update is retried right away
+		r.streaks.schedule(request.NamespacedName, toolchainCluster.Generation, r.currentTime().Add(requeueAfter))
+	}
+	return reconcile.Result{RequeueAfter: requeueAfter}, nil
+}
+
+func (r *Reconciler) currentTime() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+	return time.Now()
+}
+
+func (r *Reconciler) clusterCache() *cluster.ClusterCache {
+	if r.ClusterCache != nil {
+		return r.ClusterCache
+	}
+	return cluster.DefaultClusterCache()
 }
 
 func (r *Reconciler) updateStatus(ctx context.Context, toolchainCluster *toolchainv1alpha1.ToolchainCluster, cachedToolchainCluster *cluster.CachedToolchainCluster, currentConditions ...toolchainv1alpha1.Condition) error {
@@ -118,6 +185,43 @@ func (r *Reconciler) getClusterHealth(ctx context.Context, remoteClusterClientse
 	return getClusterHealthStatus(ctx, remoteClusterClientset)
 }
 
+// runHealthProbes executes all the additional health probes and returns their conditions. If any of the critical probes failed,
+// then the given Ready condition is changed to not ready.
+func (r *Reconciler) runHealthProbes(ctx context.Context, clusterName string, remoteClusterClientset *kubeclientset.Clientset, ready *toolchainv1alpha1.Condition) []toolchainv1alpha1.Condition {
+	conditions := make([]toolchainv1alpha1.Condition, 0, len(r.HealthProbes))
+	var failedCritical []string
+	for _, probe := range r.HealthProbes {
+		start := time.Now()
+		result, err := probe.Probe(ctx, remoteClusterClientset)
+		duration := time.Since(start).Seconds()
+		probeCondition := toolchainv1alpha1.Condition{
+			Type:    probe.ConditionType,
+			Status:  corev1.ConditionTrue,
+			Reason:  ProbeSucceededReason,
+			Message: result.Message,
+		}
+		switch {
+		case err != nil:
+			probeCondition.Status = corev1.ConditionFalse
+			probeCondition.Reason = toolchainv1alpha1.ToolchainClusterClusterNotReachableReason
+			probeCondition.Message = err.Error()
+		case !result.Healthy:
+			probeCondition.Status = corev1.ConditionFalse
+			probeCondition.Reason = ProbeFailedReason
+		}
+		recordProbeResult(clusterName, string(probe.ConditionType), duration, probeCondition)
+		if probe.Critical && probeCondition.Status != corev1.ConditionTrue {
+			failedCritical = append(failedCritical, string(probe.ConditionType))
+		}
+		conditions = append(conditions, probeCondition)
+	}
+	if len(failedCritical) > 0 && ready.Status == corev1.ConditionTrue {
+		*ready = clusterNotReadyCondition()
+		ready.Message = fmt.Sprintf("the critical health probe(s) failed: %s", strings.Join(failedCritical, ", "))
+	}
+	return conditions
+}
+
 func clusterOfflineCondition(errMsg string) toolchainv1alpha1.Condition {
 	return toolchainv1alpha1.Condition{
 		Type:    toolchainv1alpha1.ConditionReady,
@@ -9,6 +9,8 @@ import (
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	metricstest "github.com/codeready-toolchain/toolchain-common/pkg/test/metrics"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/h2non/gock.v1"
 	corev1 "k8s.io/api/core/v1"
@@ -204,6 +206,169 @@ func TestGetClusterHealth(t *testing.T) {
 	})
 }
 
+func TestHealthProbes(t *testing.T) {
+	// given
+	defer gock.Off()
+	healthy := func(context.Context, *kubeclientset.Clientset) (ProbeResult, error) {
+		return ProbeResult{Healthy: true, Message: "all good"}, nil
+	}
+	failing := func(context.Context, *kubeclientset.Clientset) (ProbeResult, error) {
+		return ProbeResult{Message: "something is wrong"}, nil
+	}
+	unreachable := func(context.Context, *kubeclientset.Clientset) (ProbeResult, error) {
+		return ProbeResult{}, fmt.Errorf("connection refused")
+	}
+	probeCondition := func(conditionType toolchainv1alpha1.ConditionType, status corev1.ConditionStatus, reason, message string) toolchainv1alpha1.Condition {
+		return toolchainv1alpha1.Condition{Type: conditionType, Status: status, Reason: reason, Message: message}
+	}
+
+	reconcileWithProbes := func(t *testing.T, probes ...HealthProbe) *test.FakeClient {
+		stable, sec := newToolchainCluster(t, "stable", "test-namespace", "https://cluster.com")
+		cl := test.NewFakeClient(t, stable, sec)
+		reset := setupCachedClusters(t, cl, stable)
+		t.Cleanup(reset)
+		controller, req := prepareReconcile(stable, cl, requeAfter)
+		controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (bool, error) {
+			return true, nil
+		}
+		controller.HealthProbes = probes
+
+		recResult, err := controller.Reconcile(context.TODO(), req)
+
+		require.NoError(t, err)
+		require.Equal(t, reconcile.Result{RequeueAfter: requeAfter}, recResult)
+		return cl
+	}
+
+	t.Run("all probes succeeded", func(t *testing.T) {
+		// when
+		cl := reconcileWithProbes(t,
+			HealthProbe{ConditionType: ReadyzConditionType, Critical: true, Probe: healthy},
+			HealthProbe{ConditionType: ClockSkewConditionType, Probe: healthy})
+
+		// then
+		assertClusterStatus(t, cl, "stable",
+			clusterReadyCondition(),
+			probeCondition(ReadyzConditionType, corev1.ConditionTrue, ProbeSucceededReason, "all good"),
+			probeCondition(ClockSkewConditionType, corev1.ConditionTrue, ProbeSucceededReason, "all good"))
+	})
+
+	t.Run("non-critical probe failed", func(t *testing.T) {
+		// when
+		cl := reconcileWithProbes(t,
+			HealthProbe{ConditionType: ReadyzConditionType, Critical: true, Probe: healthy},
+			HealthProbe{ConditionType: ClockSkewConditionType, Probe: failing})
+
+		// then
+		assertClusterStatus(t, cl, "stable",
+			clusterReadyCondition(),
+			probeCondition(ReadyzConditionType, corev1.ConditionTrue, ProbeSucceededReason, "all good"),
+			probeCondition(ClockSkewConditionType, corev1.ConditionFalse, ProbeFailedReason, "something is wrong"))
+	})
+
+	t.Run("critical probes failed", func(t *testing.T) {
+		// when
+		cl := reconcileWithProbes(t,
+			HealthProbe{ConditionType: ReadyzConditionType, Critical: true, Probe: failing},
+			HealthProbe{ConditionType: AccessReviewConditionType, Critical: true, Probe: unreachable},
+			HealthProbe{ConditionType: ClockSkewConditionType, Probe: healthy})
+
+		// then
+		notReady := clusterNotReadyCondition()
+		notReady.Message = "the critical health probe(s) failed: Readyz, AccessReview"
+		assertClusterStatus(t, cl, "stable",
+			notReady,
+			probeCondition(ReadyzConditionType, corev1.ConditionFalse, ProbeFailedReason, "something is wrong"),
+			probeCondition(AccessReviewConditionType, corev1.ConditionFalse, toolchainv1alpha1.ToolchainClusterClusterNotReachableReason, "connection refused"),
+			probeCondition(ClockSkewConditionType, corev1.ConditionTrue, ProbeSucceededReason, "all good"))
+	})
+}
+
+func TestHealthCheckMetrics(t *testing.T) {
+	// given
+	defer gock.Off()
+	healthzValue := true
+	failing := func(context.Context, *kubeclientset.Clientset) (ProbeResult, error) {
+		return ProbeResult{Message: "something is wrong"}, nil
+	}
+	metered, sec := newToolchainCluster(t, "metered", "test-namespace", "https://cluster.com")
+	cl := test.NewFakeClient(t, metered, sec)
+	reset := setupCachedClusters(t, cl, metered)
+	defer reset()
+	controller, req := prepareReconcile(metered, cl, requeAfter)
+	controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (bool, error) {
+		return healthzValue, nil
+	}
+	controller.HealthProbes = []HealthProbe{{ConditionType: ClockSkewConditionType, Probe: failing}}
+	healthzDuration := HealthProbeDurationHistogramVec.WithLabelValues("metered", "healthz").(prometheus.Histogram)
+	clockSkewDuration := HealthProbeDurationHistogramVec.WithLabelValues("metered", "ClockSkew").(prometheus.Histogram)
+
+	// when
+	_, err := controller.Reconcile(context.TODO(), req)
+
+	// then
+	require.NoError(t, err)
+	metricstest.AssertHistogramSampleCountEquals(t, 1, healthzDuration)
+	metricstest.AssertHistogramSampleCountEquals(t, 1, clockSkewDuration)
+	metricstest.AssertMetricsCounterEquals(t, 1, HealthProbeResultCounterVec.WithLabelValues("metered", "healthz", toolchainv1alpha1.ToolchainClusterClusterReadyReason))
+	metricstest.AssertMetricsCounterEquals(t, 1, HealthProbeResultCounterVec.WithLabelValues("metered", "ClockSkew", ProbeFailedReason))
+	metricstest.AssertMetricsGaugeEquals(t, 1, ReadyGaugeVec.WithLabelValues("metered"))
+
+	t.Run("cluster not ready", func(t *testing.T) {
+		// given
+		healthzValue = false
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		metricstest.AssertHistogramSampleCountEquals(t, 2, healthzDuration)
+		metricstest.AssertMetricsCounterEquals(t, 1, HealthProbeResultCounterVec.WithLabelValues("metered", "healthz", toolchainv1alpha1.ToolchainClusterClusterReadyReason))
+		metricstest.AssertMetricsCounterEquals(t, 1, HealthProbeResultCounterVec.WithLabelValues("metered", "healthz", toolchainv1alpha1.ToolchainClusterClusterNotReadyReason))
+		metricstest.AssertMetricsCounterEquals(t, 2, HealthProbeResultCounterVec.WithLabelValues("metered", "ClockSkew", ProbeFailedReason))
+		metricstest.AssertMetricsGaugeEquals(t, 0, ReadyGaugeVec.WithLabelValues("metered"))
+	})
+
+	t.Run("series are removed when the cluster is deleted", func(t *testing.T) {
+		// given
+		require.NoError(t, cl.Delete(context.TODO(), metered))
+
+		// when
+		_, err := controller.Reconcile(context.TODO(), req)
+
+		// then
+		require.NoError(t, err)
+		metricstest.AssertMetricsCounterEquals(t, 0, HealthProbeResultCounterVec.WithLabelValues("metered", "healthz", toolchainv1alpha1.ToolchainClusterClusterReadyReason))
+		metricstest.AssertMetricsGaugeEquals(t, 0, ReadyGaugeVec.WithLabelValues("metered"))
+	})
+}
+
+func TestReconcileWithClusterCache(t *testing.T) {
+	// given
+	defer gock.Off()
+	stable, sec := newToolchainCluster(t, "stable", "test-namespace", "https://cluster.com")
+	cl := test.NewFakeClient(t, stable, sec)
+	cache := cluster.NewClusterCache()
+	service := cluster.NewToolchainClusterServiceWithCache(cache, cl, logf.Log, "test-namespace", 0, nil)
+	require.NoError(t, service.AddOrUpdateToolchainCluster(stable))
+	controller, req := prepareReconcile(stable, cl, requeAfter)
+	controller.ClusterCache = cache
+	controller.checkHealth = func(context.Context, *kubeclientset.Clientset) (bool, error) {
+		return true, nil
+	}
+
+	// when
+	recResult, err := controller.Reconcile(context.TODO(), req)
+
+	// then
+	require.NoError(t, err)
+	require.Equal(t, reconcile.Result{RequeueAfter: requeAfter}, recResult)
+	assertClusterStatus(t, cl, "stable", clusterReadyCondition())
+	_, found := cluster.GetCachedToolchainCluster("stable")
+	require.False(t, found)
+}
+
 func setupCachedClusters(t *testing.T, cl *test.FakeClient, clusters ...*toolchainv1alpha1.ToolchainCluster) func() {
 	service := cluster.NewToolchainClusterServiceWithClient(cl, logf.Log, test.MemberOperatorNs, 0, func(config *rest.Config, options runtimeclient.Options) (runtimeclient.Client, error) {
 		// make sure that insecure is false to make Gock mocking working properly
@@ -234,6 +399,7 @@ func prepareReconcile(toolchainCluster *toolchainv1alpha1.ToolchainCluster, cl *
 		Client:     cl,
 		Scheme:     scheme.Scheme,
 		RequeAfter: requeAfter,
+		streaks:    newHealthStreaks(),
 	}
 	req := reconcile.Request{
 		NamespacedName: test.NamespacedName(toolchainCluster.Namespace, toolchainCluster.Name),
@@ -0,0 +1,38 @@
+package toolchainclustercache
+
+import (
+	"context"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// secretToToolchainClusterMapper maps the Secrets to the ToolchainClusters referencing them,
+// so the cached clients are rebuilt when the credentials are rotated
+type secretToToolchainClusterMapper struct {
+	client client.Client
+}
+
+// mapToToolchainClusters returns requests for all the ToolchainClusters (in the same namespace) that reference the given Secret
+func (m secretToToolchainClusterMapper) mapToToolchainClusters(ctx context.Context, obj client.Object) []reconcile.Request {
+	toolchainClusters := &toolchainv1alpha1.ToolchainClusterList{}
+	if err := m.client.List(ctx, toolchainClusters, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list ToolchainClusters referencing the Secret", "secret", obj.GetName())
+		return []reconcile.Request{}
+	}
+	requests := []reconcile.Request{}
+	for _, toolchainCluster := range toolchainClusters.Items {
+		if toolchainCluster.Spec.SecretRef.Name == obj.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Namespace: toolchainCluster.Namespace,
+					Name:      toolchainCluster.Name,
+				},
+			})
+		}
+	}
+	return requests
+}
@@ -0,0 +1,56 @@
+package toolchainclustercache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/h2non/gock.v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestMapSecretToToolchainClusters(t *testing.T) {
+	// given
+	defer gock.Off()
+	status := toolchainv1alpha1.ToolchainClusterStatus{}
+	east, eastSecret := test.NewToolchainCluster(t, "east", test.HostOperatorNs, "member-ns", "east-secret", status, false)
+	west, _ := test.NewToolchainCluster(t, "west", test.HostOperatorNs, "member-ns", "east-secret", status, false)
+	north, northSecret := test.NewToolchainCluster(t, "north", test.HostOperatorNs, "member-ns", "north-secret", status, false)
+	other, _ := test.NewToolchainCluster(t, "other", "other-ns", "member-ns", "east-secret", status, false)
+
+	t.Run("maps to all ToolchainClusters referencing the secret", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, east, west, north, other)
+		mapper := secretToToolchainClusterMapper{client: cl}
+
+		// when
+		requests := mapper.mapToToolchainClusters(context.TODO(), eastSecret)
+
+		// then
+		assert.ElementsMatch(t, []reconcile.Request{
+			{NamespacedName: test.NamespacedName(test.HostOperatorNs, "east")},
+			{NamespacedName: test.NamespacedName(test.HostOperatorNs, "west")},
+		}, requests)
+		assert.Equal(t, []reconcile.Request{{NamespacedName: test.NamespacedName(test.HostOperatorNs, "north")}},
+			mapper.mapToToolchainClusters(context.TODO(), northSecret))
+	})
+
+	t.Run("no request when list fails", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, east)
+		cl.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+			return fmt.Errorf("mock error")
+		}
+		mapper := secretToToolchainClusterMapper{client: cl}
+
+		// when
+		requests := mapper.mapToToolchainClusters(context.TODO(), eastSecret)
+
+		// then
+		assert.Empty(t, requests)
+	})
+}
@@ -6,20 +6,27 @@ import (
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-// NewReconciler returns a new Reconciler
+// NewReconciler returns a new Reconciler that stores the clusters in the default cluster cache
 func NewReconciler(mgr manager.Manager, namespace string, timeout time.Duration) *Reconciler {
+	return NewReconcilerWithCache(mgr, cluster.DefaultClusterCache(), namespace, timeout)
+}
+
+// NewReconcilerWithCache returns a new Reconciler that stores the clusters in the given cluster cache
+func NewReconcilerWithCache(mgr manager.Manager, cache *cluster.ClusterCache, namespace string, timeout time.Duration) *Reconciler {
 	cacheLog := log.Log.WithName("toolchaincluster_cache")
-	clusterCacheService := cluster.NewToolchainClusterService(mgr.GetClient(), cacheLog, namespace, timeout)
+	clusterCacheService := cluster.NewToolchainClusterServiceWithCache(cache, mgr.GetClient(), cacheLog, namespace, timeout, nil)
 	return &Reconciler{
 		client:              mgr.GetClient(),
 		scheme:              mgr.GetScheme(),
@@ -29,10 +36,15 @@ func NewReconciler(mgr manager.Manager, namespace string, timeout time.Duration)
 }
 
 // SetupWithManager sets up the controller with the Manager.
+// Apart from the ToolchainClusters, it also watches the Secrets they reference, so the cached clients are rebuilt
+// as soon as the credentials are rotated. It also registers the metrics of the cluster cache.
 func (r *Reconciler) SetupWithManager(mgr ctrl.Manager) error {
+	cluster.RegisterMetrics()
+	mapper := secretToToolchainClusterMapper{client: r.client}
 	return ctrl.NewControllerManagedBy(mgr).
 		Named("ToolchainClusterCache").
 		For(&toolchainv1alpha1.ToolchainCluster{}, builder.WithPredicates(namespacePredicate{namespace: r.namespace})).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(mapper.mapToToolchainClusters), builder.WithPredicates(namespacePredicate{namespace: r.namespace})).
 		Complete(r)
 }
 
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ApplyConfiguration is an apply configuration of an object, eg. one created using the builders from
+// k8s.io/client-go/applyconfigurations. It needs to have the apiVersion, kind and name set.
+type ApplyConfiguration interface {
+	GetName() *string
+}
+
+// ApplyConfiguration applies the given apply configuration using an SSA patch. Contrary to ApplyObject, only the fields
+// set in the apply configuration are sent to the cluster, so the field manager doesn't take the ownership of any other field.
+// The options are applied the same way as in ApplyObject.
+func (c *SSAApplyClient) ApplyConfiguration(ctx context.Context, applyConfiguration ApplyConfiguration, options ...SSAApplyObjectOption) error {
+	content, err := json.Marshal(applyConfiguration)
+	if err != nil {
+		return fmt.Errorf("unable to marshal the apply configuration of '%s': %w", applyConfigurationName(applyConfiguration), err)
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(content); err != nil {
+		return fmt.Errorf("unable to convert the apply configuration of '%s': %w", applyConfigurationName(applyConfiguration), err)
+	}
+	return c.ApplyObject(ctx, obj, options...)
+}
+
+// ApplyMinimalObject applies the given typed object using an SSA patch, the same way as ApplyObject does, but it sends only
+// the fields that are set in the object. The fields that are nil and the objects that are empty (eg. an empty `status`
+// or `resources` of a container) are omitted, so the field manager doesn't take the ownership of them.
+// Note that this also omits the empty maps explicitly set by the caller. Use ApplyConfiguration to have full control over the fields.
+//
+// The given object is updated with the state returned from the cluster.
+func (c *SSAApplyClient) ApplyMinimalObject(ctx context.Context, obj client.Object, options ...SSAApplyObjectOption) error {
+	minimal, err := toMinimalUnstructured(obj, c.Client.Scheme())
+	if err != nil {
+		return composeError(obj, fmt.Errorf("failed to convert the object to the minimal form: %w", err))
+	}
+	if err := c.ApplyObject(ctx, minimal, options...); err != nil {
+		return err
+	}
+	if _, ok := obj.(*unstructured.Unstructured); !ok {
+		// reset the object first, so the fields that are not in the response are not kept
+		reflect.ValueOf(obj).Elem().Set(reflect.Zero(reflect.TypeOf(obj).Elem()))
+	}
+	if err := c.Client.Scheme().Convert(minimal, obj, nil); err != nil {
+		return composeError(minimal, fmt.Errorf("failed to convert the applied object: %w", err))
+	}
+	return nil
+}
+
+// toMinimalUnstructured converts the object to unstructured, omitting the status, the nil values and the empty objects
+func toMinimalUnstructured(obj client.Object, scheme *runtime.Scheme) (*unstructured.Unstructured, error) {
+	obj = obj.DeepCopyObject().(client.Object)
+	if err := EnsureGVK(obj, scheme); err != nil {
+		return nil, err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	delete(content, "status")
+	pruneEmpty(content)
+	return &unstructured.Unstructured{Object: content}, nil
+}
+
+// pruneEmpty removes the nil values and the empty maps from the given map (recursively, including the maps in the lists).
+// It returns true if the map is empty afterward.
+func pruneEmpty(content map[string]interface{}) bool {
+	for key, value := range content {
+		switch v := value.(type) {
+		case nil:
+			delete(content, key)
+		case map[string]interface{}:
+			if pruneEmpty(v) {
+				delete(content, key)
+			}
+		case []interface{}:
+			for _, item := range v {
+				if m, ok := item.(map[string]interface{}); ok {
+					pruneEmpty(m)
+				}
+			}
+		}
+	}
+	return len(content) == 0
+}
+
+func applyConfigurationName(applyConfiguration ApplyConfiguration) string {
+	if n := applyConfiguration.GetName(); n != nil {
+		return *n
+	}
+	return ""
+}
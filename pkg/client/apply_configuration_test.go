@@ -0,0 +1,98 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	corev1ac "k8s.io/client-go/applyconfigurations/core/v1"
+	"k8s.io/utils/ptr"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestApplyConfiguration(t *testing.T) {
+	// given
+	cl, acl := NewTestSsaApplyClient(t)
+	sent := recordPatches(cl)
+
+	// when
+	err := acl.ApplyConfiguration(context.TODO(), corev1ac.ConfigMap("cm", "ns").WithData(map[string]string{"key": "value"}),
+		client.EnsureLabels(map[string]string{"app": "test"}))
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, *sent, 1)
+	assert.Equal(t, map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": "cm", "namespace": "ns", "labels": map[string]interface{}{"app": "test"}},
+		"data":       map[string]interface{}{"key": "value"},
+	}, (*sent)[0])
+	inCluster := &corev1.ConfigMap{}
+	require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKey{Name: "cm", Namespace: "ns"}, inCluster))
+	assert.Equal(t, "value", inCluster.Data["key"])
+	assert.Equal(t, "test", inCluster.Labels["app"])
+}
+
+func TestApplyMinimalObject(t *testing.T) {
+	// given
+	cl, acl := NewTestSsaApplyClient(t)
+	sent := recordPatches(cl)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "deployment", Namespace: "ns"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To[int32](0),
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "test"}},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "app", Image: "quay.io/app:latest"}},
+				},
+			},
+		},
+	}
+
+	// when
+	err := acl.ApplyMinimalObject(context.TODO(), deployment)
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, *sent, 1)
+	assert.Equal(t, map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]interface{}{"name": "deployment", "namespace": "ns"},
+		"spec": map[string]interface{}{
+			"replicas": int64(0),
+			"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "test"}},
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "test"}},
+				"spec": map[string]interface{}{
+					"containers": []interface{}{map[string]interface{}{"name": "app", "image": "quay.io/app:latest"}},
+				},
+			},
+		},
+	}, (*sent)[0])
+	assert.NotEmpty(t, deployment.ResourceVersion, "the object should be updated from the cluster")
+	assert.Equal(t, int32(0), *deployment.Spec.Replicas)
+	inCluster := &appsv1.Deployment{}
+	require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(deployment), inCluster))
+	assert.Equal(t, "quay.io/app:latest", inCluster.Spec.Template.Spec.Containers[0].Image)
+}
+
+// recordPatches records the content of the objects sent in the patches
+func recordPatches(cl *test.FakeClient) *[]map[string]interface{} {
+	sent := &[]map[string]interface{}{}
+	cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+		*sent = append(*sent, obj.(*unstructured.Unstructured).DeepCopy().Object)
+		return test.Patch(ctx, cl, obj, patch, opts...)
+	}
+	return sent
+}
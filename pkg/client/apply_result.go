@@ -0,0 +1,92 @@
+package client
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ApplyOperation is what happened to an object when it was applied
+type ApplyOperation string
+
+const (
+	// ApplyCreated the object didn't exist and was created
+	ApplyCreated ApplyOperation = "Created"
+	// ApplyUpdated the object existed and was changed
+	ApplyUpdated ApplyOperation = "Updated"
+	// ApplyUnchanged the object existed and was not changed
+	ApplyUnchanged ApplyOperation = "Unchanged"
+	// ApplySkipped the object was not applied at all (eg. because of the SkipIf option)
+	ApplySkipped ApplyOperation = "Skipped"
+)
+
+// ApplyResult describes the outcome of applying a single object
+type ApplyResult struct {
+	Object    ObjectRef
+	Operation ApplyOperation
+	// OldResourceVersion and OldGeneration are empty when the object was created
+	OldResourceVersion string
+	OldGeneration      int64
+	NewResourceVersion string
+	NewGeneration      int64
+	// Changes are the fields changed by the apply (the metadata set by the server, eg. the resourceVersion, is ignored)
+	Changes ObjectDiff
+}
+
+// Changed returns true if the object was created or updated
+func (r ApplyResult) Changed() bool {
+	return r.Operation == ApplyCreated || r.Operation == ApplyUpdated
+}
+
+// GenerationChanged returns true if the object was created or if its generation was incremented by the server,
+// ie. when its spec changed
+func (r ApplyResult) GenerationChanged() bool {
+	return r.Operation == ApplyCreated || r.OldGeneration != r.NewGeneration
+}
+
+// newApplyResult compares the object before the apply (nil if it didn't exist) with the applied object returned by the server
+func newApplyResult(live, applied client.Object, scheme *runtime.Scheme) (ApplyResult, error) {
+	applied = applied.DeepCopyObject().(client.Object)
+	// the GVK is dropped when the response is decoded into a typed object
+	if err := EnsureGVK(applied, scheme); err != nil {
+		return ApplyResult{}, err
+	}
+	result := ApplyResult{
+		Object:             NewObjectRef(applied),
+		Operation:          ApplyCreated,
+		NewResourceVersion: applied.GetResourceVersion(),
+		NewGeneration:      applied.GetGeneration(),
+	}
+	if live != nil {
+		result.OldResourceVersion = live.GetResourceVersion()
+		result.OldGeneration = live.GetGeneration()
+		result.Operation = ApplyUpdated
+		if result.OldResourceVersion == result.NewResourceVersion {
+			result.Operation = ApplyUnchanged
+		}
+	}
+	changes, err := DiffObjects(live, applied)
+	if err != nil {
+		return ApplyResult{}, err
+	}
+	result.Changes = changes
+	return result, nil
+}
+
+// newUnchangedResult returns the result of the object that was not applied (or not changed) at all.
+// The live object is nil if the object doesn't exist.
+func newUnchangedResult(obj, live client.Object, operation ApplyOperation, scheme *runtime.Scheme) (ApplyResult, error) {
+	if live == nil {
+		obj = obj.DeepCopyObject().(client.Object)
+		if err := EnsureGVK(obj, scheme); err != nil {
+			return ApplyResult{}, err
+		}
+		return ApplyResult{
+			Object:    NewObjectRef(obj),
+			Operation: operation,
+			Changes:   ObjectDiff{GroupVersionKind: obj.GetObjectKind().GroupVersionKind(), Namespace: obj.GetNamespace(), Name: obj.GetName()},
+		}, nil
+	}
+	result, err := newApplyResult(live, live, scheme)
+	result.Operation = operation
+	return result, err
+}
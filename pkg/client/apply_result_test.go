@@ -0,0 +1,137 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestApplyClientApplyObjectWithResult(t *testing.T) {
+	// given
+	addToScheme(t)
+	cl, _ := newClient(t)
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "ns"},
+		Data:       map[string]string{"key": "value"},
+	}
+	ref := client.ObjectRef{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns", Name: "cm"}
+
+	// when
+	created, err := cl.ApplyObjectWithResult(context.TODO(), cm.DeepCopy())
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, ref, created.Object)
+	assert.Equal(t, client.ApplyCreated, created.Operation)
+	assert.True(t, created.Changed())
+	assert.True(t, created.GenerationChanged())
+	assert.Empty(t, created.OldResourceVersion)
+	assert.NotEmpty(t, created.NewResourceVersion)
+	assert.True(t, created.Changes.Created)
+
+	t.Run("unchanged", func(t *testing.T) {
+		// when
+		result, err := cl.ApplyObjectWithResult(context.TODO(), cm.DeepCopy())
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, ref, result.Object)
+		assert.Equal(t, client.ApplyUnchanged, result.Operation)
+		assert.False(t, result.Changed())
+		assert.Equal(t, created.NewResourceVersion, result.OldResourceVersion)
+		assert.Equal(t, created.NewResourceVersion, result.NewResourceVersion)
+		assert.False(t, result.Changes.HasChanges())
+	})
+
+	t.Run("updated", func(t *testing.T) {
+		// given
+		modified := cm.DeepCopy()
+		modified.Data["key"] = "modified"
+
+		// when
+		results, err := cl.ApplyWithResults(context.TODO(), []runtimeclient.Object{modified}, nil)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, client.ApplyUpdated, results[0].Operation)
+		assert.Equal(t, created.NewResourceVersion, results[0].OldResourceVersion)
+		assert.NotEqual(t, results[0].OldResourceVersion, results[0].NewResourceVersion)
+		assert.Equal(t, []client.FieldChange{{Path: "data.key", Old: "value", New: "modified"}}, results[0].Changes.Changed)
+	})
+}
+
+func TestSsaApplyObjectWithResult(t *testing.T) {
+	// given
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "ns"},
+		Data:       map[string]string{"key": "value"},
+	}
+	ref := client.ObjectRef{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns", Name: "cm"}
+	_, acl := NewTestSsaApplyClient(t)
+
+	// when
+	created, err := acl.ApplyObjectWithResult(context.TODO(), cm.DeepCopy())
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, ref, created.Object)
+	assert.Equal(t, client.ApplyCreated, created.Operation)
+	assert.NotEmpty(t, created.NewResourceVersion)
+	assert.Contains(t, created.Changes.Added, client.FieldChange{Path: "data.key", New: "value"})
+
+	t.Run("updated", func(t *testing.T) {
+		// given
+		modified := cm.DeepCopy()
+		modified.Data["key"] = "modified"
+
+		// when
+		results, err := acl.ApplyWithResults(context.TODO(), []runtimeclient.Object{modified})
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, client.ApplyUpdated, results[0].Operation)
+		assert.Equal(t, created.NewResourceVersion, results[0].OldResourceVersion)
+		assert.NotEqual(t, results[0].OldResourceVersion, results[0].NewResourceVersion)
+		assert.Equal(t, []client.FieldChange{{Path: "data.key", Old: "value", New: "modified"}}, results[0].Changes.Changed)
+	})
+
+	t.Run("skipped", func(t *testing.T) {
+		// given
+		skip := client.SkipIf(func(runtimeclient.Object) bool { return true })
+
+		t.Run("existing object", func(t *testing.T) {
+			// when
+			result, err := acl.ApplyObjectWithResult(context.TODO(), cm.DeepCopy(), skip)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ApplySkipped, result.Operation)
+			assert.False(t, result.Changed())
+			assert.NotEmpty(t, result.OldResourceVersion)
+			assert.Equal(t, result.OldResourceVersion, result.NewResourceVersion)
+		})
+
+		t.Run("missing object", func(t *testing.T) {
+			// given
+			missing := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "missing", Namespace: "ns"}}
+
+			// when
+			result, err := acl.ApplyObjectWithResult(context.TODO(), missing, skip)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, client.ObjectRef{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns", Name: "missing"}, result.Object)
+			assert.Equal(t, client.ApplySkipped, result.Operation)
+			assert.Empty(t, result.NewResourceVersion)
+			assert.False(t, result.Changes.HasChanges())
+		})
+	})
+}
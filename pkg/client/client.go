@@ -16,6 +16,7 @@ import (
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
@@ -27,6 +28,10 @@ var log = logf.Log.WithName("apply_client")
 // ApplyClient the client to use when creating or updating objects
 type ApplyClient struct {
 	client.Client
+
+	// EventRecorder is used to emit the events about the created, updated and failed objects on their owner
+	// (set using the SetOwner option) and about the pruned objects on the parent of the Pruner. No events are emitted if not set.
+	EventRecorder record.EventRecorder
 }
 
 // NewApplyClient returns a new ApplyClient
@@ -85,7 +90,10 @@ func (c ApplyClient) ApplyRuntimeObject(ctx context.Context, obj runtime.Object,
 	if !ok {
 		return false, fmt.Errorf("unable to cast of the object to client.Object: %+v", obj)
 	}
-	return c.applyObject(ctx, clientObj, options...)
+	events := newEventBatch(c.EventRecorder)
+	defer events.flush()
+	result, err := c.applyObject(ctx, clientObj, events, options...)
+	return result.GenerationChanged(), err
 }
 
 // ApplyObject creates the object if is missing and if the owner object is provided, then it's set as a controller reference.
@@ -94,41 +102,55 @@ func (c ApplyClient) ApplyRuntimeObject(ctx context.Context, obj runtime.Object,
 // The return boolean says if the object was either created or updated (`true`). If nothing changed (ie, the generation was not
 // incremented by the server), then it returns `false`.
 func (c ApplyClient) ApplyObject(ctx context.Context, obj client.Object, options ...ApplyObjectOption) (bool, error) {
+	result, err := c.ApplyObjectWithResult(ctx, obj, options...)
+	return result.GenerationChanged(), err
+}
+
+// ApplyObjectWithResult applies the object the same way as ApplyObject does, but it returns the result describing
+// what happened to the object.
+func (c ApplyClient) ApplyObjectWithResult(ctx context.Context, obj client.Object, options ...ApplyObjectOption) (ApplyResult, error) {
+	events := newEventBatch(c.EventRecorder)
+	defer events.flush()
+	return c.applyObjectWithResult(ctx, obj, events, options...)
+}
+
+func (c ApplyClient) applyObjectWithResult(ctx context.Context, obj client.Object, events *eventBatch, options ...ApplyObjectOption) (ApplyResult, error) {
 	gvk := obj.GetObjectKind().GroupVersionKind()
-	createdOrUpdated, err := c.applyObject(ctx, obj, options...)
+	result, err := c.applyObject(ctx, obj, events, options...)
 	if err != nil {
-		return createdOrUpdated, errors.Wrapf(err, "unable to create resource of kind: %s, version: %s", gvk.Kind, gvk.Version)
+		return result, errors.Wrapf(err, "unable to create resource of kind: %s, version: %s", gvk.Kind, gvk.Version)
 	}
-	return createdOrUpdated, nil
+	return result, nil
 }
 
-func (c ApplyClient) applyObject(ctx context.Context, obj client.Object, options ...ApplyObjectOption) (bool, error) {
-	// gets the meta accessor to the new resource
+func (c ApplyClient) applyObject(ctx context.Context, obj client.Object, events *eventBatch, options ...ApplyObjectOption) (ApplyResult, error) {
 	config := newApplyObjectConfiguration(options...)
+	result, err := c.createOrUpdate(ctx, obj, config)
+	events.addApplied(config.owner, obj, c.Scheme(), result, err)
+	return result, err
+}
 
+func (c ApplyClient) createOrUpdate(ctx context.Context, obj client.Object, config applyObjectConfiguration) (ApplyResult, error) {
 	// creates a deepcopy of the new resource to be used to check if it already exists
 	existing := obj.DeepCopyObject().(client.Object)
 
 	var newConfiguration string
 	if config.saveConfiguration {
-		// set current object as annotation
-		annotations := obj.GetAnnotations()
-		newConfiguration = GetNewConfiguration(obj)
-		if annotations == nil {
-			annotations = map[string]string{}
-		}
-		annotations[LastAppliedConfigurationAnnotationKey] = newConfiguration
-		obj.SetAnnotations(annotations)
+		newConfiguration = saveConfiguration(obj)
 	}
 	// gets current object (if exists)
 	namespacedName := types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}
 	if err := c.Get(ctx, namespacedName, existing); err != nil {
 		if apierrors.IsNotFound(err) {
 			obj.SetResourceVersion("") // reset resource version when creating to avoid error: resourceVersion should not be set on objects to be created
-			return true, c.createObj(ctx, obj, config.owner)
+			if err := c.createObj(ctx, obj, config.owner); err != nil {
+				return ApplyResult{Operation: ApplyCreated}, err
+			}
+			return newApplyResult(nil, obj, c.Scheme())
 		}
-		return false, errors.Wrapf(err, "unable to get the resource '%v'", existing)
+		return ApplyResult{}, errors.Wrapf(err, "unable to get the resource '%v'", existing)
 	}
+	live := existing.DeepCopyObject().(client.Object)
 
 	// as it already exists, check using the UpdateStrategy if it should be updated
 	if !config.forceUpdate {
@@ -136,7 +158,7 @@ func (c ApplyClient) applyObject(ctx context.Context, obj client.Object, options
 		if existingAnnotations != nil {
 			lastApplied, lastAppliedFound := existingAnnotations[LastAppliedConfigurationAnnotationKey]
 			if lastAppliedFound && newConfiguration != "" && newConfiguration == lastApplied {
-				return false, nil
+				return newUnchangedResult(obj, live, ApplyUnchanged, c.Scheme())
 			}
 		}
 	}
@@ -144,7 +166,6 @@ func (c ApplyClient) applyObject(ctx context.Context, obj client.Object, options
 	// retrieve the current 'resourceVersion' to set it in the resource passed to the `client.Update()`
 	// otherwise we would get an error with the following message:
 	// `nstemplatetiers.toolchain.dev.openshift.com "base1ns" is invalid: metadata.resourceVersion: Invalid value: 0x0: must be specified for an update`
-	originalGeneration := existing.GetGeneration()
 	obj.SetResourceVersion(existing.GetResourceVersion())
 
 	// Special handling of ServiceAccounts is required because if a ServiceAccount is reapplied when it already exists, it causes Kubernetes controllers to
@@ -161,14 +182,90 @@ func (c ApplyClient) applyObject(ctx context.Context, obj client.Object, options
 	// the update will fail with the following error:
 	// `Service "<name>" is invalid: spec.clusterIP: Invalid value: "": field is immutable`
 	if err := RetainClusterIP(obj, existing); err != nil {
-		return false, err
+		return ApplyResult{}, err
 	}
 	if err := c.Update(ctx, obj); err != nil {
-		return false, errors.Wrapf(err, "unable to update the resource '%v'", obj)
+		return ApplyResult{}, errors.Wrapf(err, "unable to update the resource '%v'", obj)
 	}
 
 	// check if it was changed or not
-	return originalGeneration != obj.GetGeneration(), nil
+	return newApplyResult(live, obj, c.Scheme())
+}
+
+// saveConfiguration sets the current object as the last applied configuration annotation and returns the configuration
+func saveConfiguration(obj client.Object) string {
+	annotations := obj.GetAnnotations()
+	newConfiguration := GetNewConfiguration(obj)
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[LastAppliedConfigurationAnnotationKey] = newConfiguration
+	obj.SetAnnotations(annotations)
+	return newConfiguration
+}
+
+// DiffObject returns the changes that ApplyObject called with the same options would make, without writing anything
+// to the cluster. The given object is not modified. Only the fields of the live object that are present in the given object
+// or in its last applied configuration are compared, so the fields set by the server (eg. the defaulted ones) are ignored.
+func (c ApplyClient) DiffObject(ctx context.Context, obj client.Object, options ...ApplyObjectOption) (ObjectDiff, error) {
+	config := newApplyObjectConfiguration(options...)
+	desired := obj.DeepCopyObject().(client.Object)
+	if err := EnsureGVK(desired, c.Scheme()); err != nil {
+		return ObjectDiff{}, err
+	}
+	existing := desired.DeepCopyObject().(client.Object)
+
+	var newConfiguration string
+	if config.saveConfiguration {
+		newConfiguration = saveConfiguration(desired)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(desired), existing); err != nil {
+		if apierrors.IsNotFound(err) {
+			if config.owner != nil {
+				if err := controllerutil.SetControllerReference(config.owner, desired, c.Scheme()); err != nil {
+					return ObjectDiff{}, errors.Wrap(err, "unable to set controller references")
+				}
+			}
+			return DiffObjects(nil, desired)
+		}
+		return ObjectDiff{}, errors.Wrapf(err, "unable to get the resource '%v'", existing)
+	}
+
+	if !config.forceUpdate && newConfiguration != "" && existing.GetAnnotations()[LastAppliedConfigurationAnnotationKey] == newConfiguration {
+		return DiffObjects(existing, existing)
+	}
+	// the same special handling of ServiceAccounts and Services as in ApplyObject
+	if strings.EqualFold(desired.GetObjectKind().GroupVersionKind().Kind, "ServiceAccount") {
+		merged := existing.DeepCopyObject().(client.Object)
+		MergeAnnotations(merged, desired.GetAnnotations())
+		MergeLabels(merged, desired.GetLabels())
+		desired = merged
+	}
+	if err := RetainClusterIP(desired, existing); err != nil {
+		return ObjectDiff{}, err
+	}
+	var lastApplied map[string]interface{}
+	if lastConfiguration, found := existing.GetAnnotations()[LastAppliedConfigurationAnnotationKey]; found {
+		// an invalid annotation just means that there are no previously applied fields to compare
+		_ = json.Unmarshal([]byte(lastConfiguration), &lastApplied)
+	}
+	return diffAppliedFields(existing, desired, lastApplied)
+}
+
+// Diff returns the changes that Apply called with the same objects and labels would make, without writing anything to the cluster.
+// The given objects are not modified.
+func (c ApplyClient) Diff(ctx context.Context, toolchainObjects []client.Object, newLabels map[string]string) ([]ObjectDiff, error) {
+	diffs := make([]ObjectDiff, 0, len(toolchainObjects))
+	for _, toolchainObject := range toolchainObjects {
+		toolchainObject = toolchainObject.DeepCopyObject().(client.Object)
+		MergeLabels(toolchainObject, newLabels)
+		diff, err := c.DiffObject(ctx, toolchainObject, ForceUpdate(true))
+		if err != nil {
+			return diffs, errors.Wrapf(err, "unable to diff resource of kind: %s, version: %s", toolchainObject.GetObjectKind().GroupVersionKind().Kind, toolchainObject.GetObjectKind().GroupVersionKind().Version)
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, nil
 }
 
 // RetainClusterIP sets the `spec.clusterIP` value from the given 'existing' object
@@ -245,17 +342,37 @@ func (c ApplyClient) createObj(ctx context.Context, newResource client.Object, o
 // returns `true, nil` if at least one of the objects was created or modified,
 // `false, nil` if nothing changed, and `false, err` if an error occurred
 func (c ApplyClient) Apply(ctx context.Context, toolchainObjects []client.Object, newLabels map[string]string) (bool, error) {
+	results, err := c.ApplyWithResults(ctx, toolchainObjects, newLabels)
+	if err != nil {
+		return false, err
+	}
 	createdOrUpdated := false
+	for _, result := range results {
+		createdOrUpdated = createdOrUpdated || result.GenerationChanged()
+	}
+	return createdOrUpdated, nil
+}
+
+// ApplyWithResults applies the objects the same way as Apply does, but it returns the results of the objects
+// applied so far (in the same order as the objects were given).
+func (c ApplyClient) ApplyWithResults(ctx context.Context, toolchainObjects []client.Object, newLabels map[string]string) ([]ApplyResult, error) {
+	events := newEventBatch(c.EventRecorder)
+	defer events.flush()
+	return c.applyWithResults(ctx, toolchainObjects, newLabels, events)
+}
+
+func (c ApplyClient) applyWithResults(ctx context.Context, toolchainObjects []client.Object, newLabels map[string]string, events *eventBatch) ([]ApplyResult, error) {
+	results := make([]ApplyResult, 0, len(toolchainObjects))
 	for _, toolchainObject := range toolchainObjects {
 		MergeLabels(toolchainObject, newLabels)
 
-		result, err := c.ApplyObject(ctx, toolchainObject, ForceUpdate(true))
+		result, err := c.applyObjectWithResult(ctx, toolchainObject, events, ForceUpdate(true))
 		if err != nil {
-			return false, errors.Wrapf(err, "unable to create resource of kind: %s, version: %s", toolchainObject.GetObjectKind().GroupVersionKind().Kind, toolchainObject.GetObjectKind().GroupVersionKind().Version)
+			return results, errors.Wrapf(err, "unable to create resource of kind: %s, version: %s", toolchainObject.GetObjectKind().GroupVersionKind().Kind, toolchainObject.GetObjectKind().GroupVersionKind().Version)
 		}
-		createdOrUpdated = createdOrUpdated || result
+		results = append(results, result)
 	}
-	return createdOrUpdated, nil
+	return results, nil
 }
 
 // MergeLabels gets current exiting labels and merges them with the new ones provided
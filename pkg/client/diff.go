@@ -0,0 +1,230 @@
+package client
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FieldChange is a change of a single field of an object. The Old value is nil for the added fields
+// and the New value is nil for the removed fields.
+type FieldChange struct {
+	// Path is the path of the field, eg. "spec.replicas" or "metadata.labels[toolchain.dev.openshift.com/owner]".
+	// The lists are compared as a whole, so the path of a changed list element is the path of the list.
+	Path string
+	Old  interface{}
+	New  interface{}
+}
+
+// String returns a human-readable description of the change
+func (c FieldChange) String() string {
+	switch {
+	case c.Old == nil:
+		return fmt.Sprintf("+%s: %v", c.Path, c.New)
+	case c.New == nil:
+		return fmt.Sprintf("-%s: %v", c.Path, c.Old)
+	default:
+		return fmt.Sprintf("~%s: %v -> %v", c.Path, c.Old, c.New)
+	}
+}
+
+// ObjectDiff describes the changes that applying an object would make in the cluster.
+// The metadata maintained by the server (eg. resourceVersion, managedFields), the status
+// and the last applied configuration annotation are not compared.
+type ObjectDiff struct {
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Name             string
+	// Created is true if the object doesn't exist yet, in which case all its fields are reported as added
+	Created bool
+	Added   []FieldChange
+	Removed []FieldChange
+	Changed []FieldChange
+}
+
+// HasChanges returns true if applying the object would change anything in the cluster
+func (d ObjectDiff) HasChanges() bool {
+	return d.Created || len(d.Added) > 0 || len(d.Removed) > 0 || len(d.Changed) > 0
+}
+
+// LabelChanges returns all the changes of the labels of the object
+func (d ObjectDiff) LabelChanges() []FieldChange {
+	return d.changesOf("metadata.labels")
+}
+
+// OwnerReferencesChanged returns true if the owner references of the object would change
+func (d ObjectDiff) OwnerReferencesChanged() bool {
+	return len(d.changesOf("metadata.ownerReferences")) > 0
+}
+
+func (d ObjectDiff) changesOf(path string) []FieldChange {
+	var changes []FieldChange
+	for _, all := range [][]FieldChange{d.Added, d.Removed, d.Changed} {
+		for _, change := range all {
+			if change.Path == path || strings.HasPrefix(change.Path, path+".") || strings.HasPrefix(change.Path, path+"[") {
+				changes = append(changes, change)
+			}
+		}
+	}
+	return changes
+}
+
+// String returns a human-readable summary of the diff, suitable for logging
+func (d ObjectDiff) String() string {
+	object := fmt.Sprintf("%s %s/%s", d.GroupVersionKind.Kind, d.Namespace, d.Name)
+	if d.Namespace == "" {
+		object = fmt.Sprintf("%s %s", d.GroupVersionKind.Kind, d.Name)
+	}
+	switch {
+	case d.Created:
+		return fmt.Sprintf("%s would be created", object)
+	case !d.HasChanges():
+		return fmt.Sprintf("%s would not change", object)
+	}
+	changes := make([]string, 0, len(d.Added)+len(d.Removed)+len(d.Changed))
+	for _, all := range [][]FieldChange{d.Added, d.Removed, d.Changed} {
+		for _, change := range all {
+			changes = append(changes, change.String())
+		}
+	}
+	return fmt.Sprintf("%s would change: %s", object, strings.Join(changes, ", "))
+}
+
+// DiffObjects compares the live object (nil if it doesn't exist) with the desired one
+func DiffObjects(live, desired client.Object) (ObjectDiff, error) {
+	diff := ObjectDiff{
+		GroupVersionKind: desired.GetObjectKind().GroupVersionKind(),
+		Namespace:        desired.GetNamespace(),
+		Name:             desired.GetName(),
+		Created:          live == nil,
+	}
+	desiredContent, err := comparableContent(desired)
+	if err != nil {
+		return diff, err
+	}
+	var liveContent map[string]interface{}
+	if live != nil {
+		if liveContent, err = comparableContent(live); err != nil {
+			return diff, err
+		}
+	}
+	diff.compare("", liveContent, desiredContent, live != nil, true)
+	return diff, nil
+}
+
+// diffAppliedFields compares the live object with the desired one the same way as DiffObjects, but only the fields of the live object
+// that are present either in the desired object or in the given last applied configuration are compared. The other fields
+// (eg. the ones defaulted by the server or set by other controllers) are ignored.
+func diffAppliedFields(live, desired client.Object, lastApplied map[string]interface{}) (ObjectDiff, error) {
+	diff := ObjectDiff{
+		GroupVersionKind: desired.GetObjectKind().GroupVersionKind(),
+		Namespace:        desired.GetNamespace(),
+		Name:             desired.GetName(),
+	}
+	desiredContent, err := comparableContent(desired)
+	if err != nil {
+		return diff, err
+	}
+	liveContent, err := comparableContent(live)
+	if err != nil {
+		return diff, err
+	}
+	diff.compare("", retainFields(liveContent, desiredContent, lastApplied), desiredContent, true, true)
+	return diff, nil
+}
+
+// retainFields returns the fields of the live content that are present in the desired content or in the last applied one
+func retainFields(live, desired, lastApplied map[string]interface{}) map[string]interface{} {
+	retained := make(map[string]interface{}, len(live))
+	for key, value := range live {
+		desiredValue, inDesired := desired[key]
+		appliedValue, inApplied := lastApplied[key]
+		if !inDesired && !inApplied {
+			continue
+		}
+		if liveMap, ok := value.(map[string]interface{}); ok {
+			desiredMap, _ := desiredValue.(map[string]interface{})
+			appliedMap, _ := appliedValue.(map[string]interface{})
+			// the map replaced by a value of another type is compared as a whole
+			if desiredMap != nil || appliedMap != nil {
+				value = retainFields(liveMap, desiredMap, appliedMap)
+			}
+		}
+		retained[key] = value
+	}
+	return retained
+}
+
+// comparableContent converts the object to its unstructured content without the fields that are not compared
+func comparableContent(obj client.Object) (map[string]interface{}, error) {
+	var content map[string]interface{}
+	if u, ok := obj.(runtime.Unstructured); ok {
+		content = runtime.DeepCopyJSON(u.UnstructuredContent())
+	} else {
+		var err error
+		if content, err = runtime.DefaultUnstructuredConverter.ToUnstructured(obj); err != nil {
+			return nil, err
+		}
+	}
+	delete(content, "apiVersion")
+	delete(content, "kind")
+	delete(content, "status")
+	if metadata, ok := content["metadata"].(map[string]interface{}); ok {
+		for _, field := range []string{"managedFields", "resourceVersion", "generation", "uid", "creationTimestamp", "selfLink"} {
+			delete(metadata, field)
+		}
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			delete(annotations, LastAppliedConfigurationAnnotationKey)
+		}
+	}
+	return content, nil
+}
+
+// compare walks the maps recursively and records the changes of all the other values
+func (d *ObjectDiff) compare(path string, old, new interface{}, oldFound, newFound bool) {
+	oldMap, oldIsMap := old.(map[string]interface{})
+	newMap, newIsMap := new.(map[string]interface{})
+	if (oldIsMap || newIsMap) && (oldIsMap || !oldFound) && (newIsMap || !newFound) {
+		keys := map[string]struct{}{}
+		for key := range oldMap {
+			keys[key] = struct{}{}
+		}
+		for key := range newMap {
+			keys[key] = struct{}{}
+		}
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+		for _, key := range sorted {
+			oldValue, oldKeyFound := oldMap[key]
+			newValue, newKeyFound := newMap[key]
+			d.compare(fieldPath(path, key), oldValue, newValue, oldKeyFound, newKeyFound)
+		}
+		return
+	}
+	switch {
+	case !oldFound && newFound:
+		d.Added = append(d.Added, FieldChange{Path: path, New: new})
+	case oldFound && !newFound:
+		d.Removed = append(d.Removed, FieldChange{Path: path, Old: old})
+	case !reflect.DeepEqual(old, new):
+		d.Changed = append(d.Changed, FieldChange{Path: path, Old: old, New: new})
+	}
+}
+
+func fieldPath(parent, key string) string {
+	if strings.ContainsAny(key, "./") {
+		return fmt.Sprintf("%s[%s]", parent, key)
+	}
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
@@ -0,0 +1,302 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestDiffObjects(t *testing.T) {
+	// given
+	live := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "cm",
+			Namespace:       "default",
+			ResourceVersion: "123",
+			Labels:          map[string]string{"toolchain.dev.openshift.com/owner": "john", "removed": "true"},
+			Annotations:     map[string]string{client.LastAppliedConfigurationAnnotationKey: "{}"},
+		},
+		Data: map[string]string{"kept": "value", "changed": "old", "removed": "value"},
+	}
+
+	t.Run("changes", func(t *testing.T) {
+		// given
+		desired := &corev1.ConfigMap{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "cm",
+				Namespace:       "default",
+				Labels:          map[string]string{"toolchain.dev.openshift.com/owner": "jane"},
+				OwnerReferences: []metav1.OwnerReference{{APIVersion: "v1", Kind: "ConfigMap", Name: "owner", UID: "abc"}},
+			},
+			Data: map[string]string{"kept": "value", "changed": "new", "added": "value"},
+		}
+
+		// when
+		diff, err := client.DiffObjects(live, desired)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, diff.GroupVersionKind)
+		assert.False(t, diff.Created)
+		assert.True(t, diff.HasChanges())
+		assert.Equal(t, []client.FieldChange{
+			{Path: "data.added", New: "value"},
+			{Path: "metadata.ownerReferences", New: []interface{}{map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap", "name": "owner", "uid": "abc"}}},
+		}, diff.Added)
+		assert.Equal(t, []client.FieldChange{
+			{Path: "data.removed", Old: "value"},
+			{Path: "metadata.labels.removed", Old: "true"},
+		}, diff.Removed)
+		assert.Equal(t, []client.FieldChange{
+			{Path: "data.changed", Old: "old", New: "new"},
+			{Path: "metadata.labels[toolchain.dev.openshift.com/owner]", Old: "john", New: "jane"},
+		}, diff.Changed)
+		assert.Len(t, diff.LabelChanges(), 2)
+		assert.True(t, diff.OwnerReferencesChanged())
+		assert.Equal(t, "ConfigMap default/cm would change: +data.added: value, "+
+			"+metadata.ownerReferences: [map[apiVersion:v1 kind:ConfigMap name:owner uid:abc]], "+
+			"-data.removed: value, -metadata.labels.removed: true, "+
+			"~data.changed: old -> new, ~metadata.labels[toolchain.dev.openshift.com/owner]: john -> jane", diff.String())
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		// given
+		desired := live.DeepCopy()
+		desired.ResourceVersion = ""
+		desired.Annotations = nil
+
+		// when
+		diff, err := client.DiffObjects(live, desired)
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, diff.HasChanges())
+		assert.False(t, diff.OwnerReferencesChanged())
+		assert.Equal(t, "ConfigMap default/cm would not change", diff.String())
+	})
+
+	t.Run("created", func(t *testing.T) {
+		// when
+		diff, err := client.DiffObjects(nil, live)
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, diff.Created)
+		assert.Len(t, diff.Added, 7) // 3 data keys, 2 labels, name and namespace
+		assert.Len(t, diff.LabelChanges(), 2)
+		assert.Equal(t, "ConfigMap default/cm would be created", diff.String())
+	})
+}
+
+func TestApplyClientDiffObject(t *testing.T) {
+	// given
+	addToScheme(t)
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "default"},
+		Data:       map[string]string{"key": "value"},
+	}
+
+	t.Run("missing object", func(t *testing.T) {
+		// given
+		cl, fakeClient := newClient(t)
+		owner := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "owner", Namespace: "default", UID: "abc"}}
+
+		// when
+		diff, err := cl.DiffObject(context.TODO(), cm.DeepCopy(), client.SetOwner(owner))
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, diff.Created)
+		assert.True(t, diff.OwnerReferencesChanged())
+		assert.Equal(t, "ConfigMap", diff.GroupVersionKind.Kind)
+		err = fakeClient.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(cm), &corev1.ConfigMap{})
+		assert.True(t, errors.IsNotFound(err))
+	})
+
+	t.Run("existing object", func(t *testing.T) {
+		// given
+		cl, fakeClient := newClient(t)
+		_, err := cl.ApplyObject(context.TODO(), cm.DeepCopy())
+		require.NoError(t, err)
+		modified := cm.DeepCopy()
+		modified.Data["key"] = "modified"
+
+		t.Run("same configuration", func(t *testing.T) {
+			// when
+			diff, err := cl.DiffObject(context.TODO(), cm.DeepCopy())
+
+			// then
+			require.NoError(t, err)
+			assert.False(t, diff.HasChanges())
+		})
+
+		t.Run("changed configuration", func(t *testing.T) {
+			// when
+			diff, err := cl.DiffObject(context.TODO(), modified)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, []client.FieldChange{{Path: "data.key", Old: "value", New: "modified"}}, diff.Changed)
+			assert.Empty(t, modified.Annotations, "the given object should not be modified")
+			inCluster := &corev1.ConfigMap{}
+			require.NoError(t, fakeClient.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(cm), inCluster))
+			assert.Equal(t, "value", inCluster.Data["key"])
+		})
+
+		t.Run("multiple objects with labels", func(t *testing.T) {
+			// when
+			diffs, err := cl.Diff(context.TODO(), []runtimeclient.Object{modified, cm.DeepCopy()}, map[string]string{"new": "label"})
+
+			// then
+			require.NoError(t, err)
+			require.Len(t, diffs, 2)
+			assert.Len(t, diffs[0].Changed, 1)
+			assert.Equal(t, []client.FieldChange{{Path: "metadata.labels.new", New: "label"}}, diffs[0].LabelChanges())
+			assert.Equal(t, []client.FieldChange{{Path: "metadata.labels.new", New: "label"}}, diffs[1].Added)
+			assert.Empty(t, modified.Labels, "the given object should not be modified")
+		})
+	})
+
+	t.Run("fields not applied by the client", func(t *testing.T) {
+		// given
+		cl, fakeClient := newClient(t)
+		applied := cm.DeepCopy()
+		applied.Data["removed"] = "value"
+		_, err := cl.ApplyObject(context.TODO(), applied)
+		require.NoError(t, err)
+		// emulate the field set by the server
+		inCluster := &corev1.ConfigMap{}
+		require.NoError(t, fakeClient.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(cm), inCluster))
+		inCluster.Data["defaulted"] = "value"
+		require.NoError(t, fakeClient.Update(context.TODO(), inCluster))
+
+		// when
+		diff, err := cl.DiffObject(context.TODO(), cm.DeepCopy())
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []client.FieldChange{{Path: "data.removed", Old: "value"}}, diff.Removed)
+		assert.Empty(t, diff.Added)
+		assert.Empty(t, diff.Changed)
+	})
+}
+
+func TestSsaClientDiffObject(t *testing.T) {
+	// given
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "default"},
+		Data:       map[string]string{"key": "value"},
+	}
+
+	t.Run("missing object", func(t *testing.T) {
+		// given
+		cl, acl := NewTestSsaApplyClient(t)
+
+		// when
+		diff, err := acl.DiffObject(context.TODO(), cm.DeepCopy(), client.EnsureLabels(map[string]string{"new": "label"}))
+
+		// then
+		require.NoError(t, err)
+		assert.True(t, diff.Created)
+		assert.Equal(t, []client.FieldChange{{Path: "metadata.labels.new", New: "label"}}, diff.LabelChanges())
+		err = cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(cm), &corev1.ConfigMap{})
+		assert.True(t, errors.IsNotFound(err))
+	})
+
+	t.Run("existing object", func(t *testing.T) {
+		// given
+		cl, acl := NewTestSsaApplyClient(t, cm.DeepCopy())
+		modified := cm.DeepCopy()
+		modified.Data["key"] = "modified"
+
+		// when
+		diffs, err := acl.Diff(context.TODO(), []runtimeclient.Object{modified, cm.DeepCopy()})
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, diffs, 2)
+		assert.Equal(t, []client.FieldChange{{Path: "data.key", Old: "value", New: "modified"}}, diffs[0].Changed)
+		assert.False(t, diffs[1].HasChanges())
+		assert.Empty(t, modified.GetObjectKind().GroupVersionKind().Kind, "the given object should not be modified")
+		inCluster := &corev1.ConfigMap{}
+		require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(cm), inCluster))
+		assert.Equal(t, "value", inCluster.Data["key"])
+	})
+
+	t.Run("skipped object", func(t *testing.T) {
+		// given
+		_, acl := NewTestSsaApplyClient(t, cm.DeepCopy())
+		modified := cm.DeepCopy()
+		modified.Data["key"] = "modified"
+
+		// when
+		diff, err := acl.DiffObject(context.TODO(), modified, client.SkipIf(func(runtimeclient.Object) bool { return true }))
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, diff.HasChanges())
+	})
+
+	t.Run("without forcing the ownership", func(t *testing.T) {
+		// given
+		cl, acl := NewTestSsaApplyClient(t, cm.DeepCopy())
+		var dryRun [][]string
+		var forced []bool
+		cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+			patchOpts := (&runtimeclient.PatchOptions{}).ApplyOptions(opts)
+			dryRun = append(dryRun, patchOpts.DryRun)
+			forced = append(forced, patchOpts.Force != nil && *patchOpts.Force)
+			if !forced[len(forced)-1] {
+				return errors.NewApplyConflict([]metav1.StatusCause{
+					{Type: metav1.CauseTypeFieldManagerConflict, Field: ".data.key", Message: `conflict with "other-operator"`},
+				}, "Apply failed with 1 conflict")
+			}
+			return test.Patch(ctx, cl, obj, patch, opts...)
+		}
+		modified := cm.DeepCopy()
+		modified.Data["key"] = "modified"
+
+		t.Run("reports the conflicts", func(t *testing.T) {
+			// given
+			dryRun, forced = nil, nil
+
+			// when
+			_, err := acl.DiffObject(context.TODO(), modified.DeepCopy(), client.NoForceOwnership())
+
+			// then
+			conflictErr := &client.ConflictError{}
+			require.ErrorAs(t, err, &conflictErr)
+			assert.Equal(t, []client.FieldConflict{{Field: ".data.key", Manager: "other-operator"}}, conflictErr.Conflicts)
+			assert.Equal(t, []bool{false}, forced)
+			assert.Equal(t, [][]string{{metav1.DryRunAll}}, dryRun)
+		})
+
+		t.Run("overrides the allowed managers", func(t *testing.T) {
+			// given
+			dryRun, forced = nil, nil
+
+			// when
+			diff, err := acl.DiffObject(context.TODO(), modified.DeepCopy(), client.NoForceOwnership("other-operator"))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, []client.FieldChange{{Path: "data.key", Old: "value", New: "modified"}}, diff.Changed)
+			assert.Equal(t, []bool{false, true}, forced)
+			assert.Equal(t, [][]string{{metav1.DryRunAll}, {metav1.DryRunAll}}, dryRun)
+			inCluster := &corev1.ConfigMap{}
+			require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(cm), inCluster))
+			assert.Equal(t, "value", inCluster.Data["key"])
+		})
+	})
+}
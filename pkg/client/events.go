@@ -0,0 +1,144 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The reasons of the events emitted on the owners of the applied objects
+const (
+	EventReasonObjectCreated = "ObjectCreated"
+	EventReasonObjectUpdated = "ObjectUpdated"
+	EventReasonObjectPruned  = "ObjectPruned"
+	EventReasonApplyFailed   = "ApplyFailed"
+	EventReasonPruneFailed   = "PruneFailed"
+)
+
+// maxEventsPerReason is the maximum number of the individual events with the same reason emitted on an owner
+// when applying a batch of objects. When there are more of them, a single aggregated event is emitted instead.
+const maxEventsPerReason = 5
+
+type eventKey struct {
+	owner     runtime.Object
+	eventType string
+	reason    string
+}
+
+// eventMessage is the template of the messages of the events with the same reason
+type eventMessage struct {
+	// single is the format of the message about a single item
+	single string
+	// aggregated is the format of the message aggregating more than maxEventsPerReason items. It gets the number
+	// of the items, the first maxEventsPerReason items and the number of the remaining ones.
+	aggregated string
+}
+
+// eventMessages are the message templates of the events emitted with the given reasons
+var eventMessages = map[string]eventMessage{
+	EventReasonObjectCreated: {single: "created %s", aggregated: "created %d objects: %s and %d more"},
+	EventReasonObjectUpdated: {single: "updated %s", aggregated: "updated %d objects: %s and %d more"},
+	EventReasonObjectPruned:  {single: "pruned %s", aggregated: "pruned %d objects: %s and %d more"},
+	EventReasonApplyFailed:   {single: "failed to apply %s", aggregated: "failed to apply %d objects: %s and %d more"},
+	EventReasonPruneFailed:   {single: "pruning failed: %s", aggregated: "pruning failed for %d objects: %s and %d more"},
+}
+
+// eventBatch collects the events while applying a batch of objects, so they can be aggregated before they are emitted.
+// It does nothing if there is no recorder.
+type eventBatch struct {
+	recorder record.EventRecorder
+	keys     []eventKey
+	entries  map[eventKey][]string
+}
+
+func newEventBatch(recorder record.EventRecorder) *eventBatch {
+	return &eventBatch{
+		recorder: recorder,
+		entries:  map[eventKey][]string{},
+	}
+}
+
+// add adds an event about the given item on the given owner. The events with the same type and reason are aggregated
+// when the batch is flushed.
+func (b *eventBatch) add(owner interface{}, eventType, reason, item string) {
+	ownerObj, ok := owner.(runtime.Object)
+	if b == nil || b.recorder == nil || !ok || ownerObj == nil {
+		return
+	}
+	key := eventKey{owner: ownerObj, eventType: eventType, reason: reason}
+	if _, found := b.entries[key]; !found {
+		b.keys = append(b.keys, key)
+	}
+	b.entries[key] = append(b.entries[key], item)
+}
+
+// enabled returns true if the events are recorded
+func (b *eventBatch) enabled() bool {
+	return b != nil && b.recorder != nil
+}
+
+// addApplied adds the event about the result of applying the object on the given owner
+func (b *eventBatch) addApplied(owner interface{}, obj client.Object, scheme *runtime.Scheme, result ApplyResult, err error) {
+	if !b.enabled() {
+		return
+	}
+	switch {
+	case err != nil:
+		b.add(owner, corev1.EventTypeWarning, EventReasonApplyFailed, fmt.Sprintf("%s: %s", describe(obj, scheme), err))
+	case result.Operation == ApplyCreated:
+		b.add(owner, corev1.EventTypeNormal, EventReasonObjectCreated, result.Object.String())
+	case result.Operation == ApplyUpdated:
+		b.add(owner, corev1.EventTypeNormal, EventReasonObjectUpdated, result.Object.String())
+	}
+}
+
+// addPruned adds the events about the objects pruned from the inventory of the given parent. When some of the objects
+// failed to be deleted, there is a separate event about each of them.
+func (b *eventBatch) addPruned(parent client.Object, pruned []ObjectRef, err error) {
+	for _, ref := range pruned {
+		b.add(parent, corev1.EventTypeNormal, EventReasonObjectPruned, ref.String())
+	}
+	pruneErr := &PruneError{}
+	switch {
+	case errors.As(err, &pruneErr):
+		for _, failure := range pruneErr.Failures {
+			b.add(parent, corev1.EventTypeWarning, EventReasonPruneFailed, failure.String())
+		}
+	case err != nil:
+		b.add(parent, corev1.EventTypeWarning, EventReasonPruneFailed, err.Error())
+	}
+}
+
+// flush emits the collected events. The events with the same type and reason on the same owner are aggregated into
+// a single event when there are more than maxEventsPerReason of them.
+func (b *eventBatch) flush() {
+	if !b.enabled() {
+		return
+	}
+	for _, key := range b.keys {
+		items := b.entries[key]
+		message := eventMessages[key.reason]
+		if len(items) <= maxEventsPerReason {
+			for _, item := range items {
+				b.recorder.Eventf(key.owner, key.eventType, key.reason, message.single, item)
+			}
+			continue
+		}
+		b.recorder.Eventf(key.owner, key.eventType, key.reason, message.aggregated, len(items),
+			strings.Join(items[:maxEventsPerReason], ", "), len(items)-maxEventsPerReason)
+	}
+	b.keys = nil
+	b.entries = map[eventKey][]string{}
+}
+
+// describe returns the human-readable reference to the object
+func describe(obj client.Object, scheme *runtime.Scheme) string {
+	obj = obj.DeepCopyObject().(client.Object)
+	_ = EnsureGVK(obj, scheme) // best effort, the kind is just omitted if it can't be determined
+	return NewObjectRef(obj).String()
+}
@@ -0,0 +1,208 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestApplyEvents(t *testing.T) {
+	// given
+	newOwner := func() *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "owner", Namespace: "ns", UID: "abc"}}
+	}
+	newConfigMap := func(name string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"},
+			Data:       map[string]string{"key": "value"},
+		}
+	}
+
+	t.Run("with SSA client", func(t *testing.T) {
+		t.Run("created and updated", func(t *testing.T) {
+			// given
+			owner := newOwner()
+			_, acl := NewTestSsaApplyClient(t, owner)
+			recorder := record.NewFakeRecorder(10)
+			acl.EventRecorder = recorder
+			require.NoError(t, acl.ApplyObject(context.TODO(), newConfigMap("cm"), client.SetOwnerReference(owner)))
+			modified := newConfigMap("cm")
+			modified.Data["key"] = "modified"
+
+			// when
+			err := acl.ApplyObject(context.TODO(), modified, client.SetOwnerReference(owner))
+
+			// then
+			require.NoError(t, err)
+			assertEvents(t, recorder,
+				"Normal ObjectCreated created ConfigMap ns/cm",
+				"Normal ObjectUpdated updated ConfigMap ns/cm")
+		})
+
+		t.Run("no events without owner", func(t *testing.T) {
+			// given
+			_, acl := NewTestSsaApplyClient(t)
+			recorder := record.NewFakeRecorder(10)
+			acl.EventRecorder = recorder
+
+			// when
+			err := acl.ApplyObject(context.TODO(), newConfigMap("cm"))
+
+			// then
+			require.NoError(t, err)
+			assertEvents(t, recorder)
+		})
+
+		t.Run("failed", func(t *testing.T) {
+			// given
+			owner := newOwner()
+			cl, acl := NewTestSsaApplyClient(t, owner)
+			recorder := record.NewFakeRecorder(10)
+			acl.EventRecorder = recorder
+			cl.MockPatch = func(_ context.Context, _ runtimeclient.Object, _ runtimeclient.Patch, _ ...runtimeclient.PatchOption) error {
+				return fmt.Errorf("some error")
+			}
+
+			// when
+			err := acl.ApplyObject(context.TODO(), newConfigMap("cm"), client.SetOwnerReference(owner))
+
+			// then
+			require.Error(t, err)
+			assertEvents(t, recorder,
+				"Warning ApplyFailed failed to apply ConfigMap ns/cm: unable to patch '/v1, Kind=ConfigMap' called 'cm' in namespace 'ns': some error")
+		})
+
+		t.Run("aggregated for large batches", func(t *testing.T) {
+			// given
+			owner := newOwner()
+			_, acl := NewTestSsaApplyClient(t, owner)
+			recorder := record.NewFakeRecorder(10)
+			acl.EventRecorder = recorder
+			var objects []runtimeclient.Object
+			for i := 0; i < 7; i++ {
+				objects = append(objects, newConfigMap(fmt.Sprintf("cm-%d", i)))
+			}
+
+			// when
+			err := acl.Apply(context.TODO(), objects, client.SetOwnerReference(owner))
+
+			// then
+			require.NoError(t, err)
+			assertEvents(t, recorder,
+				"Normal ObjectCreated created 7 objects: ConfigMap ns/cm-0, ConfigMap ns/cm-1, ConfigMap ns/cm-2, ConfigMap ns/cm-3, ConfigMap ns/cm-4 and 2 more")
+		})
+
+		t.Run("pruned", func(t *testing.T) {
+			// given
+			parent := newOwner()
+			cl, acl := NewTestSsaApplyClient(t, parent)
+			require.NoError(t, acl.ApplyAndPrune(context.TODO(), []runtimeclient.Object{newConfigMap("cm-1"), newConfigMap("cm-2")}, client.NewPruner(cl, parent), client.SetOwnerReference(parent)))
+			recorder := record.NewFakeRecorder(10)
+			acl.EventRecorder = recorder
+
+			// when
+			err := acl.ApplyAndPrune(context.TODO(), []runtimeclient.Object{newConfigMap("cm-1")},
+				client.NewPruner(cl, parent, client.AllowPruning(schema.GroupKind{Kind: "ConfigMap"})), client.SetOwnerReference(parent))
+
+			// then
+			require.NoError(t, err)
+			assertEvents(t, recorder,
+				"Normal ObjectUpdated updated ConfigMap ns/cm-1",
+				"Normal ObjectPruned pruned ConfigMap ns/cm-2")
+		})
+
+		t.Run("prune failures", func(t *testing.T) {
+			// given
+			newPrunedObjects := func(count int) (*test.FakeClient, *client.SSAApplyClient, *corev1.ConfigMap) {
+				parent := newOwner()
+				cl, acl := NewTestSsaApplyClient(t, parent)
+				var objects []runtimeclient.Object
+				for i := 0; i < count; i++ {
+					objects = append(objects, newConfigMap(fmt.Sprintf("cm-%d", i)))
+				}
+				require.NoError(t, acl.ApplyAndPrune(context.TODO(), objects, client.NewPruner(cl, parent), client.SetOwnerReference(parent)))
+				cl.MockDelete = func(_ context.Context, _ runtimeclient.Object, _ ...runtimeclient.DeleteOption) error {
+					return fmt.Errorf("some error")
+				}
+				return cl, acl, parent
+			}
+
+			t.Run("single", func(t *testing.T) {
+				// given
+				cl, acl, parent := newPrunedObjects(1)
+				recorder := record.NewFakeRecorder(10)
+				acl.EventRecorder = recorder
+
+				// when
+				err := acl.ApplyAndPrune(context.TODO(), nil, client.NewPruner(cl, parent, client.AllowPruning(schema.GroupKind{Kind: "ConfigMap"})))
+
+				// then
+				require.Error(t, err)
+				assertEvents(t, recorder,
+					"Warning PruneFailed pruning failed: ConfigMap ns/cm-0: some error")
+			})
+
+			t.Run("aggregated", func(t *testing.T) {
+				// given
+				cl, acl, parent := newPrunedObjects(7)
+				recorder := record.NewFakeRecorder(10)
+				acl.EventRecorder = recorder
+
+				// when
+				err := acl.ApplyAndPrune(context.TODO(), nil, client.NewPruner(cl, parent, client.AllowPruning(schema.GroupKind{Kind: "ConfigMap"})))
+
+				// then
+				pruneErr := &client.PruneError{}
+				require.ErrorAs(t, err, &pruneErr)
+				assert.Len(t, pruneErr.Failures, 7)
+				assertEvents(t, recorder,
+					"Warning PruneFailed pruning failed for 7 objects: ConfigMap ns/cm-0: some error, ConfigMap ns/cm-1: some error, "+
+						"ConfigMap ns/cm-2: some error, ConfigMap ns/cm-3: some error, ConfigMap ns/cm-4: some error and 2 more")
+			})
+		})
+	})
+
+	t.Run("with legacy client", func(t *testing.T) {
+		// given
+		addToScheme(t)
+		owner := newOwner()
+		recorder := record.NewFakeRecorder(10)
+		acl := client.ApplyClient{Client: test.NewFakeClient(t, owner), EventRecorder: recorder}
+
+		// when
+		_, err := acl.ApplyObject(context.TODO(), newConfigMap("cm"), client.SetOwner(owner))
+
+		// then
+		require.NoError(t, err)
+		assertEvents(t, recorder, "Normal ObjectCreated created ConfigMap ns/cm")
+
+		t.Run("unchanged", func(t *testing.T) {
+			// when
+			_, err := acl.ApplyObject(context.TODO(), newConfigMap("cm"), client.SetOwner(owner))
+
+			// then
+			require.NoError(t, err)
+			assertEvents(t, recorder)
+		})
+	})
+}
+
+// assertEvents checks that the recorder received exactly the given events
+func assertEvents(t *testing.T, recorder *record.FakeRecorder, expected ...string) {
+	t.Helper()
+	var actual []string
+	for len(recorder.Events) > 0 {
+		actual = append(actual, <-recorder.Events)
+	}
+	assert.Equal(t, expected, actual)
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/util/csaupgrade"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// MigrationFailure is an object that failed to be migrated to SSA
+type MigrationFailure struct {
+	ObjectRef
+	Err error
+}
+
+// MigrationReport is the result of the migration of the objects to SSA
+type MigrationReport struct {
+	// Migrated are the objects whose managed fields were converted to SSA or that had the last-applied-configuration annotation removed
+	Migrated []ObjectRef
+	// Skipped are the objects that didn't need any migration
+	Skipped []ObjectRef
+	// Failed are the objects that failed to be migrated
+	Failed []MigrationFailure
+}
+
+// Err returns an error aggregating the errors of all the failed objects, or nil if no object failed
+func (r MigrationReport) Err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(r.Failed))
+	for i, failure := range r.Failed {
+		msgs[i] = fmt.Sprintf("%s: %s", failure.ObjectRef, failure.Err)
+	}
+	return fmt.Errorf("unable to migrate %d object(s) to SSA: %s", len(r.Failed), strings.Join(msgs, "; "))
+}
+
+// MigrateAllToSSA migrates all the objects of the given kinds matching the given list options (eg. a label selector and
+// a namespace - all the namespaces are scanned by default) so they can be managed using the SSAApplyClient:
+// the managed fields owned by the NonSSAFieldOwner are converted to be owned by the FieldOwner using SSA,
+// and the last-applied-configuration annotation used by the ApplyClient is removed.
+//
+// The objects failed to be migrated are reported and don't stop the migration of the other objects. An error is returned
+// only when the objects can't be listed.
+func (c *SSAApplyClient) MigrateAllToSSA(ctx context.Context, gvks []schema.GroupVersionKind, opts ...client.ListOption) (MigrationReport, error) {
+	report := MigrationReport{}
+	oldFieldOwner := c.nonSSAFieldOwner()
+	for _, gvk := range gvks {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err := c.Client.List(ctx, list, opts...); err != nil {
+			return report, fmt.Errorf("unable to list the objects of kind '%s' to migrate to SSA: %w", gvk, err)
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			obj.SetGroupVersionKind(gvk)
+			ref := NewObjectRef(obj)
+			migrated, err := c.migrateObjectToSSA(ctx, obj, oldFieldOwner)
+			switch {
+			case err != nil:
+				log.Error(err, "unable to migrate the object to SSA", "object", ref.String())
+				report.Failed = append(report.Failed, MigrationFailure{ObjectRef: ref, Err: err})
+			case migrated:
+				log.Info("migrated the object to SSA", "object", ref.String())
+				report.Migrated = append(report.Migrated, ref)
+			default:
+				report.Skipped = append(report.Skipped, ref)
+			}
+		}
+	}
+	return report, nil
+}
+
+// migrateObjectToSSA converts the managed fields and removes the last-applied-configuration annotation of the given object.
+// It returns false if the object didn't need any migration.
+func (c *SSAApplyClient) migrateObjectToSSA(ctx context.Context, obj *unstructured.Unstructured, oldFieldOwner string) (bool, error) {
+	migrated := false
+	attempt := 0
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		attempt++
+		if attempt > 1 {
+			if err := c.Client.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+				return err
+			}
+		}
+		migrationNeeded := isSsaMigrationNeeded(obj, oldFieldOwner)
+		annotations := obj.GetAnnotations()
+		_, annotated := annotations[LastAppliedConfigurationAnnotationKey]
+		if !migrationNeeded && !annotated {
+			migrated = false
+			return nil
+		}
+		if migrationNeeded {
+			if err := csaupgrade.UpgradeManagedFields(obj, sets.New(oldFieldOwner), c.FieldOwner); err != nil {
+				return fmt.Errorf("failed to migrate the managed fields: %w", err)
+			}
+		}
+		if annotated {
+			delete(annotations, LastAppliedConfigurationAnnotationKey)
+			obj.SetAnnotations(annotations)
+		}
+		migrated = true
+		return c.Client.Update(ctx, obj)
+	})
+	return migrated, err
+}
@@ -0,0 +1,155 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestMigrateAllToSSA(t *testing.T) {
+	// given
+	configMapGVK := corev1.SchemeGroupVersion.WithKind("ConfigMap")
+	serviceGVK := corev1.SchemeGroupVersion.WithKind("Service")
+	gvks := []schema.GroupVersionKind{configMapGVK, serviceGVK}
+	selected := map[string]string{"toolchain.dev.openshift.com/provider": "codeready-toolchain"}
+	updatedBy := func(manager string, operation metav1.ManagedFieldsOperationType) []metav1.ManagedFieldsEntry {
+		return []metav1.ManagedFieldsEntry{{
+			APIVersion: "v1",
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(`{"f:data": {"f:key": {}}}`)},
+			Manager:    manager,
+			Operation:  operation,
+		}}
+	}
+	crudFieldOwner := strings.Split(rest.DefaultKubernetesUserAgent(), "/")[0]
+	newObjects := func() []runtimeclient.Object {
+		return []runtimeclient.Object{
+			// the managed fields and the annotation to migrate
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Name:          "with-managed-fields",
+				Namespace:     "ns-1",
+				Labels:        selected,
+				Annotations:   map[string]string{client.LastAppliedConfigurationAnnotationKey: "{}", "other": "value"},
+				ManagedFields: updatedBy(crudFieldOwner, metav1.ManagedFieldsOperationUpdate),
+			}, Data: map[string]string{"key": "value"}},
+			// only the annotation to remove
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{
+				Name:        "with-annotation",
+				Namespace:   "ns-2",
+				Labels:      selected,
+				Annotations: map[string]string{client.LastAppliedConfigurationAnnotationKey: "{}"},
+			}},
+			// already managed using SSA
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Name:          "migrated",
+				Namespace:     "ns-2",
+				Labels:        selected,
+				ManagedFields: updatedBy("test-field-owner", metav1.ManagedFieldsOperationApply),
+			}, Data: map[string]string{"key": "value"}},
+			// not matching the selector
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Name:        "not-selected",
+				Namespace:   "ns-1",
+				Annotations: map[string]string{client.LastAppliedConfigurationAnnotationKey: "{}"},
+			}},
+		}
+	}
+
+	t.Run("migrates the selected objects", func(t *testing.T) {
+		// given
+		cl, acl := NewTestSsaApplyClient(t, newObjects()...)
+
+		// when
+		report, err := acl.MigrateAllToSSA(context.TODO(), gvks, runtimeclient.MatchingLabels(selected))
+
+		// then
+		require.NoError(t, err)
+		require.NoError(t, report.Err())
+		assert.Equal(t, []client.ObjectRef{
+			{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns-1", Name: "with-managed-fields"},
+			{APIVersion: "v1", Kind: "Service", Namespace: "ns-2", Name: "with-annotation"},
+		}, report.Migrated)
+		assert.Equal(t, []client.ObjectRef{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns-2", Name: "migrated"}}, report.Skipped)
+
+		cm := &corev1.ConfigMap{}
+		require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKey{Name: "with-managed-fields", Namespace: "ns-1"}, cm))
+		assert.Equal(t, map[string]string{"other": "value"}, cm.Annotations)
+		require.Len(t, cm.ManagedFields, 1)
+		assert.Equal(t, "test-field-owner", cm.ManagedFields[0].Manager)
+		assert.Equal(t, metav1.ManagedFieldsOperationApply, cm.ManagedFields[0].Operation)
+		svc := &corev1.Service{}
+		require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKey{Name: "with-annotation", Namespace: "ns-2"}, svc))
+		assert.Empty(t, svc.Annotations)
+		require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKey{Name: "not-selected", Namespace: "ns-1"}, cm))
+		assert.Contains(t, cm.Annotations, client.LastAppliedConfigurationAnnotationKey)
+
+		t.Run("nothing to migrate the second time", func(t *testing.T) {
+			// when
+			report, err := acl.MigrateAllToSSA(context.TODO(), gvks, runtimeclient.MatchingLabels(selected))
+
+			// then
+			require.NoError(t, err)
+			assert.Empty(t, report.Migrated)
+			assert.Len(t, report.Skipped, 3)
+		})
+	})
+
+	t.Run("in a single namespace", func(t *testing.T) {
+		// given
+		_, acl := NewTestSsaApplyClient(t, newObjects()...)
+
+		// when
+		report, err := acl.MigrateAllToSSA(context.TODO(), gvks, runtimeclient.InNamespace("ns-2"), runtimeclient.MatchingLabels(selected))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []client.ObjectRef{{APIVersion: "v1", Kind: "Service", Namespace: "ns-2", Name: "with-annotation"}}, report.Migrated)
+		assert.Len(t, report.Skipped, 1)
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		t.Run("object failed to be updated", func(t *testing.T) {
+			// given
+			cl, acl := NewTestSsaApplyClient(t, newObjects()...)
+			cl.MockUpdate = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+				if obj.GetName() == "with-annotation" {
+					return fmt.Errorf("some error")
+				}
+				return cl.Client.Update(ctx, obj, opts...)
+			}
+
+			// when
+			report, err := acl.MigrateAllToSSA(context.TODO(), gvks, runtimeclient.MatchingLabels(selected))
+
+			// then
+			require.NoError(t, err)
+			assert.Len(t, report.Migrated, 1)
+			require.Len(t, report.Failed, 1)
+			require.EqualError(t, report.Err(), "unable to migrate 1 object(s) to SSA: Service ns-2/with-annotation: some error")
+		})
+
+		t.Run("objects failed to be listed", func(t *testing.T) {
+			// given
+			cl, acl := NewTestSsaApplyClient(t, newObjects()...)
+			cl.MockList = func(_ context.Context, _ runtimeclient.ObjectList, _ ...runtimeclient.ListOption) error {
+				return fmt.Errorf("some error")
+			}
+
+			// when
+			_, err := acl.MigrateAllToSSA(context.TODO(), gvks)
+
+			// then
+			require.EqualError(t, err, "unable to list the objects of kind '/v1, Kind=ConfigMap' to migrate to SSA: some error")
+		})
+	})
+}
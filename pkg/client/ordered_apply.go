@@ -0,0 +1,252 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+var crdGroupKind = schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+
+// applyPhases are the kinds of the objects applied in the individual phases, so the objects are applied after the ones
+// they depend on. The objects of all the other kinds (eg. the workloads or the custom resources) are applied in the last phase.
+var applyPhases = [][]schema.GroupKind{
+	{{Kind: "Namespace"}},
+	{crdGroupKind},
+	{{Kind: "ServiceAccount"}},
+	{{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole"}, {Group: "rbac.authorization.k8s.io", Kind: "Role"}},
+	{{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding"}, {Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"}},
+	{{Kind: "ResourceQuota"}, {Kind: "LimitRange"}, {Kind: "ConfigMap"}, {Kind: "Secret"}, {Kind: "PersistentVolumeClaim"}, {Kind: "Service"}},
+}
+
+// PlanApply splits the given objects into the phases they should be applied in: Namespaces, CustomResourceDefinitions,
+// ServiceAccounts, Roles, RoleBindings, configuration (eg. ConfigMaps, Secrets, Services) and finally all the other objects.
+// The objects keep their order within a phase and the empty phases are omitted.
+func PlanApply(objects []client.Object, scheme *runtime.Scheme) ([][]client.Object, error) {
+	indexPlan, err := planApply(objects, scheme)
+	if err != nil {
+		return nil, err
+	}
+	plan := make([][]client.Object, len(indexPlan))
+	for i, phase := range indexPlan {
+		plan[i] = make([]client.Object, len(phase))
+		for j, index := range phase {
+			plan[i][j] = objects[index]
+		}
+	}
+	return plan, nil
+}
+
+// planApply is the same as PlanApply, but the phases contain the indexes of the objects in the given slice
+func planApply(objects []client.Object, scheme *runtime.Scheme) ([][]int, error) {
+	phases := make([][]int, len(applyPhases)+1)
+	for i, obj := range objects {
+		gvk, err := apiutil.GVKForObject(obj, scheme)
+		if err != nil {
+			return nil, err
+		}
+		phases[applyPhase(gvk.GroupKind())] = append(phases[applyPhase(gvk.GroupKind())], i)
+	}
+	plan := make([][]int, 0, len(phases))
+	for _, phase := range phases {
+		if len(phase) > 0 {
+			plan = append(plan, phase)
+		}
+	}
+	return plan, nil
+}
+
+func applyPhase(groupKind schema.GroupKind) int {
+	for i, kinds := range applyPhases {
+		for _, kind := range kinds {
+			if kind == groupKind {
+				return i
+			}
+		}
+	}
+	return len(applyPhases)
+}
+
+// ApplyOutcome is the outcome of applying a single object
+type ApplyOutcome struct {
+	Object client.Object
+	Err    error
+}
+
+// ApplyOutcomes are the outcomes of applying multiple objects, in the same order as the objects were given
+type ApplyOutcomes []ApplyOutcome
+
+// Failed returns the outcomes of the objects that failed to be applied (or that were not applied at all)
+func (o ApplyOutcomes) Failed() ApplyOutcomes {
+	failed := ApplyOutcomes{}
+	for _, outcome := range o {
+		if outcome.Err != nil {
+			failed = append(failed, outcome)
+		}
+	}
+	return failed
+}
+
+// Err returns an error aggregating the errors of all the failed objects, or nil if all the objects were applied
+func (o ApplyOutcomes) Err() error {
+	failed := o.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(failed))
+	for i, outcome := range failed {
+		msgs[i] = outcome.Err.Error()
+	}
+	return fmt.Errorf("unable to apply %d of %d object(s): %s", len(failed), len(o), strings.Join(msgs, "; "))
+}
+
+// OrderedApplier applies the objects in the phases given by PlanApply. The objects of one phase are applied in parallel
+// and the next phase starts only when all the objects of the previous phase were applied successfully.
+type OrderedApplier struct {
+	client         *SSAApplyClient
+	maxConcurrency int
+	crdTimeout     time.Duration
+	pollInterval   time.Duration
+}
+
+// OrderedApplyOption an option to configure the OrderedApplier
+type OrderedApplyOption func(*OrderedApplier)
+
+// MaxConcurrency limits the number of the objects applied at the same time (default: `5`)
+func MaxConcurrency(maxConcurrency int) OrderedApplyOption {
+	return func(a *OrderedApplier) {
+		a.maxConcurrency = maxConcurrency
+	}
+}
+
+// CRDEstablishedTimeout sets how long to wait for an applied CustomResourceDefinition to become Established (default: `30s`)
+func CRDEstablishedTimeout(timeout time.Duration) OrderedApplyOption {
+	return func(a *OrderedApplier) {
+		a.crdTimeout = timeout
+	}
+}
+
+// NewOrderedApplier returns an OrderedApplier applying the objects using the given client
+func NewOrderedApplier(cl *SSAApplyClient, opts ...OrderedApplyOption) *OrderedApplier {
+	a := &OrderedApplier{
+		client:         cl,
+		maxConcurrency: 5,
+		crdTimeout:     30 * time.Second,
+		pollInterval:   500 * time.Millisecond,
+	}
+	for _, apply := range opts {
+		apply(a)
+	}
+	return a
+}
+
+// Apply applies the given objects phase by phase with the given options and reports the outcome of every object.
+// When an object fails to be applied, the objects of the following phases are not applied and are reported as failed, too.
+func (a *OrderedApplier) Apply(ctx context.Context, objects []client.Object, opts ...SSAApplyObjectOption) ApplyOutcomes {
+	outcomes := make(ApplyOutcomes, len(objects))
+	for i, obj := range objects {
+		outcomes[i].Object = obj
+	}
+	plan, err := planApply(objects, a.client.Client.Scheme())
+	if err != nil {
+		for i := range outcomes {
+			outcomes[i].Err = a.objectError(outcomes[i].Object, fmt.Errorf("unable to plan the apply: %w", err))
+		}
+		return outcomes
+	}
+	// the same object given more than once is applied only once (and not concurrently), the repeated ones share its outcome
+	first := make(map[client.Object]int, len(objects))
+	for i, obj := range objects {
+		if _, found := first[obj]; !found {
+			first[obj] = i
+		}
+	}
+	defer func() {
+		for i, obj := range objects {
+			outcomes[i].Err = outcomes[first[obj]].Err
+		}
+	}()
+
+	failed := false
+	for _, allIndexes := range plan {
+		phase := make([]int, 0, len(allIndexes))
+		for _, i := range allIndexes {
+			if first[objects[i]] == i {
+				phase = append(phase, i)
+			}
+		}
+		if failed {
+			for _, i := range phase {
+				outcomes[i].Err = a.objectError(objects[i], fmt.Errorf("not applied because of the failures in the previous phase"))
+			}
+			continue
+		}
+		limit := a.maxConcurrency
+		if limit <= 0 {
+			limit = len(phase)
+		}
+		slots := make(chan struct{}, limit)
+		var wg sync.WaitGroup
+		for _, i := range phase {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				select {
+				case slots <- struct{}{}:
+					defer func() { <-slots }()
+				case <-ctx.Done():
+					outcomes[i].Err = a.objectError(objects[i], ctx.Err())
+					return
+				}
+				outcomes[i].Err = a.applyObject(ctx, objects[i], opts...)
+			}()
+		}
+		wg.Wait()
+		for _, i := range phase {
+			failed = failed || outcomes[i].Err != nil
+		}
+	}
+	return outcomes
+}
+
+// applyObject applies the object and, in case of a CustomResourceDefinition, waits until it's established
+func (a *OrderedApplier) applyObject(ctx context.Context, obj client.Object, opts ...SSAApplyObjectOption) error {
+	if err := a.client.ApplyObject(ctx, obj, opts...); err != nil {
+		// the error is already wrapped by ApplyObject the same way as objectError does it
+		return err
+	}
+	if obj.GetObjectKind().GroupVersionKind().GroupKind() != crdGroupKind {
+		return nil
+	}
+	waiter := NewReadinessWaiter(a.client.Client, ReadinessTimeout(a.crdTimeout), ReadinessPollInterval(a.pollInterval))
+	if err := waiter.WaitForReady(ctx, obj); err != nil {
+		return a.objectError(obj, fmt.Errorf("the CustomResourceDefinition is not established: %w", err))
+	}
+	return nil
+}
+
+// objectError wraps the error of the given object the same way as ApplyObject does, so all the outcomes
+// refer to the objects in the same form
+func (a *OrderedApplier) objectError(obj client.Object, err error) error {
+	obj = obj.DeepCopyObject().(client.Object)
+	_ = EnsureGVK(obj, a.client.Client.Scheme()) // best effort, the type is used if the kind can't be determined
+	return composeError(obj, err)
+}
+
+func isEstablished(crd *unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	for _, condition := range conditions {
+		if c, ok := condition.(map[string]interface{}); ok && c["type"] == "Established" && c["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestPlanApply(t *testing.T) {
+	// given
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "deployment", Namespace: "ns"}}
+	roleBinding := &rbac.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "rb", Namespace: "ns"}}
+	role := &rbac.Role{ObjectMeta: metav1.ObjectMeta{Name: "role", Namespace: "ns"}}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "ns"}}
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "sa", Namespace: "ns"}}
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}}
+	crd := newCRD("foos.example.com", true)
+	clusterRole := &rbac.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "cluster-role"}}
+
+	// when
+	plan, err := client.PlanApply([]runtimeclient.Object{deployment, roleBinding, role, cm, sa, ns, crd, clusterRole}, scheme.Scheme)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, [][]runtimeclient.Object{
+		{ns},
+		{crd},
+		{sa},
+		{role, clusterRole},
+		{roleBinding},
+		{cm},
+		{deployment},
+	}, plan)
+}
+
+func TestOrderedApplier(t *testing.T) {
+	// given
+	newObjects := func() []runtimeclient.Object {
+		return []runtimeclient.Object{
+			&rbac.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "rb", Namespace: "ns"}, RoleRef: rbac.RoleRef{Kind: "Role", Name: "role"}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm-1", Namespace: "ns"}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm-2", Namespace: "ns"}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm-3", Namespace: "ns"}},
+			newCRD("foos.example.com", true),
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}},
+		}
+	}
+
+	t.Run("applies the objects phase by phase", func(t *testing.T) {
+		// given
+		cl, acl := NewTestSsaApplyClient(t)
+		var lock sync.Mutex
+		var applied []string
+		var inFlight, maxInFlight int32
+		cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+			current := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				observed := atomic.LoadInt32(&maxInFlight)
+				if current <= observed || atomic.CompareAndSwapInt32(&maxInFlight, observed, current) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			lock.Lock()
+			applied = append(applied, obj.GetObjectKind().GroupVersionKind().Kind)
+			lock.Unlock()
+			return test.Patch(ctx, cl, obj, patch, opts...)
+		}
+		objects := newObjects()
+
+		// when
+		outcomes := client.NewOrderedApplier(acl, client.MaxConcurrency(2)).Apply(context.TODO(), objects)
+
+		// then
+		require.NoError(t, outcomes.Err())
+		require.Len(t, outcomes, len(objects))
+		for i, outcome := range outcomes {
+			assert.Same(t, objects[i], outcome.Object)
+		}
+		assert.Equal(t, []string{"Namespace", "CustomResourceDefinition", "RoleBinding", "ConfigMap", "ConfigMap", "ConfigMap"}, applied)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&maxInFlight))
+	})
+
+	t.Run("the same object given more than once is applied once", func(t *testing.T) {
+		// given
+		cl, acl := NewTestSsaApplyClient(t)
+		var patched int32
+		cl.MockPatch = func(_ context.Context, obj runtimeclient.Object, _ runtimeclient.Patch, _ ...runtimeclient.PatchOption) error {
+			atomic.AddInt32(&patched, 1)
+			return fmt.Errorf("some error with %s", obj.GetName())
+		}
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "ns"}}
+		objects := []runtimeclient.Object{cm, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "ns"}}, cm}
+
+		// when
+		outcomes := client.NewOrderedApplier(acl).Apply(context.TODO(), objects)
+
+		// then
+		require.Len(t, outcomes, 3)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&patched))
+		require.ErrorContains(t, outcomes[0].Err, "some error with cm")
+		require.ErrorContains(t, outcomes[1].Err, "some error with other")
+		assert.Equal(t, outcomes[0].Err, outcomes[2].Err)
+		assert.Same(t, cm, outcomes[2].Object)
+	})
+
+	t.Run("the following phases are not applied after a failure", func(t *testing.T) {
+		// given
+		cl, acl := NewTestSsaApplyClient(t)
+		cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+			if obj.GetName() == "rb" {
+				return fmt.Errorf("some error")
+			}
+			return test.Patch(ctx, cl, obj, patch, opts...)
+		}
+
+		// when
+		outcomes := client.NewOrderedApplier(acl).Apply(context.TODO(), newObjects())
+
+		// then
+		failed := outcomes.Failed()
+		require.Len(t, failed, 4)
+		assert.EqualError(t, failed[0].Err, "unable to patch 'rbac.authorization.k8s.io/v1, Kind=RoleBinding' called 'rb' in namespace 'ns': some error")
+		assert.EqualError(t, failed[1].Err, "unable to patch '/v1, Kind=ConfigMap' called 'cm-1' in namespace 'ns': not applied because of the failures in the previous phase")
+		require.ErrorContains(t, outcomes.Err(), "unable to apply 4 of 6 object(s): ")
+		err := cl.Get(context.TODO(), runtimeclient.ObjectKey{Name: "ns"}, &corev1.Namespace{})
+		require.NoError(t, err)
+	})
+
+	t.Run("nothing is applied when the apply can't be planned", func(t *testing.T) {
+		// given
+		_, acl := NewTestSsaApplyClient(t)
+		objects := []runtimeclient.Object{
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "ns"}},
+			&unstructured.Unstructured{},
+		}
+
+		// when
+		outcomes := client.NewOrderedApplier(acl).Apply(context.TODO(), objects)
+
+		// then
+		require.Len(t, outcomes.Failed(), 2)
+		require.ErrorContains(t, outcomes[0].Err, "unable to patch '/v1, Kind=ConfigMap' called 'cm' in namespace 'ns': unable to plan the apply: ")
+	})
+
+	t.Run("waits for the CRDs to be established", func(t *testing.T) {
+		// given
+		_, acl := NewTestSsaApplyClient(t)
+		objects := []runtimeclient.Object{
+			newCRD("foos.example.com", false),
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "ns"}},
+		}
+
+		// when
+		outcomes := client.NewOrderedApplier(acl, client.CRDEstablishedTimeout(10*time.Millisecond)).Apply(context.TODO(), objects)
+
+		// then
+		require.Len(t, outcomes.Failed(), 2)
+		require.ErrorContains(t, outcomes[0].Err, "the CustomResourceDefinition is not established")
+		require.ErrorContains(t, outcomes[1].Err, "not applied because of the failures in the previous phase")
+	})
+}
+
+func newCRD(name string, established bool) *unstructured.Unstructured {
+	crd := &unstructured.Unstructured{}
+	crd.SetAPIVersion("apiextensions.k8s.io/v1")
+	crd.SetKind("CustomResourceDefinition")
+	crd.SetName(name)
+	if established {
+		crd.Object["status"] = map[string]interface{}{
+			"conditions": []interface{}{map[string]interface{}{"type": "Established", "status": "True"}},
+		}
+	}
+	return crd
+}
@@ -0,0 +1,312 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AppliedObjectsAnnotationKey the key of the annotation of the parent object keeping the inventory of the applied objects
+const AppliedObjectsAnnotationKey = "toolchain.dev.openshift.com/applied-objects"
+
+// ObjectRef identifies an applied object in the inventory
+type ObjectRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// NewObjectRef returns the reference to the given object, which is expected to have its GVK set
+func NewObjectRef(obj client.Object) ObjectRef {
+	apiVersion, kind := obj.GetObjectKind().GroupVersionKind().ToAPIVersionAndKind()
+	return ObjectRef{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+	}
+}
+
+// GroupKind returns the group and kind of the referenced object
+func (r ObjectRef) GroupKind() schema.GroupKind {
+	return schema.FromAPIVersionAndKind(r.APIVersion, r.Kind).GroupKind()
+}
+
+// String returns a human-readable representation of the reference
+func (r ObjectRef) String() string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s %s", r.Kind, r.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", r.Kind, r.Namespace, r.Name)
+}
+
+// key identifies the object regardless of its API version
+func (r ObjectRef) key() string {
+	return fmt.Sprintf("%s/%s/%s", r.GroupKind(), r.Namespace, r.Name)
+}
+
+// PruneFailure is an object that failed to be pruned
+type PruneFailure struct {
+	Object ObjectRef
+	Err    error
+}
+
+// String returns a human-readable representation of the failure
+func (f PruneFailure) String() string {
+	return fmt.Sprintf("%s: %s", f.Object, f.Err)
+}
+
+// PruneError is returned when some of the objects failed to be pruned
+type PruneError struct {
+	Failures []PruneFailure
+}
+
+func (e *PruneError) Error() string {
+	failures := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		failures[i] = failure.String()
+	}
+	return fmt.Sprintf("unable to prune %d object(s): %v", len(e.Failures), failures)
+}
+
+// Pruner deletes the objects that were applied before but are no longer in the desired set of objects.
+// The inventory of the applied objects is kept in the AppliedObjectsAnnotationKey annotation of the parent object
+// (eg. the NSTemplateSet the objects are applied for). Only the objects owned by the parent are deleted, ie. the objects
+// having an owner reference to the parent or the ownership labels set by the OwnedByLabels option.
+type Pruner struct {
+	client            client.Client
+	parent            client.Object
+	prunable          map[schema.GroupKind]bool
+	propagationPolicy metav1.DeletionPropagation
+	ownerLabels       map[string]string
+}
+
+// PruneOption an option to configure the Pruner
+type PruneOption func(*Pruner)
+
+// AllowPruning allows pruning the objects of the given kinds. The objects of the other kinds are never deleted
+// by the pruner - they are only removed from the inventory when they are no longer desired.
+func AllowPruning(groupKinds ...schema.GroupKind) PruneOption {
+	return func(p *Pruner) {
+		for _, groupKind := range groupKinds {
+			p.prunable[groupKind] = true
+		}
+	}
+}
+
+// WithPropagationPolicy sets the propagation policy used when deleting the pruned objects (default: `Background`)
+func WithPropagationPolicy(policy metav1.DeletionPropagation) PruneOption {
+	return func(p *Pruner) {
+		p.propagationPolicy = policy
+	}
+}
+
+// OwnedByLabels makes the pruner treat the objects having all the given labels as owned by the parent, even if they don't
+// have any owner reference to it (eg. the objects applied with the labels by the ApplyClient)
+func OwnedByLabels(labels map[string]string) PruneOption {
+	return func(p *Pruner) {
+		p.ownerLabels = labels
+	}
+}
+
+// NewPruner returns a pruner keeping the inventory in the given parent object
+func NewPruner(cl client.Client, parent client.Object, opts ...PruneOption) *Pruner {
+	p := &Pruner{
+		client:            cl,
+		parent:            parent,
+		prunable:          map[schema.GroupKind]bool{},
+		propagationPolicy: metav1.DeletePropagationBackground,
+	}
+	for _, apply := range opts {
+		apply(p)
+	}
+	return p
+}
+
+// Prune deletes the objects from the inventory that are not in the given desired objects and that are allowed to be pruned,
+// and then stores the desired objects as the new inventory in the parent object. The objects that failed to be deleted
+// are kept in the inventory, so they are pruned next time, and they are reported in a PruneError. The objects that are
+// not owned by the parent or that don't exist anymore are only removed from the inventory. It returns the references
+// to the objects that were actually deleted.
+func (p *Pruner) Prune(ctx context.Context, desired []client.Object) ([]ObjectRef, error) {
+	previous, err := p.inventory()
+	if err != nil {
+		return nil, err
+	}
+	inventory := make([]ObjectRef, 0, len(desired))
+	desiredKeys := map[string]bool{}
+	for _, obj := range desired {
+		obj = obj.DeepCopyObject().(client.Object)
+		if err := EnsureGVK(obj, p.client.Scheme()); err != nil {
+			return nil, errors.Wrapf(err, "unable to determine the kind of the object '%s'", obj.GetName())
+		}
+		ref := NewObjectRef(obj)
+		if !desiredKeys[ref.key()] {
+			desiredKeys[ref.key()] = true
+			inventory = append(inventory, ref)
+		}
+	}
+
+	var pruned []ObjectRef
+	var failures []PruneFailure
+	for _, ref := range previous {
+		if desiredKeys[ref.key()] {
+			continue
+		}
+		if !p.prunable[ref.GroupKind()] {
+			log.Info("the object is no longer desired but its kind is not allowed to be pruned", "object", ref.String())
+			continue
+		}
+		outcome, err := p.delete(ctx, ref)
+		if err != nil {
+			failures = append(failures, PruneFailure{Object: ref, Err: err})
+			inventory = append(inventory, ref)
+			continue
+		}
+		switch outcome {
+		case pruneNotOwned:
+			log.Info("the object is no longer desired but it is not owned by the parent, removing it from the inventory only", "object", ref.String())
+			continue
+		case pruneAlreadyGone:
+			log.Info("the object that is no longer desired doesn't exist anymore, removing it from the inventory", "object", ref.String())
+			continue
+		}
+		log.Info("pruned the object that is no longer desired", "object", ref.String())
+		pruned = append(pruned, ref)
+	}
+
+	if err := p.saveInventory(ctx, inventory); err != nil {
+		return pruned, err
+	}
+	if len(failures) > 0 {
+		return pruned, &PruneError{Failures: failures}
+	}
+	return pruned, nil
+}
+
+// pruneOutcome is what happened to the object that was no longer desired
+type pruneOutcome int
+
+const (
+	pruneDeleted pruneOutcome = iota
+	// pruneAlreadyGone the object didn't exist anymore, so there was nothing to delete
+	pruneAlreadyGone
+	// pruneNotOwned the object exists but it's not owned by the parent, so it was not deleted
+	pruneNotOwned
+)
+
+// delete deletes the referenced object if it is owned by the parent
+func (p *Pruner) delete(ctx context.Context, ref ObjectRef) (pruneOutcome, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(ref.APIVersion)
+	obj.SetKind(ref.Kind)
+	if err := p.client.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			return pruneAlreadyGone, nil
+		}
+		return pruneDeleted, err
+	}
+	if !p.ownedByParent(obj) {
+		return pruneNotOwned, nil
+	}
+	// make sure that the checked object is deleted and not the one possibly recreated in the meantime
+	uid := obj.GetUID()
+	if err := p.client.Delete(ctx, obj, client.PropagationPolicy(p.propagationPolicy), client.Preconditions{UID: &uid}); err != nil {
+		if apierrors.IsNotFound(err) {
+			return pruneAlreadyGone, nil
+		}
+		return pruneDeleted, err
+	}
+	return pruneDeleted, nil
+}
+
+// ownedByParent returns true if the object has an owner reference to the parent or all the ownership labels
+func (p *Pruner) ownedByParent(obj client.Object) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if ref.UID == p.parent.GetUID() && ref.Name == p.parent.GetName() {
+			return true
+		}
+	}
+	if len(p.ownerLabels) == 0 {
+		return false
+	}
+	for key, value := range p.ownerLabels {
+		if actual, found := obj.GetLabels()[key]; !found || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// inventory returns the references to the objects stored in the annotation of the parent object
+func (p *Pruner) inventory() ([]ObjectRef, error) {
+	value, found := p.parent.GetAnnotations()[AppliedObjectsAnnotationKey]
+	if !found || value == "" {
+		return nil, nil
+	}
+	var refs []ObjectRef
+	if err := json.Unmarshal([]byte(value), &refs); err != nil {
+		return nil, errors.Wrapf(err, "unable to read the inventory of the applied objects from the annotation %s", AppliedObjectsAnnotationKey)
+	}
+	return refs, nil
+}
+
+// saveInventory stores the given references in the annotation of the parent object (if they changed)
+func (p *Pruner) saveInventory(ctx context.Context, refs []ObjectRef) error {
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].key() < refs[j].key()
+	})
+	value, err := json.Marshal(refs)
+	if err != nil {
+		return err
+	}
+	if p.parent.GetAnnotations()[AppliedObjectsAnnotationKey] == string(value) {
+		return nil
+	}
+	patch := client.MergeFrom(p.parent.DeepCopyObject().(client.Object))
+	MergeAnnotations(p.parent, map[string]string{AppliedObjectsAnnotationKey: string(value)})
+	if err := p.client.Patch(ctx, p.parent, patch); err != nil {
+		return errors.Wrapf(err, "unable to store the inventory of the applied objects in '%s'", p.parent.GetName())
+	}
+	return nil
+}
+
+// ApplyAndPrune applies the objects the same way as Apply does and then prunes the objects that were applied before
+// but are no longer in the given objects. Nothing is pruned if the apply fails.
+func (c ApplyClient) ApplyAndPrune(ctx context.Context, toolchainObjects []client.Object, newLabels map[string]string, pruner *Pruner) (bool, error) {
+	events := newEventBatch(c.EventRecorder)
+	defer events.flush()
+	results, err := c.applyWithResults(ctx, toolchainObjects, newLabels, events)
+	if err != nil {
+		return false, err
+	}
+	createdOrUpdated := false
+	for _, result := range results {
+		createdOrUpdated = createdOrUpdated || result.GenerationChanged()
+	}
+	pruned, err := pruner.Prune(ctx, toolchainObjects)
+	events.addPruned(pruner.parent, pruned, err)
+	return createdOrUpdated || len(pruned) > 0, err
+}
+
+// ApplyAndPrune applies the objects the same way as Apply does and then prunes the objects that were applied before
+// but are no longer in the given objects. Nothing is pruned if the apply fails.
+func (c *SSAApplyClient) ApplyAndPrune(ctx context.Context, toolchainObjects []client.Object, pruner *Pruner, opts ...SSAApplyObjectOption) error {
+	events := newEventBatch(c.EventRecorder)
+	defer events.flush()
+	if _, err := applyAll(ctx, c, toolchainObjects, false, events, opts...); err != nil {
+		return err
+	}
+	pruned, err := pruner.Prune(ctx, toolchainObjects)
+	events.addPruned(pruner.parent, pruned, err)
+	return err
+}
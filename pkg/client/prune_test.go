@@ -0,0 +1,254 @@
+package client_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestPrune(t *testing.T) {
+	// given
+	configMapKind := schema.GroupKind{Kind: "ConfigMap"}
+	roleBindingKind := schema.GroupKind{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding"}
+	newObjects := func() []runtimeclient.Object {
+		return []runtimeclient.Object{
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm-1", Namespace: "ns"}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm-2", Namespace: "ns"}},
+			&rbac.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "rb", Namespace: "ns"}, RoleRef: rbac.RoleRef{Kind: "Role", Name: "role"}},
+			&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "sa", Namespace: "ns"}},
+		}
+	}
+	assertExists := func(t *testing.T, cl runtimeclient.Client, obj runtimeclient.Object) {
+		require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(obj), obj))
+	}
+	assertNotExists := func(t *testing.T, cl runtimeclient.Client, obj runtimeclient.Object) {
+		err := cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(obj), obj)
+		assert.True(t, errors.IsNotFound(err), "expected %s to be deleted but got: %v", obj.GetName(), err)
+	}
+
+	t.Run("with SSA client", func(t *testing.T) {
+		// given
+		parent := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "parent", Namespace: "ns", UID: "abc"}}
+		cl, acl := NewTestSsaApplyClient(t, parent)
+		objects := newObjects()
+		require.NoError(t, acl.ApplyAndPrune(context.TODO(), objects, client.NewPruner(cl, parent), client.SetOwnerReference(parent)))
+		inCluster := &corev1.ConfigMap{}
+		require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(parent), inCluster))
+		assert.JSONEq(t, `[
+			{"apiVersion":"v1","kind":"ConfigMap","namespace":"ns","name":"cm-1"},
+			{"apiVersion":"v1","kind":"ConfigMap","namespace":"ns","name":"cm-2"},
+			{"apiVersion":"rbac.authorization.k8s.io/v1","kind":"RoleBinding","namespace":"ns","name":"rb"},
+			{"apiVersion":"v1","kind":"ServiceAccount","namespace":"ns","name":"sa"}
+		]`, inCluster.Annotations[client.AppliedObjectsAnnotationKey])
+
+		t.Run("prunes the objects that are no longer desired", func(t *testing.T) {
+			// given
+			var policies []metav1.DeletionPropagation
+			cl.MockDelete = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.DeleteOption) error {
+				policies = append(policies, *(&runtimeclient.DeleteOptions{}).ApplyOptions(opts).PropagationPolicy)
+				return cl.Client.Delete(ctx, obj, opts...)
+			}
+			defer func() { cl.MockDelete = nil }()
+			pruner := client.NewPruner(cl, parent, client.AllowPruning(configMapKind, roleBindingKind), client.WithPropagationPolicy(metav1.DeletePropagationForeground))
+
+			// when
+			err := acl.ApplyAndPrune(context.TODO(), objects[:1], pruner)
+
+			// then
+			require.NoError(t, err)
+			assertExists(t, cl, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm-1", Namespace: "ns"}})
+			assertNotExists(t, cl, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm-2", Namespace: "ns"}})
+			assertNotExists(t, cl, &rbac.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "rb", Namespace: "ns"}})
+			// the ServiceAccounts are not allowed to be pruned
+			assertExists(t, cl, &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "sa", Namespace: "ns"}})
+			assert.Equal(t, []metav1.DeletionPropagation{metav1.DeletePropagationForeground, metav1.DeletePropagationForeground}, policies)
+			require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(parent), inCluster))
+			assert.JSONEq(t, `[{"apiVersion":"v1","kind":"ConfigMap","namespace":"ns","name":"cm-1"}]`,
+				inCluster.Annotations[client.AppliedObjectsAnnotationKey])
+		})
+	})
+
+	t.Run("with legacy client", func(t *testing.T) {
+		// given
+		addToScheme(t)
+		parent := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "parent", Namespace: "ns", UID: "abc"}}
+		cl := test.NewFakeClient(t, parent)
+		acl := client.NewApplyClient(cl)
+		ownerLabels := map[string]string{"toolchain.dev.openshift.com/owner": "parent"}
+		objects := newObjects()
+		_, err := acl.ApplyAndPrune(context.TODO(), objects, ownerLabels, client.NewPruner(cl, parent))
+		require.NoError(t, err)
+
+		t.Run("nothing to prune", func(t *testing.T) {
+			// when
+			changed, err := acl.ApplyAndPrune(context.TODO(), newObjects(), ownerLabels, client.NewPruner(cl, parent, client.AllowPruning(configMapKind), client.OwnedByLabels(ownerLabels)))
+
+			// then
+			require.NoError(t, err)
+			assert.False(t, changed)
+		})
+
+		t.Run("prunes the objects that are no longer desired", func(t *testing.T) {
+			// when
+			changed, err := acl.ApplyAndPrune(context.TODO(), newObjects()[1:], ownerLabels, client.NewPruner(cl, parent, client.AllowPruning(configMapKind), client.OwnedByLabels(ownerLabels)))
+
+			// then
+			require.NoError(t, err)
+			assert.True(t, changed)
+			assertNotExists(t, cl, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm-1", Namespace: "ns"}})
+			assertExists(t, cl, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm-2", Namespace: "ns"}})
+		})
+	})
+
+	t.Run("objects not owned by the parent are only removed from the inventory", func(t *testing.T) {
+		// given
+		parent := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "parent", Namespace: "ns", UID: "abc"}}
+		otherOwner := metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: "other", UID: "def"}
+		notOwned := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm-2", Namespace: "ns", OwnerReferences: []metav1.OwnerReference{otherOwner}}}
+		cl, acl := NewTestSsaApplyClient(t, parent, notOwned)
+		require.NoError(t, acl.ApplyAndPrune(context.TODO(), newObjects()[:1], client.NewPruner(cl, parent), client.SetOwnerReference(parent)))
+		// the inventory refers to an object owned by someone else (eg. recreated after it was applied)
+		parent.Annotations[client.AppliedObjectsAnnotationKey] = `[
+			{"apiVersion":"v1","kind":"ConfigMap","namespace":"ns","name":"cm-1"},
+			{"apiVersion":"v1","kind":"ConfigMap","namespace":"ns","name":"cm-2"}
+		]`
+		var deleted []string
+		cl.MockDelete = func(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.DeleteOption) error {
+			deleted = append(deleted, obj.GetName())
+			return cl.Client.Delete(ctx, obj, opts...)
+		}
+
+		// when
+		pruned, err := client.NewPruner(cl, parent, client.AllowPruning(configMapKind)).Prune(context.TODO(), nil)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []client.ObjectRef{{APIVersion: "v1", Kind: "ConfigMap", Namespace: "ns", Name: "cm-1"}}, pruned)
+		assert.Equal(t, []string{"cm-1"}, deleted)
+		assertExists(t, cl, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm-2", Namespace: "ns"}})
+		inCluster := &corev1.ConfigMap{}
+		require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(parent), inCluster))
+		assert.JSONEq(t, `[]`, inCluster.Annotations[client.AppliedObjectsAnnotationKey])
+	})
+
+	t.Run("objects that don't exist anymore are only removed from the inventory", func(t *testing.T) {
+		// given
+		addToScheme(t)
+		parent := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "parent", Namespace: "ns", UID: "abc"}}
+		ownerLabels := map[string]string{"toolchain.dev.openshift.com/owner": "parent"}
+		cl := test.NewFakeClient(t, parent)
+		acl := client.NewApplyClient(cl)
+		_, err := acl.ApplyAndPrune(context.TODO(), newObjects()[:2], ownerLabels, client.NewPruner(cl, parent))
+		require.NoError(t, err)
+		require.NoError(t, cl.Delete(context.TODO(), &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm-1", Namespace: "ns"}}))
+
+		// when
+		changed, err := acl.ApplyAndPrune(context.TODO(), newObjects()[1:2], ownerLabels,
+			client.NewPruner(cl, parent, client.AllowPruning(configMapKind), client.OwnedByLabels(ownerLabels)))
+
+		// then
+		require.NoError(t, err)
+		assert.False(t, changed)
+		inCluster := &corev1.ConfigMap{}
+		require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(parent), inCluster))
+		assert.JSONEq(t, `[{"apiVersion":"v1","kind":"ConfigMap","namespace":"ns","name":"cm-2"}]`,
+			inCluster.Annotations[client.AppliedObjectsAnnotationKey])
+	})
+
+	t.Run("objects with the ownership labels are pruned", func(t *testing.T) {
+		// given
+		parent := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "parent", Namespace: "ns", UID: "abc"}}
+		ownerLabels := map[string]string{"toolchain.dev.openshift.com/owner": "parent"}
+		cl, acl := NewTestSsaApplyClient(t, parent)
+		require.NoError(t, acl.ApplyAndPrune(context.TODO(), newObjects()[:2], client.NewPruner(cl, parent), client.EnsureLabels(ownerLabels)))
+
+		t.Run("not without the option", func(t *testing.T) {
+			// when
+			pruned, err := client.NewPruner(cl, parent, client.AllowPruning(configMapKind)).Prune(context.TODO(), newObjects()[1:2])
+
+			// then
+			require.NoError(t, err)
+			assert.Empty(t, pruned)
+			assertExists(t, cl, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm-1", Namespace: "ns"}})
+		})
+
+		t.Run("with the option", func(t *testing.T) {
+			// given
+			require.NoError(t, acl.ApplyAndPrune(context.TODO(), newObjects()[:2], client.NewPruner(cl, parent), client.EnsureLabels(ownerLabels)))
+
+			// when
+			pruned, err := client.NewPruner(cl, parent, client.AllowPruning(configMapKind), client.OwnedByLabels(ownerLabels)).Prune(context.TODO(), newObjects()[1:2])
+
+			// then
+			require.NoError(t, err)
+			assert.Len(t, pruned, 1)
+			assertNotExists(t, cl, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm-1", Namespace: "ns"}})
+		})
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		t.Run("objects failed to be deleted are kept in the inventory", func(t *testing.T) {
+			// given
+			parent := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "parent", Namespace: "ns", UID: "abc"}}
+			cl, acl := NewTestSsaApplyClient(t, parent)
+			require.NoError(t, acl.ApplyAndPrune(context.TODO(), newObjects(), client.NewPruner(cl, parent), client.SetOwnerReference(parent)))
+			cl.MockDelete = func(_ context.Context, _ runtimeclient.Object, _ ...runtimeclient.DeleteOption) error {
+				return fmt.Errorf("some error")
+			}
+
+			// when
+			pruned, err := client.NewPruner(cl, parent, client.AllowPruning(configMapKind)).Prune(context.TODO(), newObjects()[2:])
+
+			// then
+			require.EqualError(t, err, "unable to prune 2 object(s): [ConfigMap ns/cm-1: some error ConfigMap ns/cm-2: some error]")
+			assert.Empty(t, pruned)
+			inCluster := &corev1.ConfigMap{}
+			require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(parent), inCluster))
+			assert.Contains(t, inCluster.Annotations[client.AppliedObjectsAnnotationKey], `"name":"cm-1"`)
+			assert.Contains(t, inCluster.Annotations[client.AppliedObjectsAnnotationKey], `"name":"cm-2"`)
+		})
+
+		t.Run("nothing is pruned when apply fails", func(t *testing.T) {
+			// given
+			parent := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "parent", Namespace: "ns", UID: "abc"}}
+			cl, acl := NewTestSsaApplyClient(t, parent)
+			cl.MockPatch = func(_ context.Context, _ runtimeclient.Object, _ runtimeclient.Patch, _ ...runtimeclient.PatchOption) error {
+				return fmt.Errorf("some error")
+			}
+
+			// when
+			err := acl.ApplyAndPrune(context.TODO(), newObjects(), client.NewPruner(cl, parent))
+
+			// then
+			require.ErrorContains(t, err, "some error")
+			assert.Empty(t, parent.Annotations)
+		})
+
+		t.Run("invalid inventory", func(t *testing.T) {
+			// given
+			parent := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Name:        "parent",
+				Namespace:   "ns",
+				Annotations: map[string]string{client.AppliedObjectsAnnotationKey: "not json"},
+			}}
+			cl := test.NewFakeClient(t, parent)
+
+			// when
+			_, err := client.NewPruner(cl, parent).Prune(context.TODO(), newObjects())
+
+			// then
+			require.ErrorContains(t, err, "unable to read the inventory of the applied objects from the annotation "+client.AppliedObjectsAnnotationKey)
+		})
+	})
+}
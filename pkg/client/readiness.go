@@ -0,0 +1,273 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ReadinessCheck checks if the given object is ready. When the object is not ready yet, it returns false together with
+// the reason. It returns an error when the object will never become ready (eg. a failed Job).
+type ReadinessCheck func(obj *unstructured.Unstructured) (bool, string, error)
+
+// readinessCheck is the ReadinessCheck that can use the context of the wait (eg. to read other objects)
+type readinessCheck func(ctx context.Context, obj *unstructured.Unstructured) (bool, string, error)
+
+// serviceAccountGroupKind is the kind of the objects checked by WithServiceAccountTokenCheck
+var serviceAccountGroupKind = schema.GroupKind{Kind: "ServiceAccount"}
+
+// defaultReadinessChecks are the readiness checks of the built-in kinds. The objects of the kinds without any check
+// are ready as soon as they exist.
+var defaultReadinessChecks = map[schema.GroupKind]ReadinessCheck{
+	{Group: "apps", Kind: "Deployment"}: deploymentReady,
+	{Kind: "Namespace"}:                 namespaceReady,
+	crdGroupKind:                        crdReady,
+	{Group: "batch", Kind: "Job"}:       jobReady,
+}
+
+// PendingObject is an object that is not ready yet
+type PendingObject struct {
+	ObjectRef
+	Reason string
+}
+
+// NotReadyError is returned when some of the objects didn't become ready in time
+type NotReadyError struct {
+	Pending []PendingObject
+	Err     error
+}
+
+func (e *NotReadyError) Error() string {
+	pending := make([]string, len(e.Pending))
+	for i, obj := range e.Pending {
+		pending[i] = fmt.Sprintf("%s: %s", obj.ObjectRef, obj.Reason)
+	}
+	return fmt.Sprintf("%d object(s) not ready: %s: %s", len(e.Pending), strings.Join(pending, "; "), e.Err)
+}
+
+func (e *NotReadyError) Unwrap() error {
+	return e.Err
+}
+
+// ReadinessWaiter waits until the objects are ready, using the readiness checks registered for their kinds
+type ReadinessWaiter struct {
+	client       client.Client
+	checks       map[schema.GroupKind]readinessCheck
+	timeout      time.Duration
+	pollInterval time.Duration
+}
+
+// ReadinessOption an option to configure the ReadinessWaiter
+type ReadinessOption func(*ReadinessWaiter)
+
+// WithReadinessCheck registers the readiness check for the objects of the given kind (regardless of their API version).
+// It replaces the built-in check if there is one.
+func WithReadinessCheck(groupKind schema.GroupKind, check ReadinessCheck) ReadinessOption {
+	return func(w *ReadinessWaiter) {
+		w.checks[groupKind] = withoutContext(check)
+	}
+}
+
+// WithServiceAccountTokenCheck registers the readiness check of the ServiceAccounts, which are ready when their token
+// is present, ie. when there is a Secret of the `kubernetes.io/service-account-token` type annotated with the name
+// of the ServiceAccount and with the token populated by the token controller. The check is not registered by default,
+// because the token Secrets are not created automatically since Kubernetes 1.24, so it should be used only when
+// the token Secrets are applied together with the ServiceAccounts.
+func WithServiceAccountTokenCheck() ReadinessOption {
+	return func(w *ReadinessWaiter) {
+		w.checks[serviceAccountGroupKind] = w.serviceAccountTokenReady
+	}
+}
+
+// ReadinessTimeout sets how long to wait for the objects to become ready (default: `2m`)
+func ReadinessTimeout(timeout time.Duration) ReadinessOption {
+	return func(w *ReadinessWaiter) {
+		w.timeout = timeout
+	}
+}
+
+// ReadinessPollInterval sets how often the objects are checked (default: `1s`)
+func ReadinessPollInterval(interval time.Duration) ReadinessOption {
+	return func(w *ReadinessWaiter) {
+		w.pollInterval = interval
+	}
+}
+
+// NewReadinessWaiter returns a ReadinessWaiter with the built-in readiness checks of the Deployments, Namespaces,
+// CustomResourceDefinitions and Jobs (the check of the ServiceAccount tokens can be enabled by WithServiceAccountTokenCheck)
+func NewReadinessWaiter(cl client.Client, opts ...ReadinessOption) *ReadinessWaiter {
+	w := &ReadinessWaiter{
+		client:       cl,
+		checks:       make(map[schema.GroupKind]readinessCheck, len(defaultReadinessChecks)),
+		timeout:      2 * time.Minute,
+		pollInterval: time.Second,
+	}
+	for groupKind, check := range defaultReadinessChecks {
+		w.checks[groupKind] = withoutContext(check)
+	}
+	for _, apply := range opts {
+		apply(w)
+	}
+	return w
+}
+
+// WaitForReady waits until all the given objects are ready. When the timeout is reached or the context is cancelled,
+// it returns a NotReadyError listing the objects that are still pending. When an object will never become ready,
+// it returns the error of its readiness check right away.
+func (w *ReadinessWaiter) WaitForReady(ctx context.Context, objects ...client.Object) error {
+	pending := make([]PendingObject, 0, len(objects))
+	for _, obj := range objects {
+		obj = obj.DeepCopyObject().(client.Object)
+		if err := EnsureGVK(obj, w.client.Scheme()); err != nil {
+			return errors.Wrapf(err, "unable to determine the kind of the object '%s'", obj.GetName())
+		}
+		pending = append(pending, PendingObject{ObjectRef: NewObjectRef(obj), Reason: "not checked yet"})
+	}
+
+	err := wait.PollUntilContextTimeout(ctx, w.pollInterval, w.timeout, true, func(ctx context.Context) (bool, error) {
+		stillPending := pending[:0]
+		for _, obj := range pending {
+			ready, reason, err := w.isReady(ctx, obj.ObjectRef)
+			if err != nil {
+				return false, err
+			}
+			if !ready {
+				obj.Reason = reason
+				stillPending = append(stillPending, obj)
+			}
+		}
+		pending = stillPending
+		return len(pending) == 0, nil
+	})
+	if err != nil && len(pending) > 0 && wait.Interrupted(err) {
+		return &NotReadyError{Pending: pending, Err: err}
+	}
+	return err
+}
+
+func (w *ReadinessWaiter) isReady(ctx context.Context, ref ObjectRef) (bool, string, error) {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(ref.APIVersion)
+	obj.SetKind(ref.Kind)
+	if err := w.client.Get(ctx, client.ObjectKey{Namespace: ref.Namespace, Name: ref.Name}, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, "not found", nil
+		}
+		// the error might be transient, so let's try again later
+		return false, err.Error(), nil
+	}
+	if obj.GetDeletionTimestamp() != nil {
+		return false, "being deleted", nil
+	}
+	check, found := w.checks[ref.GroupKind()]
+	if !found {
+		return true, "", nil
+	}
+	ready, reason, err := check(ctx, obj)
+	if err != nil {
+		return false, "", errors.Wrapf(err, "%s will not become ready", ref)
+	}
+	return ready, reason, nil
+}
+
+func withoutContext(check ReadinessCheck) readinessCheck {
+	return func(_ context.Context, obj *unstructured.Unstructured) (bool, string, error) {
+		return check(obj)
+	}
+}
+
+func (w *ReadinessWaiter) serviceAccountTokenReady(ctx context.Context, obj *unstructured.Unstructured) (bool, string, error) {
+	secrets := &corev1.SecretList{}
+	if err := w.client.List(ctx, secrets, client.InNamespace(obj.GetNamespace())); err != nil {
+		// the error might be transient, so let's try again later
+		return false, err.Error(), nil
+	}
+	for _, secret := range secrets.Items {
+		if secret.Type != corev1.SecretTypeServiceAccountToken || secret.Annotations[corev1.ServiceAccountNameKey] != obj.GetName() {
+			continue
+		}
+		// the Secret of the previous ServiceAccount with the same name is not valid
+		if uid := secret.Annotations[corev1.ServiceAccountUIDKey]; uid != "" && uid != string(obj.GetUID()) {
+			continue
+		}
+		if len(secret.Data[corev1.ServiceAccountTokenKey]) > 0 {
+			return true, "", nil
+		}
+	}
+	return false, "no token secret yet", nil
+}
+
+func deploymentReady(obj *unstructured.Unstructured) (bool, string, error) {
+	deployment := &appsv1.Deployment{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, deployment); err != nil {
+		return false, "", err
+	}
+	if deployment.Status.ObservedGeneration < deployment.Generation {
+		return false, "the latest generation is not observed yet", nil
+	}
+	for _, condition := range deployment.Status.Conditions {
+		if condition.Type == appsv1.DeploymentProgressing && condition.Reason == "ProgressDeadlineExceeded" {
+			return false, "", fmt.Errorf("the rollout exceeded its progress deadline")
+		}
+	}
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	status := deployment.Status
+	switch {
+	case status.UpdatedReplicas < replicas:
+		return false, fmt.Sprintf("%d of %d replicas updated", status.UpdatedReplicas, replicas), nil
+	case status.Replicas > status.UpdatedReplicas:
+		return false, fmt.Sprintf("%d old replicas pending termination", status.Replicas-status.UpdatedReplicas), nil
+	case status.AvailableReplicas < status.UpdatedReplicas:
+		return false, fmt.Sprintf("%d of %d updated replicas available", status.AvailableReplicas, status.UpdatedReplicas), nil
+	}
+	return true, "", nil
+}
+
+func namespaceReady(obj *unstructured.Unstructured) (bool, string, error) {
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	if phase != string(corev1.NamespaceActive) {
+		return false, fmt.Sprintf("the phase is '%s'", phase), nil
+	}
+	return true, "", nil
+}
+
+func crdReady(obj *unstructured.Unstructured) (bool, string, error) {
+	if !isEstablished(obj) {
+		return false, "not established", nil
+	}
+	return true, "", nil
+}
+
+func jobReady(obj *unstructured.Unstructured) (bool, string, error) {
+	job := &batchv1.Job{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, job); err != nil {
+		return false, "", err
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return true, "", nil
+		case batchv1.JobFailed:
+			return false, "", fmt.Errorf("the job failed: %s", condition.Message)
+		}
+	}
+	return false, "not complete", nil
+}
@@ -0,0 +1,261 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/utils/ptr"
+)
+
+func TestWaitForReady(t *testing.T) {
+	// given
+	readyDeployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "ready", Namespace: "ns", Generation: 2},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](2)},
+		Status:     appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2},
+	}
+	rollingDeployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "rolling", Namespace: "ns", Generation: 2},
+		Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](3)},
+		Status:     appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 1, AvailableReplicas: 3},
+	}
+	activeNs := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "active"}, Status: corev1.NamespaceStatus{Phase: corev1.NamespaceActive}}
+	terminatingNs := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "terminating"}, Status: corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating}}
+	completeJob := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "complete", Namespace: "ns"},
+		Status:     batchv1.JobStatus{Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}},
+	}
+	failedJob := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "failed", Namespace: "ns"},
+		Status:     batchv1.JobStatus{Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "backoff limit exceeded"}}},
+	}
+	// no token secrets are created for the ServiceAccounts since Kubernetes 1.24
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "sa", Namespace: "ns"}}
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "ns"}}
+	cl := test.NewFakeClient(t, readyDeployment, rollingDeployment, activeNs, terminatingNs, completeJob, failedJob, sa, cm,
+		newCRD("established.example.com", true), newCRD("not-established.example.com", false))
+	newWaiter := func(opts ...client.ReadinessOption) *client.ReadinessWaiter {
+		return client.NewReadinessWaiter(cl, append([]client.ReadinessOption{client.ReadinessTimeout(50 * time.Millisecond), client.ReadinessPollInterval(10 * time.Millisecond)}, opts...)...)
+	}
+
+	t.Run("all objects ready", func(t *testing.T) {
+		// when
+		err := newWaiter().WaitForReady(context.TODO(), readyDeployment, activeNs, completeJob, sa, cm, newCRD("established.example.com", true))
+
+		// then
+		require.NoError(t, err)
+	})
+
+	t.Run("timeout with pending objects", func(t *testing.T) {
+		// when
+		err := newWaiter().WaitForReady(context.TODO(), readyDeployment, rollingDeployment, terminatingNs,
+			newCRD("not-established.example.com", false), &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "missing", Namespace: "ns"}})
+
+		// then
+		notReady := &client.NotReadyError{}
+		require.ErrorAs(t, err, &notReady)
+		require.Len(t, notReady.Pending, 4)
+		assert.Equal(t, "Deployment ns/rolling", notReady.Pending[0].String())
+		assert.Equal(t, "1 of 3 replicas updated", notReady.Pending[0].Reason)
+		assert.Equal(t, "the phase is 'Terminating'", notReady.Pending[1].Reason)
+		assert.Equal(t, "not established", notReady.Pending[2].Reason)
+		assert.Equal(t, "not found", notReady.Pending[3].Reason)
+		assert.ErrorContains(t, err, "4 object(s) not ready: Deployment ns/rolling: 1 of 3 replicas updated; Namespace terminating: the phase is 'Terminating'; ")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("cancelled context", func(t *testing.T) {
+		// given
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+
+		// when
+		err := newWaiter(client.ReadinessTimeout(time.Minute)).WaitForReady(ctx, rollingDeployment)
+
+		// then
+		require.ErrorIs(t, err, context.Canceled)
+		assert.ErrorContains(t, err, "1 object(s) not ready: Deployment ns/rolling: ")
+	})
+
+	t.Run("object never becoming ready", func(t *testing.T) {
+		// when
+		err := newWaiter(client.ReadinessTimeout(time.Minute)).WaitForReady(context.TODO(), completeJob, failedJob)
+
+		// then
+		require.EqualError(t, err, "Job ns/failed will not become ready: the job failed: backoff limit exceeded")
+	})
+
+	t.Run("custom readiness check", func(t *testing.T) {
+		// given
+		configMapKind := schema.GroupKind{Kind: "ConfigMap"}
+
+		t.Run("ready", func(t *testing.T) {
+			// given
+			check := func(obj *unstructured.Unstructured) (bool, string, error) {
+				return obj.GetName() == "cm", "", nil
+			}
+
+			// when
+			err := newWaiter(client.WithReadinessCheck(configMapKind, check)).WaitForReady(context.TODO(), cm)
+
+			// then
+			require.NoError(t, err)
+		})
+
+		t.Run("failing", func(t *testing.T) {
+			// given
+			check := func(_ *unstructured.Unstructured) (bool, string, error) {
+				return false, "", errors.New("some error")
+			}
+
+			// when
+			err := newWaiter(client.WithReadinessCheck(configMapKind, check)).WaitForReady(context.TODO(), cm)
+
+			// then
+			require.EqualError(t, err, "ConfigMap ns/cm will not become ready: some error")
+		})
+
+		t.Run("replacing the built-in check", func(t *testing.T) {
+			// given
+			check := func(_ *unstructured.Unstructured) (bool, string, error) {
+				return true, "", nil
+			}
+
+			// when
+			err := newWaiter(client.WithReadinessCheck(schema.GroupKind{Kind: "Namespace"}, check)).WaitForReady(context.TODO(), terminatingNs)
+
+			// then
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("service account token", func(t *testing.T) {
+		// given
+		withToken := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "with-token", Namespace: "ns", UID: "abc"}}
+		withStaleToken := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "with-stale-token", Namespace: "ns", UID: "def"}}
+		withEmptyToken := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "with-empty-token", Namespace: "ns"}}
+		tokenSecret := func(name, serviceAccount, uid, token string) *corev1.Secret {
+			return &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns", Annotations: map[string]string{
+					corev1.ServiceAccountNameKey: serviceAccount,
+					corev1.ServiceAccountUIDKey:  uid,
+				}},
+				Type: corev1.SecretTypeServiceAccountToken,
+				Data: map[string][]byte{corev1.ServiceAccountTokenKey: []byte(token)},
+			}
+		}
+		cl := test.NewFakeClient(t, withToken, withStaleToken, withEmptyToken, sa,
+			tokenSecret("with-token", "with-token", "abc", "token"),
+			tokenSecret("with-stale-token", "with-stale-token", "previous", "token"),
+			tokenSecret("with-empty-token", "with-empty-token", "", ""))
+		newWaiter := func(opts ...client.ReadinessOption) *client.ReadinessWaiter {
+			return client.NewReadinessWaiter(cl, append([]client.ReadinessOption{client.ReadinessTimeout(50 * time.Millisecond), client.ReadinessPollInterval(10 * time.Millisecond)}, opts...)...)
+		}
+
+		t.Run("not checked by default", func(t *testing.T) {
+			// when
+			err := newWaiter().WaitForReady(context.TODO(), sa, withEmptyToken)
+
+			// then
+			require.NoError(t, err)
+		})
+
+		t.Run("ready with the token", func(t *testing.T) {
+			// when
+			err := newWaiter(client.WithServiceAccountTokenCheck()).WaitForReady(context.TODO(), withToken)
+
+			// then
+			require.NoError(t, err)
+		})
+
+		t.Run("pending without the token", func(t *testing.T) {
+			// when
+			err := newWaiter(client.WithServiceAccountTokenCheck()).WaitForReady(context.TODO(), sa, withStaleToken, withEmptyToken)
+
+			// then
+			notReady := &client.NotReadyError{}
+			require.ErrorAs(t, err, &notReady)
+			require.Len(t, notReady.Pending, 3)
+			for _, pending := range notReady.Pending {
+				assert.Equal(t, "no token secret yet", pending.Reason)
+			}
+		})
+
+		t.Run("replaced by a custom check", func(t *testing.T) {
+			// given
+			check := func(_ *unstructured.Unstructured) (bool, string, error) {
+				return true, "", nil
+			}
+
+			// when
+			err := newWaiter(client.WithServiceAccountTokenCheck(), client.WithReadinessCheck(schema.GroupKind{Kind: "ServiceAccount"}, check)).
+				WaitForReady(context.TODO(), sa)
+
+			// then
+			require.NoError(t, err)
+		})
+	})
+
+	t.Run("deployment rollout", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			status appsv1.DeploymentStatus
+			reason string
+		}{
+			"generation not observed": {
+				status: appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+				reason: "the latest generation is not observed yet",
+			},
+			"old replicas": {
+				status: appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 1, AvailableReplicas: 1},
+				reason: "1 old replicas pending termination",
+			},
+			"not available": {
+				status: appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 1, UpdatedReplicas: 1},
+				reason: "0 of 1 updated replicas available",
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				// given
+				deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "deployment", Namespace: "ns", Generation: 2}, Status: tc.status}
+				cl := test.NewFakeClient(t, deployment)
+
+				// when
+				err := client.NewReadinessWaiter(cl, client.ReadinessTimeout(10*time.Millisecond)).WaitForReady(context.TODO(), deployment)
+
+				// then
+				notReady := &client.NotReadyError{}
+				require.ErrorAs(t, err, &notReady)
+				assert.Equal(t, tc.reason, notReady.Pending[0].Reason)
+			})
+		}
+
+		t.Run("progress deadline exceeded", func(t *testing.T) {
+			// given
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "deployment", Namespace: "ns"},
+				Status: appsv1.DeploymentStatus{Conditions: []appsv1.DeploymentCondition{
+					{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: "ProgressDeadlineExceeded"},
+				}},
+			}
+			cl := test.NewFakeClient(t, deployment)
+
+			// when
+			err := client.NewReadinessWaiter(cl).WaitForReady(context.TODO(), deployment)
+
+			// then
+			require.EqualError(t, err, "Deployment ns/deployment will not become ready: the rollout exceeded its progress deadline")
+		})
+	})
+}
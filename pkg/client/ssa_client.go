@@ -10,7 +10,9 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/csaupgrade"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
@@ -55,6 +57,16 @@ type SSAApplyClient struct {
 	//
 	// The user agent in the REST config is usually empty, so there's no need to set it here either in that case.
 	NonSSAFieldOwner string
+
+	// RetryBackoff is the backoff used when retrying the patches failed because of transient API errors
+	// (eg. timeouts or throttling) when the RetryOnTransientErrors option is used. If not set, retry.DefaultBackoff is used.
+	RetryBackoff wait.Backoff
+
+	// EventRecorder is used to emit the events about the created, updated and failed objects on their owner
+	// (set using the SetOwnerReference option) and about the pruned objects on the parent of the Pruner.
+	// No events are emitted if not set. Note that to tell the created and updated objects apart, an additional GET
+	// of the objects with an owner is needed when set.
+	EventRecorder record.EventRecorder
 }
 
 // NewSSAApplyClient creates a new SSAApplyClient from the provided parameters that will use the provided field owner
@@ -78,10 +90,13 @@ const (
 )
 
 type ssaApplyObjectConfiguration struct {
-	owner      metav1.Object
-	newLabels  map[string]string
-	skipIf     func(client.Object) bool
-	migrateSSA migrateSSA
+	owner               metav1.Object
+	newLabels           map[string]string
+	skipIf              func(client.Object) bool
+	migrateSSA          migrateSSA
+	noForce             bool
+	overridableManagers []string
+	retryTransient      bool
 }
 
 func newSSAApplyObjectConfiguration(options ...SSAApplyObjectOption) ssaApplyObjectConfiguration {
@@ -140,37 +155,138 @@ func (c *ssaApplyObjectConfiguration) Configure(obj client.Object, s *runtime.Sc
 			return err
 		}
 	}
-	MergeLabels(obj, c.newLabels)
+	if len(c.newLabels) > 0 {
+		MergeLabels(obj, c.newLabels)
+	}
 
 	return nil
 }
 
 // ApplyObject creates the object if is missing or update it if it already exists using an SSA patch.
 func (c *SSAApplyClient) ApplyObject(ctx context.Context, obj client.Object, options ...SSAApplyObjectOption) error {
+	events := newEventBatch(c.EventRecorder)
+	defer events.flush()
+	_, err := c.applyObject(ctx, obj, false, events, options...)
+	return err
+}
+
+// ApplyObjectWithResult applies the object the same way as ApplyObject does, but it returns the result describing
+// what happened to the object. To be able to compare the object before and after the apply, it requires an additional
+// GET of the object from the cluster.
+func (c *SSAApplyClient) ApplyObjectWithResult(ctx context.Context, obj client.Object, options ...SSAApplyObjectOption) (ApplyResult, error) {
+	events := newEventBatch(c.EventRecorder)
+	defer events.flush()
+	return c.applyObject(ctx, obj, true, events, options...)
+}
+
+func (c *SSAApplyClient) applyObject(ctx context.Context, obj client.Object, withResult bool, events *eventBatch, options ...SSAApplyObjectOption) (ApplyResult, error) {
 	config := newSSAApplyObjectConfiguration(options...)
+	// the result is needed to emit the event about the created or updated object
+	withResult = withResult || (events.enabled() && config.owner != nil)
+	result, err := c.createOrUpdate(ctx, obj, withResult, config)
+	events.addApplied(config.owner, obj, c.Client.Scheme(), result, err)
+	return result, err
+}
+
+func (c *SSAApplyClient) createOrUpdate(ctx context.Context, obj client.Object, withResult bool, config ssaApplyObjectConfiguration) (ApplyResult, error) {
 	if err := config.Configure(obj, c.Client.Scheme()); err != nil {
-		return composeError(obj, fmt.Errorf("failed to configure the apply function: %w", err))
+		return ApplyResult{}, composeError(obj, fmt.Errorf("failed to configure the apply function: %w", err))
 	}
 
 	if err := prepareForSSA(obj, c.Client.Scheme()); err != nil {
-		return composeError(obj, fmt.Errorf("failed to prepare the object for SSA: %w", err))
+		return ApplyResult{}, composeError(obj, fmt.Errorf("failed to prepare the object for SSA: %w", err))
 	}
 
 	if config.migrateSSA == migrateSSAYes || (config.migrateSSA == migrateSSANotSpecified && c.MigrateSSAByDefault) {
 		if err := c.migrateSSA(ctx, obj); err != nil {
-			return composeError(obj, err)
+			return ApplyResult{}, composeError(obj, err)
+		}
+	}
+
+	var live client.Object
+	if withResult {
+		live = obj.DeepCopyObject().(client.Object)
+		if err := c.Client.Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return ApplyResult{}, composeError(obj, fmt.Errorf("failed to get the object from the cluster: %w", err))
+			}
+			live = nil
 		}
 	}
 
 	if config.skipIf != nil && config.skipIf(obj) {
-		return nil
+		if !withResult {
+			return ApplyResult{}, nil
+		}
+		result, err := newUnchangedResult(obj, live, ApplySkipped, c.Client.Scheme())
+		if err != nil {
+			return result, composeError(obj, err)
+		}
+		return result, nil
 	}
 
-	if err := c.Client.Patch(ctx, obj, client.Apply, client.FieldOwner(c.FieldOwner), client.ForceOwnership); err != nil {
-		return composeError(obj, err)
+	if err := c.patch(ctx, obj, config); err != nil {
+		return ApplyResult{}, composeError(obj, err)
 	}
 
-	return nil
+	if !withResult {
+		return ApplyResult{}, nil
+	}
+	result, err := newApplyResult(live, obj, c.Client.Scheme())
+	if err != nil {
+		return result, composeError(obj, err)
+	}
+	return result, nil
+}
+
+// DiffObject returns the changes that ApplyObject called with the same options would make, without writing anything to the cluster.
+// It issues a server-side dry-run apply (with the same field ownership handling as ApplyObject, so the conflicts are reported
+// as a ConflictError when NoForceOwnership is used) and compares the result with the live object. The given object is not modified.
+// The managed fields are not migrated and the objects matching the SkipIf option are reported without any changes.
+func (c *SSAApplyClient) DiffObject(ctx context.Context, obj client.Object, options ...SSAApplyObjectOption) (ObjectDiff, error) {
+	config := newSSAApplyObjectConfiguration(options...)
+	desired := obj.DeepCopyObject().(client.Object)
+	if err := config.Configure(desired, c.Client.Scheme()); err != nil {
+		return ObjectDiff{}, composeError(desired, fmt.Errorf("failed to configure the apply function: %w", err))
+	}
+	if err := prepareForSSA(desired, c.Client.Scheme()); err != nil {
+		return ObjectDiff{}, composeError(desired, fmt.Errorf("failed to prepare the object for SSA: %w", err))
+	}
+
+	live := desired.DeepCopyObject().(client.Object)
+	if err := c.Client.Get(ctx, client.ObjectKeyFromObject(desired), live); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ObjectDiff{}, composeError(desired, fmt.Errorf("failed to get the object from the cluster: %w", err))
+		}
+		live = nil
+	}
+	if config.skipIf != nil && config.skipIf(desired) {
+		if live == nil {
+			return ObjectDiff{GroupVersionKind: desired.GetObjectKind().GroupVersionKind(), Namespace: desired.GetNamespace(), Name: desired.GetName()}, nil
+		}
+		return DiffObjects(live, live)
+	}
+
+	gvk := desired.GetObjectKind().GroupVersionKind()
+	if err := c.patch(ctx, desired, config, client.DryRunAll); err != nil {
+		return ObjectDiff{}, composeError(desired, err)
+	}
+	// the GVK is dropped when the response is decoded into a typed object
+	desired.GetObjectKind().SetGroupVersionKind(gvk)
+	return DiffObjects(live, desired)
+}
+
+// Diff is a utility function that just calls `DiffObject` in a loop on all the supplied objects.
+func (c *SSAApplyClient) Diff(ctx context.Context, toolchainObjects []client.Object, opts ...SSAApplyObjectOption) ([]ObjectDiff, error) {
+	diffs := make([]ObjectDiff, 0, len(toolchainObjects))
+	for _, toolchainObject := range toolchainObjects {
+		diff, err := c.DiffObject(ctx, toolchainObject, opts...)
+		if err != nil {
+			return diffs, err
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, nil
 }
 
 func (c *SSAApplyClient) migrateSSA(ctx context.Context, obj client.Object) error {
@@ -183,13 +299,7 @@ func (c *SSAApplyClient) migrateSSA(ctx context.Context, obj client.Object) erro
 	}
 
 	if orig != nil {
-		oldFieldOwner := c.NonSSAFieldOwner
-		if len(oldFieldOwner) == 0 {
-			// this is how the kubernetes api server determines the default owner from the user agent
-			// The default user agent has the form of "name-of-binary/version information etc.".
-			// The owner is the first part of the UA unless explicitly specified in the request URI.
-			oldFieldOwner = strings.Split(rest.DefaultKubernetesUserAgent(), "/")[0]
-		}
+		oldFieldOwner := c.nonSSAFieldOwner()
 		if isSsaMigrationNeeded(orig, oldFieldOwner) {
 			if err := migrateToSSA(ctx, c.Client, orig, oldFieldOwner, c.FieldOwner); err != nil {
 				return fmt.Errorf("failed to migrate the managed fields: %w", err)
@@ -199,6 +309,17 @@ func (c *SSAApplyClient) migrateSSA(ctx context.Context, obj client.Object) erro
 	return nil
 }
 
+// nonSSAFieldOwner returns the field owner of the changes made using the normal CRUD operations
+func (c *SSAApplyClient) nonSSAFieldOwner() string {
+	if len(c.NonSSAFieldOwner) > 0 {
+		return c.NonSSAFieldOwner
+	}
+	// this is how the kubernetes api server determines the default owner from the user agent
+	// The default user agent has the form of "name-of-binary/version information etc.".
+	// The owner is the first part of the UA unless explicitly specified in the request URI.
+	return strings.Split(rest.DefaultKubernetesUserAgent(), "/")[0]
+}
+
 func composeError(obj client.Object, err error) error {
 	message := "unable to patch '%s' called '%s' in namespace '%s': %w"
 	if !obj.GetObjectKind().GroupVersionKind().Empty() {
@@ -241,14 +362,39 @@ func (c *SSAApplyClient) Apply(ctx context.Context, toolchainObjects []client.Ob
 	return ApplyAll(ctx, c, toolchainObjects, opts...)
 }
 
+// ApplyWithResults is a utility function that just calls `ApplyObjectWithResult` in a loop on all the supplied objects.
+// It returns the results of the objects applied so far (in the same order as the objects were given).
+func (c *SSAApplyClient) ApplyWithResults(ctx context.Context, toolchainObjects []client.Object, opts ...SSAApplyObjectOption) ([]ApplyResult, error) {
+	events := newEventBatch(c.EventRecorder)
+	defer events.flush()
+	return applyAll(ctx, c, toolchainObjects, true, events, opts...)
+}
+
 // ApplyAll is a generic version of c.Apply that can accept a slice of anything that implements client.Object.
 func ApplyAll[T client.Object](ctx context.Context, cl *SSAApplyClient, toolchainObjects []T, opts ...SSAApplyObjectOption) error {
+	events := newEventBatch(cl.EventRecorder)
+	defer events.flush()
+	_, err := applyAll(ctx, cl, toolchainObjects, false, events, opts...)
+	return err
+}
+
+// applyAll applies the objects one by one and stops at the first failure. The events are only collected in the given batch,
+// so they can be aggregated.
+func applyAll[T client.Object](ctx context.Context, cl *SSAApplyClient, toolchainObjects []T, withResults bool, events *eventBatch, opts ...SSAApplyObjectOption) ([]ApplyResult, error) {
+	var results []ApplyResult
+	if withResults {
+		results = make([]ApplyResult, 0, len(toolchainObjects))
+	}
 	for _, toolchainObject := range toolchainObjects {
-		if err := cl.ApplyObject(ctx, toolchainObject, opts...); err != nil {
-			return err
+		result, err := cl.applyObject(ctx, toolchainObject, withResults, events, opts...)
+		if err != nil {
+			return results, err
+		}
+		if withResults {
+			results = append(results, result)
 		}
 	}
-	return nil
+	return results, nil
 }
 
 func isSsaMigrationNeeded(obj client.Object, expectedOwner string) bool {
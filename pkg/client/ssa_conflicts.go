@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FieldConflict is a field of the applied object that is owned by another field manager
+type FieldConflict struct {
+	// Field is the path of the conflicting field, eg. `.spec.replicas`
+	Field string
+	// Manager is the field manager currently owning the field
+	Manager string
+}
+
+// ConflictError is returned when the object applied without forcing the ownership contains fields owned by other
+// field managers that are not allowed to be overridden
+type ConflictError struct {
+	Object    ObjectRef
+	Conflicts []FieldConflict
+	Err       error
+}
+
+func (e *ConflictError) Error() string {
+	conflicts := make([]string, len(e.Conflicts))
+	for i, conflict := range e.Conflicts {
+		conflicts[i] = fmt.Sprintf("%s (managed by '%s')", conflict.Field, conflict.Manager)
+	}
+	return fmt.Sprintf("%s has %d field(s) owned by other managers: %s", e.Object, len(e.Conflicts), strings.Join(conflicts, ", "))
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+// NoForceOwnership makes the apply not to take over the fields owned by other field managers (by default, the ownership
+// is forced). When some of the applied fields are owned by other managers, the apply fails with a ConflictError, unless
+// all the conflicting managers are in the given list of managers that are allowed to be overridden - in that case,
+// the apply is repeated with the ownership forced.
+func NoForceOwnership(overridableManagers ...string) SSAApplyObjectOption {
+	return func(config *ssaApplyObjectConfiguration) {
+		config.noForce = true
+		config.overridableManagers = overridableManagers
+	}
+}
+
+// RetryOnTransientErrors makes the apply retry the patches failed because of the transient API errors (eg. timeouts
+// or throttling) using the RetryBackoff of the client. By default, the patch is not retried.
+func RetryOnTransientErrors() SSAApplyObjectOption {
+	return func(config *ssaApplyObjectConfiguration) {
+		config.retryTransient = true
+	}
+}
+
+// patch applies the object, retrying on the transient API errors if configured so. The extra options (eg. the dry run) are added to every patch request.
+func (c *SSAApplyClient) patch(ctx context.Context, obj client.Object, config ssaApplyObjectConfiguration, extraOpts ...client.PatchOption) error {
+	backoff := wait.Backoff{Steps: 1}
+	if config.retryTransient {
+		backoff = c.RetryBackoff
+		if backoff.Steps == 0 {
+			backoff = retry.DefaultBackoff
+		}
+	}
+	force := !config.noForce
+	return retry.OnError(backoff, isTransient, func() error {
+		// don't retry when the caller is no longer interested in the result
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		opts := append([]client.PatchOption{client.FieldOwner(c.FieldOwner)}, extraOpts...)
+		if force {
+			opts = append(opts, client.ForceOwnership)
+		}
+		err := c.Client.Patch(ctx, obj, client.Apply, opts...)
+		if force || err == nil {
+			return err
+		}
+		conflicts := fieldConflicts(err)
+		if len(conflicts) == 0 {
+			return err
+		}
+		for _, conflict := range conflicts {
+			if !slices.Contains(config.overridableManagers, conflict.Manager) {
+				return &ConflictError{Object: NewObjectRef(obj), Conflicts: conflicts, Err: err}
+			}
+		}
+		log.Info("overriding the fields owned by other managers", "object", NewObjectRef(obj).String(), "conflicts", conflicts)
+		force = true
+		return c.Client.Patch(ctx, obj, client.Apply, append(opts, client.ForceOwnership)...)
+	})
+}
+
+// fieldConflicts returns the conflicting fields reported by the API server in the given error
+func fieldConflicts(err error) []FieldConflict {
+	statusErr := &apierrors.StatusError{}
+	if !apierrors.IsConflict(err) || !errors.As(err, &statusErr) || statusErr.ErrStatus.Details == nil {
+		return nil
+	}
+	var conflicts []FieldConflict
+	for _, cause := range statusErr.ErrStatus.Details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+		conflicts = append(conflicts, FieldConflict{Field: cause.Field, Manager: conflictingManager(cause.Message)})
+	}
+	return conflicts
+}
+
+// conflictingManager extracts the manager from the message of the conflict cause, which has the form of
+// `conflict with "manager"`, optionally followed by the subresource, API version and time of the change.
+func conflictingManager(message string) string {
+	quoted := strings.TrimPrefix(message, "conflict with ")
+	if manager, err := strconv.QuotedPrefix(quoted); err == nil {
+		if unquoted, err := strconv.Unquote(manager); err == nil {
+			return unquoted
+		}
+	}
+	return quoted
+}
+
+// isTransient returns true if the error is a transient API error and the request can be retried
+func isTransient(err error) bool {
+	return apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsInternalError(err)
+}
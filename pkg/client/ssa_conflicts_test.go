@@ -0,0 +1,203 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestSsaApplyConflicts(t *testing.T) {
+	// given
+	newConfigMap := func() *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "ns"},
+			Data:       map[string]string{"key": "value"},
+		}
+	}
+	conflict := errors.NewApplyConflict([]metav1.StatusCause{
+		{Type: metav1.CauseTypeFieldManagerConflict, Field: ".data.key", Message: `conflict with "kubectl-edit" using v1`},
+		{Type: metav1.CauseTypeFieldManagerConflict, Field: ".metadata.labels.app", Message: `conflict with "other-operator"`},
+	}, "Apply failed with 2 conflicts")
+	// mockConflicts returns the conflict error unless the ownership is forced and records whether the ownership was forced
+	mockConflicts := func(cl *test.FakeClient) *[]bool {
+		forced := &[]bool{}
+		cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+			force := (&runtimeclient.PatchOptions{}).ApplyOptions(opts).Force
+			*forced = append(*forced, force != nil && *force)
+			if force == nil || !*force {
+				return conflict
+			}
+			return test.Patch(ctx, cl, obj, patch, opts...)
+		}
+		return forced
+	}
+
+	t.Run("forces the ownership by default", func(t *testing.T) {
+		// given
+		cl, acl := NewTestSsaApplyClient(t)
+		forced := mockConflicts(cl)
+
+		// when
+		err := acl.ApplyObject(context.TODO(), newConfigMap())
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, []bool{true}, *forced)
+	})
+
+	t.Run("without forcing the ownership", func(t *testing.T) {
+		t.Run("no conflicts", func(t *testing.T) {
+			// given
+			cl, acl := NewTestSsaApplyClient(t)
+			var forced *bool
+			cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+				forced = (&runtimeclient.PatchOptions{}).ApplyOptions(opts).Force
+				return test.Patch(ctx, cl, obj, patch, opts...)
+			}
+
+			// when
+			err := acl.ApplyObject(context.TODO(), newConfigMap(), client.NoForceOwnership())
+
+			// then
+			require.NoError(t, err)
+			assert.Nil(t, forced)
+		})
+
+		t.Run("reports the conflicts", func(t *testing.T) {
+			// given
+			cl, acl := NewTestSsaApplyClient(t)
+			forced := mockConflicts(cl)
+
+			// when
+			err := acl.ApplyObject(context.TODO(), newConfigMap(), client.NoForceOwnership("kubectl-edit"))
+
+			// then
+			conflictErr := &client.ConflictError{}
+			require.ErrorAs(t, err, &conflictErr)
+			assert.Equal(t, []client.FieldConflict{
+				{Field: ".data.key", Manager: "kubectl-edit"},
+				{Field: ".metadata.labels.app", Manager: "other-operator"},
+			}, conflictErr.Conflicts)
+			assert.True(t, errors.IsConflict(err))
+			assert.EqualError(t, err, "unable to patch '/v1, Kind=ConfigMap' called 'cm' in namespace 'ns': ConfigMap ns/cm has 2 field(s) owned by other managers: "+
+				".data.key (managed by 'kubectl-edit'), .metadata.labels.app (managed by 'other-operator')")
+			assert.Equal(t, []bool{false}, *forced)
+		})
+
+		t.Run("overrides the allowed managers", func(t *testing.T) {
+			// given
+			cl, acl := NewTestSsaApplyClient(t)
+			forced := mockConflicts(cl)
+
+			// when
+			err := acl.ApplyObject(context.TODO(), newConfigMap(), client.NoForceOwnership("kubectl-edit", "other-operator"))
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, []bool{false, true}, *forced)
+			inCluster := &corev1.ConfigMap{}
+			require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(newConfigMap()), inCluster))
+			assert.Equal(t, "value", inCluster.Data["key"])
+		})
+	})
+
+	t.Run("does not retry by default", func(t *testing.T) {
+		// given
+		cl, acl := NewTestSsaApplyClient(t)
+		calls := 0
+		cl.MockPatch = func(_ context.Context, _ runtimeclient.Object, _ runtimeclient.Patch, _ ...runtimeclient.PatchOption) error {
+			calls++
+			return errors.NewTooManyRequests("slow down", 0)
+		}
+
+		// when
+		err := acl.ApplyObject(context.TODO(), newConfigMap())
+
+		// then
+		require.True(t, errors.IsTooManyRequests(err))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("retries on transient errors", func(t *testing.T) {
+		// given
+		cl, acl := NewTestSsaApplyClient(t)
+		acl.RetryBackoff = wait.Backoff{Steps: 3, Duration: time.Millisecond}
+		calls := 0
+		cl.MockPatch = func(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+			calls++
+			if calls < 3 {
+				return errors.NewTooManyRequests("slow down", 0)
+			}
+			return test.Patch(ctx, cl, obj, patch, opts...)
+		}
+
+		// when
+		err := acl.ApplyObject(context.TODO(), newConfigMap(), client.RetryOnTransientErrors())
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 3, calls)
+
+		t.Run("gives up after the configured steps", func(t *testing.T) {
+			// given
+			calls = 0
+			cl.MockPatch = func(_ context.Context, _ runtimeclient.Object, _ runtimeclient.Patch, _ ...runtimeclient.PatchOption) error {
+				calls++
+				return errors.NewServiceUnavailable("unavailable")
+			}
+
+			// when
+			err := acl.ApplyObject(context.TODO(), newConfigMap(), client.RetryOnTransientErrors())
+
+			// then
+			require.True(t, errors.IsServiceUnavailable(err))
+			assert.Equal(t, 3, calls)
+		})
+
+		t.Run("does not retry other errors", func(t *testing.T) {
+			// given
+			calls = 0
+			cl.MockPatch = func(_ context.Context, _ runtimeclient.Object, _ runtimeclient.Patch, _ ...runtimeclient.PatchOption) error {
+				calls++
+				return errors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "cm", nil)
+			}
+
+			// when
+			err := acl.ApplyObject(context.TODO(), newConfigMap(), client.RetryOnTransientErrors())
+
+			// then
+			require.True(t, errors.IsForbidden(err))
+			assert.Equal(t, 1, calls)
+		})
+
+		t.Run("stops retrying when the context is done", func(t *testing.T) {
+			// given
+			calls = 0
+			ctx, cancel := context.WithCancel(context.TODO())
+			defer cancel()
+			cl.MockPatch = func(_ context.Context, _ runtimeclient.Object, _ runtimeclient.Patch, _ ...runtimeclient.PatchOption) error {
+				calls++
+				cancel()
+				return errors.NewServiceUnavailable("unavailable")
+			}
+
+			// when
+			err := acl.ApplyObject(ctx, newConfigMap(), client.RetryOnTransientErrors())
+
+			// then
+			require.ErrorIs(t, err, context.Canceled)
+			assert.Equal(t, 1, calls)
+		})
+	})
+}
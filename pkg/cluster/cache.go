@@ -1,19 +1,65 @@
 package cluster
 
 import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	crcluster "sigs.k8s.io/controller-runtime/pkg/cluster"
 )
 
-var clusterCache = toolchainClusterClients{clusters: map[string]*CachedToolchainCluster{}}
+// clusterCache is the default cache used by the package-level functions such as GetHostCluster or GetMemberClusters.
+// It's the only cache reporting its size in the CacheSizeGauge metric.
+var clusterCache = newClusterCache(CacheSizeGauge)
 
-type toolchainClusterClients struct {
+// ClusterCache keeps the CachedToolchainClusters (indexed by their names) and is safe for concurrent use.
+// Multiple instances can be used in one process, e.g. when running several managers or services at the same time.
+type ClusterCache struct {
 	sync.RWMutex
 	clusters     map[string]*CachedToolchainCluster
 	refreshCache func()
+	sizeGauge    prometheus.Gauge
+
+	subscribersLock  sync.RWMutex
+	subscribers      map[int]ClusterEventHandler
+	nextSubscriberID int
+	// publishLock is held from the modification of the cache until the related events are published,
+	// so the subscribers receive the events in the same order as the modifications were done.
+	// It has to be acquired before the cache lock.
+	publishLock sync.Mutex
+}
+
+// NewClusterCache returns a new empty instance of the ClusterCache
+func NewClusterCache() *ClusterCache {
+	return newClusterCache(nil)
+}
+
+func newClusterCache(sizeGauge prometheus.Gauge) *ClusterCache {
+	return &ClusterCache{
+		clusters:  map[string]*CachedToolchainCluster{},
+		sizeGauge: sizeGauge,
+	}
+}
+
+// updateSizeGauge sets the current number of the clusters to the size gauge (if any). The caller has to hold the lock.
+func (c *ClusterCache) updateSizeGauge() {
+	if c.sizeGauge != nil {
+		c.sizeGauge.Set(float64(len(c.clusters)))
+	}
+}
+
+// DefaultClusterCache returns the cache instance that is used by the package-level functions
+// (GetCachedToolchainCluster, GetHostCluster, GetMemberClusters) and by the ToolchainClusterService
+// when it is created without an explicit cache
+func DefaultClusterCache() *ClusterCache {
+	return clusterCache
 }
 
 type Config struct {
@@ -35,6 +81,15 @@ type Config struct {
 	// Labels contains all the labels of the corresponding ToolchainCluster.
 	// They will be used for filtering ToolchainCluster's based on a given list of cluster-role labels.
 	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations contains all the annotations of the corresponding ToolchainCluster.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// TokenExpiry is the expiration time of the bearer token used for accessing the cluster (if known)
+	TokenExpiry *time.Time `json:"tokenExpiry,omitempty"`
+
+	// RateLimits are the client-side rate limits applied to the cached Client (but not to the RestConfig)
+	RateLimits RateLimits `json:"rateLimits,omitempty"`
 }
 
 // CachedToolchainCluster stores cluster client; cluster related info and previous health check probe results
@@ -44,21 +99,73 @@ type CachedToolchainCluster struct {
 	Client client.Client
 	// ClusterStatus is the cluster result as of the last health check probe.
 	ClusterStatus *toolchainv1alpha1.ToolchainClusterStatus
+	// Cluster is the controller-runtime Cluster backing the Client by an informer cache.
+	// It's set only when the service is created with the WithInformerCache option.
+	Cluster crcluster.Cluster
+	// Capacity is the last snapshot of the capacity and of the resource usage of the cluster.
+	// It's set only when the CapacityCollector is running.
+	Capacity *Capacity
+	// stopCluster stops the Cluster (if any)
+	stopCluster context.CancelFunc
+}
+
+// stop stops the informer cache backing the client (if any)
+func (c *CachedToolchainCluster) stop() {
+	if c.stopCluster != nil {
+		c.stopCluster()
+	}
 }
 
-func (c *toolchainClusterClients) addCachedToolchainCluster(cluster *CachedToolchainCluster) {
+func (c *ClusterCache) addCachedToolchainCluster(cluster *CachedToolchainCluster) {
+	c.publishLock.Lock()
+	defer c.publishLock.Unlock()
 	c.Lock()
-	defer c.Unlock()
+	previous := c.clusters[cluster.Name]
+	if previous != nil && cluster.Capacity == nil {
+		// the capacity is sampled independently of the changes of the ToolchainCluster, so keep the last snapshot.
+		// It's taken from the current entry under the lock, so the snapshot stored in the meantime is not lost.
+		cluster.Capacity = previous.Capacity
+	}
+	events := newAddedOrUpdatedEvents(previous, cluster)
 	c.clusters[cluster.Name] = cluster
+	c.updateSizeGauge()
+	c.Unlock()
+	if previous != nil && previous.Cluster != cluster.Cluster {
+		previous.stop()
+	}
+	c.publish(events...)
 }
 
-func (c *toolchainClusterClients) deleteCachedToolchainCluster(name string) {
+func (c *ClusterCache) deleteCachedToolchainCluster(name string) {
+	c.publishLock.Lock()
+	defer c.publishLock.Unlock()
 	c.Lock()
-	defer c.Unlock()
+	old, exists := c.clusters[name]
 	delete(c.clusters, name)
+	c.updateSizeGauge()
+	c.Unlock()
+	if exists {
+		old.stop()
+		c.publish(ClusterEvent{Type: ClusterRemoved, Old: old})
+	}
+}
+
+// setCapacity replaces the cached cluster with a copy having the given capacity snapshot, so the clusters that were already
+// returned by the cache are not modified. It returns false if there is no such cluster in the cache.
+func (c *ClusterCache) setCapacity(name string, capacity *Capacity) bool {
+	c.Lock()
+	defer c.Unlock()
+	cluster, exists := c.clusters[name]
+	if !exists {
+		return false
+	}
+	withCapacity := *cluster
+	withCapacity.Capacity = capacity
+	c.clusters[name] = &withCapacity
+	return true
 }
 
-func (c *toolchainClusterClients) getCachedToolchainCluster(name string, canRefreshCache bool) (*CachedToolchainCluster, bool) {
+func (c *ClusterCache) getCachedToolchainCluster(name string, canRefreshCache bool) (*CachedToolchainCluster, bool) {
 	c.RLock()
 	defer c.RUnlock()
 	_, ok := c.clusters[name]
@@ -79,11 +186,13 @@ var Ready Condition = func(cluster *CachedToolchainCluster) bool {
 	return IsReady(cluster.ClusterStatus)
 }
 
-func (c *toolchainClusterClients) getCachedToolchainClusters(conditions ...Condition) []*CachedToolchainCluster {
+func (c *ClusterCache) getCachedToolchainClusters(conditions ...Condition) []*CachedToolchainCluster {
 	c.RLock()
 	defer c.RUnlock()
 	return Filter(c.clusters, conditions...)
 }
+
+// Filter returns the clusters matching all the given conditions, sorted by their names
 func Filter(clusters map[string]*CachedToolchainCluster, conditions ...Condition) []*CachedToolchainCluster {
 	filteredClusters := make([]*CachedToolchainCluster, 0, len(clusters))
 clusters:
@@ -95,12 +204,103 @@ clusters:
 		}
 		filteredClusters = append(filteredClusters, cluster)
 	}
+	sort.Slice(filteredClusters, func(i, j int) bool {
+		return filteredClusters[i].Name < filteredClusters[j].Name
+	})
 	return filteredClusters
 }
 
 // GetCachedToolchainCluster returns a kube client for the cluster (with the given name) and info if the client exists
+func (c *ClusterCache) GetCachedToolchainCluster(name string) (*CachedToolchainCluster, bool) {
+	return c.getCachedToolchainCluster(name, true)
+}
+
+// GetHostCluster returns the kube client for the host cluster from the cache of the clusters
+// and info if such a client exists. See LookupHostCluster for the details on how the host cluster is selected.
+func (c *ClusterCache) GetHostCluster() (*CachedToolchainCluster, bool) {
+	host, err := c.LookupHostCluster()
+	if err != nil {
+		return nil, false
+	}
+	return host, true
+}
+
+// LookupHostCluster returns the host cluster from the cache of the clusters. The host cluster is the only cluster
+// having the Host role (see HasRole). For the backward compatibility with the ToolchainClusters that are not labeled,
+// if none of the clusters has the Host role but there is exactly one cluster in the cache, then this cluster is returned.
+// An error is returned when no host cluster is found or when more than one cluster matches. The cache is refreshed
+// only when the host cluster is missing in it (ie. the cache is empty or all the clusters have other roles).
+func (c *ClusterCache) LookupHostCluster() (*CachedToolchainCluster, error) {
+	host, missing, err := c.lookupHostCluster()
+	// the refresh doesn't help when the host can't be told apart from the other clusters
+	if missing && c.refreshCache != nil {
+		c.refreshCache()
+		host, _, err = c.lookupHostCluster()
+	}
+	return host, err
+}
+
+// lookupHostCluster returns the host cluster from the cache. When not found, it also says whether the host cluster
+// is missing in the cache, ie. whether the cache is empty or all the cached clusters have some other role.
+func (c *ClusterCache) lookupHostCluster() (*CachedToolchainCluster, bool, error) {
+	c.RLock()
+	defer c.RUnlock()
+	hosts := Filter(c.clusters, HasRole(Host))
+	if len(hosts) == 0 && len(c.clusters) == 1 {
+		hosts = Filter(c.clusters)
+	}
+	switch len(hosts) {
+	case 0:
+		missing := true
+		for _, cluster := range c.clusters {
+			missing = missing && hasAnyRole(cluster)
+		}
+		return nil, missing, fmt.Errorf("no host cluster found in the cache of %d cluster(s)", len(c.clusters))
+	case 1:
+		return hosts[0], false, nil
+	default:
+		names := make([]string, len(hosts))
+		for i, host := range hosts {
+			names[i] = host.Name
+		}
+		return nil, false, fmt.Errorf("multiple host clusters found in the cache: %s", strings.Join(names, ", "))
+	}
+}
+
+// hasAnyRole returns true if the cluster is labeled with any role
+func hasAnyRole(cluster *CachedToolchainCluster) bool {
+	if cluster.Config == nil {
+		return false
+	}
+	for key := range cluster.Labels {
+		if key == LabelType || strings.HasPrefix(key, labelClusterRolePrefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// GetClustersByRole returns the clusters from the cache that have the given role and match all the given conditions,
+// sorted by their names
+func (c *ClusterCache) GetClustersByRole(role Role, conditions ...Condition) []*CachedToolchainCluster {
+	return c.GetMemberClusters(append([]Condition{HasRole(role)}, conditions...)...)
+}
+
+// GetMemberClusters returns the kube clients for the member clusters from the cache of the clusters
+func (c *ClusterCache) GetMemberClusters(conditions ...Condition) []*CachedToolchainCluster {
+	clusters := c.getCachedToolchainClusters(conditions...)
+	if len(clusters) == 0 {
+		if c.refreshCache != nil {
+			c.refreshCache()
+		}
+		clusters = c.getCachedToolchainClusters(conditions...)
+	}
+	return clusters
+}
+
+// GetCachedToolchainCluster returns a kube client for the cluster (with the given name) from the default cache and info if the client exists
 func GetCachedToolchainCluster(name string) (*CachedToolchainCluster, bool) {
-	return clusterCache.getCachedToolchainCluster(name, true)
+	return clusterCache.GetCachedToolchainCluster(name)
 }
 
 // GetHostClusterFunc a func that returns the Host cluster from the cache,
@@ -110,20 +310,21 @@ type GetHostClusterFunc func() (*CachedToolchainCluster, bool)
 // HostCluster the func to retrieve the host cluster
 var HostCluster GetHostClusterFunc = GetHostCluster
 
-// GetHostCluster returns the kube client for the host cluster from the cache of the clusters
+// GetHostCluster returns the kube client for the host cluster from the default cache of the clusters
 // and info if such a client exists
 func GetHostCluster() (*CachedToolchainCluster, bool) {
-	clusters := clusterCache.getCachedToolchainClusters()
-	if len(clusters) == 0 {
-		if clusterCache.refreshCache != nil {
-			clusterCache.refreshCache()
-		}
-		clusters = clusterCache.getCachedToolchainClusters()
-		if len(clusters) == 0 {
-			return nil, false
-		}
-	}
-	return clusters[0], true
+	return clusterCache.GetHostCluster()
+}
+
+// LookupHostCluster returns the host cluster from the default cache of the clusters or an error if there is no such
+// cluster or if it's ambiguous
+func LookupHostCluster() (*CachedToolchainCluster, error) {
+	return clusterCache.LookupHostCluster()
+}
+
+// GetClustersByRole returns the clusters from the default cache that have the given role and match all the given conditions
+func GetClustersByRole(role Role, conditions ...Condition) []*CachedToolchainCluster {
+	return clusterCache.GetClustersByRole(role, conditions...)
 }
 
 // GetMemberClustersFunc a func that returns the member clusters from the cache
@@ -132,16 +333,9 @@ type GetMemberClustersFunc func(conditions ...Condition) []*CachedToolchainClust
 // MemberClusters the func to retrieve the member clusters
 var MemberClusters GetMemberClustersFunc = GetMemberClusters
 
-// GetMemberClusters returns the kube clients for the host clusters from the cache of the clusters
+// GetMemberClusters returns the kube clients for the member clusters from the default cache of the clusters
 func GetMemberClusters(conditions ...Condition) []*CachedToolchainCluster {
-	clusters := clusterCache.getCachedToolchainClusters(conditions...)
-	if len(clusters) == 0 {
-		if clusterCache.refreshCache != nil {
-			clusterCache.refreshCache()
-		}
-		clusters = clusterCache.getCachedToolchainClusters(conditions...)
-	}
-	return clusters
+	return clusterCache.GetMemberClusters(conditions...)
 }
 
 // Role defines the role of the cluster.
@@ -150,4 +344,22 @@ type Role string
 
 const (
 	Tenant Role = "tenant"
+	// Host is the role of the cluster the host operator is running in
+	Host Role = "host"
+	// Member is the role of the clusters the member operator is running in
+	Member Role = "member"
 )
+
+// HasRole checks that the cluster has the given role, ie. it has either the label returned by RoleLabel
+// or the "type" label with the role as the value
+func HasRole(role Role) Condition {
+	return func(cluster *CachedToolchainCluster) bool {
+		if cluster.Config == nil {
+			return false
+		}
+		if _, ok := cluster.Labels[RoleLabel(role)]; ok {
+			return true
+		}
+		return cluster.Labels[LabelType] == string(role)
+	}
+}
@@ -33,6 +33,39 @@ func TestAddCluster(t *testing.T) {
 	assert.Equal(t, cachedCluster, clusterCache.clusters["testCluster"])
 }
 
+func TestClusterCacheInstancesAreIndependent(t *testing.T) {
+	// given
+	defer resetClusterCache()
+	cache1 := NewClusterCache()
+	cache2 := NewClusterCache()
+	cluster1 := newTestCachedToolchainCluster(t, "cluster-1", ready)
+	cluster2 := newTestCachedToolchainCluster(t, "cluster-2", ready)
+	refreshed1, refreshed2 := false, false
+	cache1.refreshCache = func() {
+		refreshed1 = true
+	}
+	cache2.refreshCache = func() {
+		refreshed2 = true
+	}
+
+	// when
+	cache1.addCachedToolchainCluster(cluster1)
+	cache2.addCachedToolchainCluster(cluster2)
+
+	// then
+	assert.Equal(t, []*CachedToolchainCluster{cluster1}, cache1.GetMemberClusters())
+	assert.Equal(t, []*CachedToolchainCluster{cluster2}, cache2.GetMemberClusters())
+	host, ok := cache2.GetHostCluster()
+	assert.True(t, ok)
+	assert.Equal(t, cluster2, host)
+	assert.Empty(t, GetMemberClusters())
+
+	_, ok = cache1.GetCachedToolchainCluster("cluster-2")
+	assert.False(t, ok)
+	assert.True(t, refreshed1)
+	assert.False(t, refreshed2)
+}
+
 func TestGetCluster(t *testing.T) {
 	// given
 	defer resetClusterCache()
@@ -202,6 +235,100 @@ func TestGetClustersByType(t *testing.T) {
 			assert.Equal(t, host, cluster)
 		})
 
+		t.Run("found by role label among members", func(t *testing.T) {
+			// given
+			defer resetClusterCache()
+			host := newTestCachedToolchainCluster(t, "cluster-host", ready, withLabel(RoleLabel(Host), ""))
+			clusterCache.addCachedToolchainCluster(host)
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "cluster-a", ready, withLabel(RoleLabel(Tenant), "")))
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "cluster-z", ready))
+
+			for i := 0; i < 10; i++ {
+				//when
+				cluster, ok := GetHostCluster()
+
+				//then
+				assert.True(t, ok)
+				assert.Equal(t, host, cluster)
+			}
+		})
+
+		t.Run("found by type label", func(t *testing.T) {
+			// given
+			defer resetClusterCache()
+			host := newTestCachedToolchainCluster(t, "cluster-host", ready, withLabel(LabelType, "host"))
+			clusterCache.addCachedToolchainCluster(host)
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "cluster-1", ready, withLabel(LabelType, "member")))
+
+			//when
+			cluster, err := LookupHostCluster()
+
+			//then
+			require.NoError(t, err)
+			assert.Equal(t, host, cluster)
+		})
+
+		t.Run("error when no cluster is labeled as host and there are more clusters", func(t *testing.T) {
+			// given
+			defer resetClusterCache()
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "cluster-1", ready))
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "cluster-2", ready))
+			refreshed := 0
+			clusterCache.refreshCache = func() {
+				refreshed++
+			}
+
+			//when
+			cluster, err := LookupHostCluster()
+
+			//then
+			require.EqualError(t, err, "no host cluster found in the cache of 2 cluster(s)")
+			assert.Nil(t, cluster)
+			_, ok := GetHostCluster()
+			assert.False(t, ok)
+			assert.Zero(t, refreshed, "the unlabeled clusters are ambiguous, so the cache should not be refreshed")
+		})
+
+		t.Run("error when multiple clusters are labeled as host", func(t *testing.T) {
+			// given
+			defer resetClusterCache()
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "cluster-2", ready, withLabel(LabelType, "host")))
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "cluster-1", ready, withLabel(RoleLabel(Host), "")))
+			refreshed := 0
+			clusterCache.refreshCache = func() {
+				refreshed++
+			}
+
+			//when
+			cluster, err := LookupHostCluster()
+
+			//then
+			require.EqualError(t, err, "multiple host clusters found in the cache: cluster-1, cluster-2")
+			assert.Nil(t, cluster)
+			assert.Zero(t, refreshed)
+		})
+
+		t.Run("refreshed when only the clusters of the other roles are cached", func(t *testing.T) {
+			// given
+			defer resetClusterCache()
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member-1", ready, withLabel(RoleLabel(Tenant), "")))
+			clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member-2", ready, withLabel(LabelType, "member")))
+			host := newTestCachedToolchainCluster(t, "cluster-host", ready, withLabel(RoleLabel(Host), ""))
+			refreshed := 0
+			clusterCache.refreshCache = func() {
+				refreshed++
+				clusterCache.addCachedToolchainCluster(host)
+			}
+
+			//when
+			cluster, err := LookupHostCluster()
+
+			//then
+			require.NoError(t, err)
+			assert.Equal(t, host, cluster)
+			assert.Equal(t, 1, refreshed)
+		})
+
 		t.Run("found after refreshing the cache", func(t *testing.T) {
 			// given
 			defer resetClusterCache()
@@ -223,6 +350,52 @@ func TestGetClustersByType(t *testing.T) {
 	})
 }
 
+func TestGetClustersByRole(t *testing.T) {
+	// given
+	defer resetClusterCache()
+	host := newTestCachedToolchainCluster(t, "host", ready, withLabel(LabelType, "host"))
+	clusterCache.addCachedToolchainCluster(host)
+	tenant2 := newTestCachedToolchainCluster(t, "member-2", ready, withLabel(RoleLabel(Tenant), ""))
+	clusterCache.addCachedToolchainCluster(tenant2)
+	tenant1 := newTestCachedToolchainCluster(t, "member-1", ready, withLabel(RoleLabel(Tenant), ""))
+	clusterCache.addCachedToolchainCluster(tenant1)
+	tenant3 := newTestCachedToolchainCluster(t, "member-3", notReady, withLabel(RoleLabel(Tenant), ""))
+	clusterCache.addCachedToolchainCluster(tenant3)
+	clusterCache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member-4", ready))
+
+	t.Run("all tenants sorted by name", func(t *testing.T) {
+		// when
+		clusters := GetClustersByRole(Tenant)
+
+		// then
+		assert.Equal(t, []*CachedToolchainCluster{tenant1, tenant2, tenant3}, clusters)
+	})
+
+	t.Run("ready tenants", func(t *testing.T) {
+		// when
+		clusters := GetClustersByRole(Tenant, Ready)
+
+		// then
+		assert.Equal(t, []*CachedToolchainCluster{tenant1, tenant2}, clusters)
+	})
+
+	t.Run("host", func(t *testing.T) {
+		// when
+		clusters := GetClustersByRole(Host)
+
+		// then
+		assert.Equal(t, []*CachedToolchainCluster{host}, clusters)
+	})
+
+	t.Run("unknown role", func(t *testing.T) {
+		// when
+		clusters := GetClustersByRole(Role("unknown"))
+
+		// then
+		assert.Empty(t, clusters)
+	})
+}
+
 func TestGetClusterUsingDifferentKey(t *testing.T) {
 	// given
 	defer resetClusterCache()
@@ -412,6 +585,16 @@ var notReady clusterOption = func(c *CachedToolchainCluster) {
 	})
 }
 
+// withLabel an option to set the given label on the cluster
+func withLabel(key, value string) clusterOption {
+	return func(c *CachedToolchainCluster) {
+		if c.Labels == nil {
+			c.Labels = map[string]string{}
+		}
+		c.Labels[key] = value
+	}
+}
+
 func newTestCachedToolchainCluster(t *testing.T, name string, options ...clusterOption) *CachedToolchainCluster {
 	cl := test.NewFakeClient(t)
 	cachedCluster := &CachedToolchainCluster{
@@ -429,5 +612,12 @@ func newTestCachedToolchainCluster(t *testing.T, name string, options ...cluster
 }
 
 func resetClusterCache() {
-	clusterCache = toolchainClusterClients{clusters: map[string]*CachedToolchainCluster{}}
+	clusterCache.Lock()
+	defer clusterCache.Unlock()
+	clusterCache.clusters = map[string]*CachedToolchainCluster{}
+	clusterCache.updateSizeGauge()
+	clusterCache.refreshCache = nil
+	clusterCache.subscribersLock.Lock()
+	defer clusterCache.subscribersLock.Unlock()
+	clusterCache.subscribers = nil
 }
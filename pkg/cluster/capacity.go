@@ -0,0 +1,194 @@
+package cluster
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Capacity is a snapshot of the capacity and of the resource usage of a cluster
+type Capacity struct {
+	// AllocatableCPU is the sum of the allocatable CPU of all the schedulable nodes
+	AllocatableCPU resource.Quantity
+	// AllocatableMemory is the sum of the allocatable memory of all the schedulable nodes
+	AllocatableMemory resource.Quantity
+	// RequestedCPU is the sum of the CPU requested by all the pods that are not terminated
+	RequestedCPU resource.Quantity
+	// RequestedMemory is the sum of the memory requested by all the pods that are not terminated
+	RequestedMemory resource.Quantity
+	// Namespaces is the number of the namespaces in the cluster
+	Namespaces int
+	// SampledAt is the time when the snapshot was taken
+	SampledAt time.Time
+}
+
+// FreeCPUPercentage returns the percentage (0-100) of the allocatable CPU that is not requested by any pod
+func (c *Capacity) FreeCPUPercentage() float64 {
+	return freePercentage(c.AllocatableCPU, c.RequestedCPU)
+}
+
+// FreeMemoryPercentage returns the percentage (0-100) of the allocatable memory that is not requested by any pod
+func (c *Capacity) FreeMemoryPercentage() float64 {
+	return freePercentage(c.AllocatableMemory, c.RequestedMemory)
+}
+
+func freePercentage(allocatable, requested resource.Quantity) float64 {
+	if allocatable.IsZero() {
+		return 0
+	}
+	free := 100 * (1 - requested.AsApproximateFloat64()/allocatable.AsApproximateFloat64())
+	if free < 0 {
+		return 0
+	}
+	return free
+}
+
+// HasFreeCPU checks that the cluster has at least the given percentage (0-100) of the allocatable CPU not requested by any pod.
+// The clusters without any capacity snapshot don't match.
+func HasFreeCPU(percentage float64) Condition {
+	return func(cluster *CachedToolchainCluster) bool {
+		return cluster.Capacity != nil && cluster.Capacity.FreeCPUPercentage() >= percentage
+	}
+}
+
+// HasFreeMemory checks that the cluster has at least the given percentage (0-100) of the allocatable memory not requested by any pod.
+// The clusters without any capacity snapshot don't match.
+func HasFreeMemory(percentage float64) Condition {
+	return func(cluster *CachedToolchainCluster) bool {
+		return cluster.Capacity != nil && cluster.Capacity.FreeMemoryPercentage() >= percentage
+	}
+}
+
+// podsPageSize is the maximum number of the pods fetched by a single list request when sampling the capacity
+const podsPageSize = 500
+
+// DefaultCapacityInterval is the interval of the CapacityCollector used when the given interval is not positive
+const DefaultCapacityInterval = 5 * time.Minute
+
+// SampleCapacity takes a snapshot of the capacity and of the resource usage of the cluster accessed by the given reader
+func SampleCapacity(ctx context.Context, cl client.Reader) (*Capacity, error) {
+	capacity := &Capacity{SampledAt: time.Now()}
+
+	nodes := &corev1.NodeList{}
+	if err := cl.List(ctx, nodes); err != nil {
+		return nil, errors.Wrap(err, "unable to list the nodes")
+	}
+	for _, node := range nodes.Items {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		capacity.AllocatableCPU.Add(node.Status.Allocatable[corev1.ResourceCPU])
+		capacity.AllocatableMemory.Add(node.Status.Allocatable[corev1.ResourceMemory])
+	}
+
+	// there can be a lot of pods in the cluster, so they are listed in pages
+	pods := &corev1.PodList{}
+	for {
+		if err := cl.List(ctx, pods, client.Limit(podsPageSize), client.Continue(pods.Continue)); err != nil {
+			return nil, errors.Wrap(err, "unable to list the pods")
+		}
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+				continue
+			}
+			capacity.RequestedCPU.Add(podRequest(pod, corev1.ResourceCPU))
+			capacity.RequestedMemory.Add(podRequest(pod, corev1.ResourceMemory))
+		}
+		if pods.Continue == "" {
+			break
+		}
+	}
+
+	namespaces := &corev1.NamespaceList{}
+	if err := cl.List(ctx, namespaces); err != nil {
+		return nil, errors.Wrap(err, "unable to list the namespaces")
+	}
+	capacity.Namespaces = len(namespaces.Items)
+	return capacity, nil
+}
+
+// podRequest returns the amount of the given resource requested by the pod, the same way as the scheduler computes it:
+// the sum of the requests of the containers, or the highest request of an init container if it's higher, plus the pod overhead
+func podRequest(pod *corev1.Pod, name corev1.ResourceName) resource.Quantity {
+	request := resource.Quantity{}
+	for _, container := range pod.Spec.Containers {
+		request.Add(container.Resources.Requests[name])
+	}
+	for _, container := range pod.Spec.InitContainers {
+		if initRequest := container.Resources.Requests[name]; initRequest.Cmp(request) > 0 {
+			request = initRequest.DeepCopy()
+		}
+	}
+	if overhead, found := pod.Spec.Overhead[name]; found {
+		request.Add(overhead)
+	}
+	return request
+}
+
+// CapacityCollector periodically samples the capacity of the cached clusters and stores the snapshots in the cache
+// (see CachedToolchainCluster.Capacity), so they can be used by the placement decisions
+type CapacityCollector struct {
+	cache      *ClusterCache
+	log        logr.Logger
+	interval   time.Duration
+	conditions []Condition
+}
+
+// NewCapacityCollector returns a collector sampling the capacity of the clusters from the given cache that match all the given
+// conditions (eg. HasRole(Member)) every interval (DefaultCapacityInterval if the interval is not positive).
+// The collector implements the manager.Runnable interface, so it can be added to the manager.
+func NewCapacityCollector(cache *ClusterCache, log logr.Logger, interval time.Duration, conditions ...Condition) *CapacityCollector {
+	if interval <= 0 {
+		interval = DefaultCapacityInterval
+	}
+	return &CapacityCollector{
+		cache:      cache,
+		log:        log,
+		interval:   interval,
+		conditions: conditions,
+	}
+}
+
+// Start collects the capacity of the clusters right away and then every interval until the given context is done
+func (c *CapacityCollector) Start(ctx context.Context) error {
+	RegisterMetrics()
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		c.Collect(ctx)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect samples the capacity of all the matching clusters once. If the capacity of a cluster cannot be sampled,
+// then the previous snapshot (if any) is kept.
+func (c *CapacityCollector) Collect(ctx context.Context) {
+	for _, cluster := range c.cache.getCachedToolchainClusters(c.conditions...) {
+		if cluster.Client == nil {
+			continue
+		}
+		var reader client.Reader = cluster.Client
+		if cluster.Cluster != nil {
+			// the informer-backed client serves only the objects it has the informers for
+			reader = cluster.Cluster.GetAPIReader()
+		}
+		capacity, err := SampleCapacity(ctx, reader)
+		if err != nil {
+			c.log.Error(err, "unable to sample the capacity of the cluster", "cluster", cluster.Name)
+			continue
+		}
+		if c.cache.setCapacity(cluster.Name, capacity) {
+			recordCapacity(cluster.Name, capacity)
+		}
+	}
+}
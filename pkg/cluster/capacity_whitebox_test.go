@@ -0,0 +1,263 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	metricstest "github.com/codeready-toolchain/toolchain-common/pkg/test/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestSampleCapacity(t *testing.T) {
+	// given
+	cl := test.NewFakeClient(t,
+		node("node-1", "4", "16Gi", false),
+		node("node-2", "4", "16Gi", false),
+		node("cordoned", "8", "32Gi", true),
+		pod("app", corev1.PodRunning, requests("1", "2Gi"), requests("500m", "1Gi")),
+		pod("with-big-init", corev1.PodPending, requests("3", "1Gi"), requests("500m", "1Gi")),
+		pod("completed", corev1.PodSucceeded, nil, requests("2", "8Gi")),
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-1"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns-2"}})
+
+	// when
+	capacity, err := SampleCapacity(context.TODO(), cl)
+
+	// then
+	require.NoError(t, err)
+	assert.Equal(t, "8", capacity.AllocatableCPU.String())
+	assert.Equal(t, "32Gi", capacity.AllocatableMemory.String())
+	// app: max(500m+500m, 1), with-big-init: max(500m+500m, 3)
+	assert.Equal(t, "4", capacity.RequestedCPU.String())
+	// app: max(1Gi+1Gi, 2Gi), with-big-init: max(1Gi+1Gi, 1Gi)
+	assert.Equal(t, "4Gi", capacity.RequestedMemory.String())
+	assert.Equal(t, 2, capacity.Namespaces)
+	assert.InDelta(t, 50.0, capacity.FreeCPUPercentage(), 0.01)
+	assert.InDelta(t, 87.5, capacity.FreeMemoryPercentage(), 0.01)
+	assert.False(t, capacity.SampledAt.IsZero())
+
+	t.Run("failure", func(t *testing.T) {
+		// given
+		cl.MockList = func(_ context.Context, _ client.ObjectList, _ ...client.ListOption) error {
+			return fmt.Errorf("some error")
+		}
+
+		// when
+		_, err := SampleCapacity(context.TODO(), cl)
+
+		// then
+		require.EqualError(t, err, "unable to list the nodes: some error")
+	})
+
+	t.Run("pods are listed in pages", func(t *testing.T) {
+		// given
+		pages := map[string]*corev1.PodList{
+			"": {
+				ListMeta: metav1.ListMeta{Continue: "page-2"},
+				Items:    []corev1.Pod{*pod("app-1", corev1.PodRunning, nil, requests("1", "1Gi"))},
+			},
+			"page-2": {
+				Items: []corev1.Pod{*pod("app-2", corev1.PodRunning, nil, requests("2", "1Gi"))},
+			},
+		}
+		cl := test.NewFakeClient(t, node("node-1", "4", "16Gi", false))
+		cl.MockList = func(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+			pods, ok := list.(*corev1.PodList)
+			if !ok {
+				return cl.Client.List(ctx, list, opts...)
+			}
+			listOpts := &client.ListOptions{}
+			listOpts.ApplyOptions(opts)
+			assert.Equal(t, int64(500), listOpts.Limit)
+			pages[listOpts.Continue].DeepCopyInto(pods)
+			return nil
+		}
+
+		// when
+		capacity, err := SampleCapacity(context.TODO(), cl)
+
+		// then
+		require.NoError(t, err)
+		// two containers per pod
+		assert.Equal(t, "6", capacity.RequestedCPU.String())
+		assert.Equal(t, "4Gi", capacity.RequestedMemory.String())
+	})
+}
+
+func TestCapacityConditions(t *testing.T) {
+	// given
+	withCapacity := func(cpu, memory string) clusterOption {
+		return func(c *CachedToolchainCluster) {
+			c.Capacity = &Capacity{
+				AllocatableCPU:    resource.MustParse("10"),
+				AllocatableMemory: resource.MustParse("10Gi"),
+				RequestedCPU:      resource.MustParse(cpu),
+				RequestedMemory:   resource.MustParse(memory),
+			}
+		}
+	}
+	clusters := NewClusterCache()
+	clusters.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "busy", withCapacity("9", "9Gi")))
+	clusters.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "half-full", withCapacity("5", "2Gi")))
+	clusters.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "overcommitted", withCapacity("12", "12Gi")))
+	clusters.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "not-sampled"))
+
+	// when
+	withFreeCPU := clusters.getCachedToolchainClusters(HasFreeCPU(50))
+	withFreeMemory := clusters.getCachedToolchainClusters(HasFreeMemory(50))
+
+	// then
+	assertClusterNames(t, withFreeCPU, "half-full")
+	assertClusterNames(t, withFreeMemory, "half-full")
+	assertClusterNames(t, clusters.getCachedToolchainClusters(HasFreeMemory(0)), "busy", "half-full", "overcommitted")
+}
+
+func TestCapacityCollector(t *testing.T) {
+	// given
+	defer gock.Off()
+	member1Client := test.NewFakeClient(t, node("node-1", "4", "16Gi", false), pod("app", corev1.PodRunning, nil, requests("500m", "2Gi")))
+	brokenClient := test.NewFakeClient(t)
+	brokenClient.MockList = func(_ context.Context, _ client.ObjectList, _ ...client.ListOption) error {
+		return fmt.Errorf("some error")
+	}
+	clusters := NewClusterCache()
+	withClient := func(cl client.Client) clusterOption {
+		return func(c *CachedToolchainCluster) {
+			c.Client = cl
+		}
+	}
+	member := withLabel(RoleLabel(Member), "")
+	clusters.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member1", member, withClient(member1Client)))
+	clusters.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "broken", member, withClient(brokenClient)))
+	clusters.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "host", withLabel(RoleLabel(Host), "")))
+	previous, _ := clusters.getCachedToolchainCluster("member1", false)
+	collector := NewCapacityCollector(clusters, logf.Log, 0, HasRole(Member))
+
+	// when
+	collector.Collect(context.TODO())
+
+	// then
+	member1, _ := clusters.getCachedToolchainCluster("member1", false)
+	require.NotNil(t, member1.Capacity)
+	assert.Equal(t, "1", member1.Capacity.RequestedCPU.String())
+	assert.InDelta(t, 75.0, member1.Capacity.FreeMemoryPercentage(), 0.01)
+	assert.Nil(t, previous.Capacity, "the clusters returned before should not be modified")
+	broken, _ := clusters.getCachedToolchainCluster("broken", false)
+	assert.Nil(t, broken.Capacity)
+	host, _ := clusters.getCachedToolchainCluster("host", false)
+	assert.Nil(t, host.Capacity)
+	metricstest.AssertMetricsGaugeEquals(t, 4, CapacityAllocatableCPUGaugeVec.WithLabelValues("member1"))
+	metricstest.AssertMetricsGaugeEquals(t, 1, CapacityRequestedCPUGaugeVec.WithLabelValues("member1"))
+	metricstest.AssertMetricsGaugeEquals(t, 16*1024*1024*1024, CapacityAllocatableMemoryGaugeVec.WithLabelValues("member1"))
+	metricstest.AssertMetricsGaugeEquals(t, 4*1024*1024*1024, CapacityRequestedMemoryGaugeVec.WithLabelValues("member1"))
+	metricstest.AssertMetricsGaugeEquals(t, 0, CapacityNamespacesGaugeVec.WithLabelValues("member1"))
+
+	t.Run("capacity stored in the meantime is kept when the cluster is updated", func(t *testing.T) {
+		// given
+		outdated := *member1
+		outdated.Capacity = nil
+		sampled := &Capacity{Namespaces: 10}
+		require.True(t, clusters.setCapacity("member1", sampled))
+
+		// when
+		clusters.addCachedToolchainCluster(&outdated)
+
+		// then
+		updated, _ := clusters.getCachedToolchainCluster("member1", false)
+		assert.Same(t, sampled, updated.Capacity)
+		member1 = updated
+	})
+
+	t.Run("capacity is kept when the cluster is updated by the service", func(t *testing.T) {
+		// given
+		status := test.NewClusterStatus(toolchainv1alpha1.ConditionReady, corev1.ConditionTrue)
+		tc, sec := test.NewToolchainCluster(t, "member1", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, false)
+		service := NewToolchainClusterServiceWithCache(clusters, test.NewFakeClient(t, tc, sec), logf.Log, test.HostOperatorNs, 0, nil)
+
+		// when
+		err := service.AddOrUpdateToolchainCluster(tc)
+
+		// then
+		require.NoError(t, err)
+		updated, _ := clusters.getCachedToolchainCluster("member1", false)
+		assert.NotSame(t, member1Client, updated.Client)
+		assert.Same(t, member1.Capacity, updated.Capacity)
+	})
+}
+
+func TestNewCapacityCollector(t *testing.T) {
+	t.Run("default interval", func(t *testing.T) {
+		for _, interval := range []time.Duration{0, -time.Second} {
+			// when
+			collector := NewCapacityCollector(NewClusterCache(), logf.Log, interval)
+
+			// then
+			assert.Equal(t, DefaultCapacityInterval, collector.interval)
+		}
+	})
+
+	t.Run("given interval", func(t *testing.T) {
+		// when
+		collector := NewCapacityCollector(NewClusterCache(), logf.Log, time.Minute)
+
+		// then
+		assert.Equal(t, time.Minute, collector.interval)
+	})
+}
+
+func assertClusterNames(t *testing.T, clusters []*CachedToolchainCluster, expectedNames ...string) {
+	names := make([]string, len(clusters))
+	for i, cluster := range clusters {
+		names[i] = cluster.Name
+	}
+	assert.Equal(t, expectedNames, names)
+}
+
+func node(name, cpu, memory string, unschedulable bool) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       corev1.NodeSpec{Unschedulable: unschedulable},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse(cpu),
+				corev1.ResourceMemory: resource.MustParse(memory),
+			},
+		},
+	}
+}
+
+func requests(cpu, memory string) corev1.ResourceList {
+	return corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse(cpu),
+		corev1.ResourceMemory: resource.MustParse(memory),
+	}
+}
+
+// pod returns a pod with one init container (if initRequests is not nil) and two containers having the given requests
+func pod(name string, phase corev1.PodPhase, initRequests, containerRequests corev1.ResourceList) *corev1.Pod {
+	p := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns-1"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "first", Resources: corev1.ResourceRequirements{Requests: containerRequests}},
+				{Name: "second", Resources: corev1.ResourceRequirements{Requests: containerRequests}},
+			},
+		},
+		Status: corev1.PodStatus{Phase: phase},
+	}
+	if initRequests != nil {
+		p.Spec.InitContainers = []corev1.Container{{Name: "init", Resources: corev1.ResourceRequirements{Requests: initRequests}}}
+	}
+	return p
+}
@@ -0,0 +1,223 @@
+package cluster
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// CredentialSourceAnnotationKey is the annotation of the ToolchainCluster selecting the CredentialLoader
+// that is used for loading the credentials from the referenced Secret. If not set, then the KubeConfigCredentialSource is used.
+const CredentialSourceAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "credential-source"
+
+// the names of the built-in credential sources
+const (
+	// KubeConfigCredentialSource loads the credentials from a kubeconfig stored under the "kubeconfig" key.
+	// The operator namespace is the namespace of the current context.
+	KubeConfigCredentialSource = "kubeconfig"
+	// TokenCredentialSource loads the credentials from the "token", "api-endpoint", "namespace" and (optional) "ca.crt" keys
+	TokenCredentialSource = "token"
+	// ClientCertificateCredentialSource loads the credentials from the "tls.crt", "tls.key", "api-endpoint", "namespace"
+	// and (optional) "ca.crt" keys
+	ClientCertificateCredentialSource = "client-cert"
+	// TokenFileCredentialSource loads the credentials from the "token-file" (a path to a projected service account token),
+	// "api-endpoint", "namespace" and (optional) "ca.crt" keys. The token is re-read from the file by the client when it's rotated.
+	// The path has to point to a file in the DefaultTokenFileDirectory (see NewTokenFileCredentialLoader for using another directory).
+	TokenFileCredentialSource = "token-file"
+)
+
+// DefaultTokenFileDirectory is the only directory the built-in TokenFileCredentialSource loader reads the token files from
+const DefaultTokenFileDirectory = "/var/run/secrets/tokens"
+
+// the keys of the Secret data used by the built-in credential loaders
+const (
+	kubeConfigKey  = "kubeconfig"
+	tokenKey       = "token"
+	tokenFileKey   = "token-file"
+	caCertKey      = "ca.crt"
+	tlsCertKey     = "tls.crt"
+	tlsKeyKey      = "tls.key"
+	apiEndpointKey = "api-endpoint"
+	namespaceKey   = "namespace"
+)
+
+// Credentials contains the data loaded by a CredentialLoader
+type Credentials struct {
+	// RestConfig is the config used for accessing the cluster
+	RestConfig *rest.Config
+	// OperatorNamespace is a name of a namespace (in the cluster) the operator is running in
+	OperatorNamespace string
+}
+
+// CredentialLoader loads the credentials for accessing a cluster from the Secret referenced by a ToolchainCluster
+type CredentialLoader interface {
+	Load(secret *v1.Secret) (*Credentials, error)
+}
+
+// CredentialLoaderFunc is a function implementing the CredentialLoader interface
+type CredentialLoaderFunc func(secret *v1.Secret) (*Credentials, error)
+
+// Load calls the function
+func (f CredentialLoaderFunc) Load(secret *v1.Secret) (*Credentials, error) {
+	return f(secret)
+}
+
+var credentialLoaders = struct {
+	sync.RWMutex
+	loaders map[string]CredentialLoader
+}{
+	loaders: map[string]CredentialLoader{
+		KubeConfigCredentialSource:        CredentialLoaderFunc(loadKubeConfigCredentials),
+		TokenCredentialSource:             CredentialLoaderFunc(loadTokenCredentials),
+		ClientCertificateCredentialSource: CredentialLoaderFunc(loadClientCertificateCredentials),
+		TokenFileCredentialSource:         NewTokenFileCredentialLoader(DefaultTokenFileDirectory),
+	},
+}
+
+// RegisterCredentialLoader registers the loader for the given credential source (the value of the CredentialSourceAnnotationKey annotation).
+// An already registered loader (including the built-in ones) is replaced.
+func RegisterCredentialLoader(source string, loader CredentialLoader) {
+	credentialLoaders.Lock()
+	defer credentialLoaders.Unlock()
+	credentialLoaders.loaders[source] = loader
+}
+
+// GetCredentialLoader returns the loader registered for the given credential source
+func GetCredentialLoader(source string) (CredentialLoader, error) {
+	credentialLoaders.RLock()
+	defer credentialLoaders.RUnlock()
+	loader, found := credentialLoaders.loaders[source]
+	if !found {
+		sources := make([]string, 0, len(credentialLoaders.loaders))
+		for s := range credentialLoaders.loaders {
+			sources = append(sources, s)
+		}
+		sort.Strings(sources)
+		return nil, fmt.Errorf("unknown credential source '%s', supported sources are: %s", source, strings.Join(sources, ", "))
+	}
+	return loader, nil
+}
+
+// credentialSource returns the credential source set on the given ToolchainCluster (the kubeconfig source by default)
+func credentialSource(toolchainCluster *toolchainv1alpha1.ToolchainCluster) string {
+	if source, found := toolchainCluster.Annotations[CredentialSourceAnnotationKey]; found && source != "" {
+		return source
+	}
+	return KubeConfigCredentialSource
+}
+
+func loadKubeConfigCredentials(secret *v1.Secret) (*Credentials, error) {
+	if err := requireKeys(KubeConfigCredentialSource, secret, kubeConfigKey); err != nil {
+		return nil, err
+	}
+	cfg, err := clientcmd.Load(secret.Data[kubeConfigKey])
+	if err != nil {
+		return nil, err
+	}
+	return kubeConfigCredentials(*cfg, "")
+}
+
+// kubeConfigCredentials loads the credentials from the given context of the kubeconfig (the current context if empty).
+// The operator namespace is the namespace of the context.
+func kubeConfigCredentials(cfg clientcmdapi.Config, contextName string) (*Credentials, error) {
+	clientCfg := clientcmd.NewNonInteractiveClientConfig(cfg, contextName, &clientcmd.ConfigOverrides{}, nil)
+	restCfg, err := clientCfg.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	operatorNamespace, _, err := clientCfg.Namespace()
+	if err != nil {
+		return nil, fmt.Errorf("could not determine the operator namespace from the current context in the provided kubeconfig because of: %w", err)
+	}
+	return &Credentials{
+		RestConfig:        restCfg,
+		OperatorNamespace: operatorNamespace,
+	}, nil
+}
+
+func loadTokenCredentials(secret *v1.Secret) (*Credentials, error) {
+	if err := requireKeys(TokenCredentialSource, secret, tokenKey, apiEndpointKey, namespaceKey); err != nil {
+		return nil, err
+	}
+	return newCredentials(secret, func(config *rest.Config) {
+		config.BearerToken = string(secret.Data[tokenKey])
+	})
+}
+
+func loadClientCertificateCredentials(secret *v1.Secret) (*Credentials, error) {
+	if err := requireKeys(ClientCertificateCredentialSource, secret, tlsCertKey, tlsKeyKey, apiEndpointKey, namespaceKey); err != nil {
+		return nil, err
+	}
+	return newCredentials(secret, func(config *rest.Config) {
+		config.CertData = secret.Data[tlsCertKey]
+		config.KeyData = secret.Data[tlsKeyKey]
+	})
+}
+
+// NewTokenFileCredentialLoader returns the loader of the TokenFileCredentialSource that accepts only the token files
+// in the given directory (or in its subdirectories). Any other path is rejected, so the Secret cannot make the client
+// send the content of an arbitrary local file as the bearer token.
+func NewTokenFileCredentialLoader(directory string) CredentialLoader {
+	directory = filepath.Clean(directory)
+	return CredentialLoaderFunc(func(secret *v1.Secret) (*Credentials, error) {
+		if err := requireKeys(TokenFileCredentialSource, secret, tokenFileKey, apiEndpointKey, namespaceKey); err != nil {
+			return nil, err
+		}
+		tokenFile := string(secret.Data[tokenFileKey])
+		if !isInDirectory(directory, tokenFile) {
+			return nil, fmt.Errorf("invalid value of the '%s' key: the token file has to be in the %s directory", tokenFileKey, directory)
+		}
+		return newCredentials(secret, func(config *rest.Config) {
+			config.BearerTokenFile = filepath.Clean(tokenFile)
+		})
+	})
+}
+
+// isInDirectory checks that the given absolute path points to a file in the given directory (or in its subdirectories)
+func isInDirectory(directory, path string) bool {
+	if !filepath.IsAbs(path) {
+		return false
+	}
+	rel, err := filepath.Rel(directory, filepath.Clean(path))
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// newCredentials creates the credentials from the common "api-endpoint", "namespace" and "ca.crt" keys
+// and lets the given function set the authentication part of the config
+func newCredentials(secret *v1.Secret, setAuth func(config *rest.Config)) (*Credentials, error) {
+	restCfg := &rest.Config{
+		Host: string(secret.Data[apiEndpointKey]),
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: secret.Data[caCertKey],
+		},
+	}
+	setAuth(restCfg)
+	if _, _, err := rest.DefaultServerUrlFor(restCfg); err != nil {
+		return nil, fmt.Errorf("invalid value of the '%s' key: %w", apiEndpointKey, err)
+	}
+	return &Credentials{
+		RestConfig:        restCfg,
+		OperatorNamespace: string(secret.Data[namespaceKey]),
+	}, nil
+}
+
+func requireKeys(source string, secret *v1.Secret, keys ...string) error {
+	var missing []string
+	for _, key := range keys {
+		if len(secret.Data[key]) == 0 {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the secret %s is missing the required key(s) for the '%s' credential source: %s", secret.Name, source, strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package cluster
+
+import (
+	"context"
+	"reflect"
+	"sync"
+
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// ClusterEventType is the type of change of a cluster in the cache
+type ClusterEventType string
+
+const (
+	// ClusterAdded is published when a cluster which was not in the cache is added
+	ClusterAdded ClusterEventType = "Added"
+	// ClusterUpdated is published when the config (eg. kubeconfig, labels) or the client of a cached cluster has changed
+	ClusterUpdated ClusterEventType = "Updated"
+	// ClusterRemoved is published when a cluster is removed from the cache
+	ClusterRemoved ClusterEventType = "Removed"
+	// ClusterReadinessChanged is published when a cached cluster became Ready or stopped being Ready
+	ClusterReadinessChanged ClusterEventType = "ReadinessChanged"
+	// ClusterCredentialsRotated is published after the client created for the rotated credentials of a cluster
+	// passed the health probe and replaced the previous client in the cache (which is published as ClusterUpdated).
+	// It's published only when the service is configured with a ClientProbe.
+	ClusterCredentialsRotated ClusterEventType = "CredentialsRotated"
+	// ClusterCredentialsRotationFailed is published when the client created for the rotated credentials of a cluster
+	// didn't pass the health probe, so the previous client is kept in the cache. The New cluster is the rejected one.
+	ClusterCredentialsRotationFailed ClusterEventType = "CredentialsRotationFailed"
+)
+
+// ClusterEvent describes a change of a cluster in the cache.
+// Old is nil for the ClusterAdded events and New is nil for the ClusterRemoved events.
+type ClusterEvent struct {
+	Type ClusterEventType
+	Old  *CachedToolchainCluster
+	New  *CachedToolchainCluster
+}
+
+// Name returns the name of the cluster the event is related to
+func (e ClusterEvent) Name() string {
+	if e.New != nil {
+		return e.New.Name
+	}
+	if e.Old != nil {
+		return e.Old.Name
+	}
+	return ""
+}
+
+// ClusterEventHandler is called for every change of the clusters in the cache
+type ClusterEventHandler func(event ClusterEvent)
+
+// Subscribe registers the given handler to be called for every change of the clusters in the cache.
+// The handler is called synchronously after the cache was modified (outside of the cache lock), so it should not block.
+// The events are delivered in the same order as the cache was modified, which is why the handler must not modify
+// the cache (nor trigger its refresh) synchronously - it can only read the clusters which are already cached.
+// The returned function unsubscribes the handler.
+func (c *ClusterCache) Subscribe(handler ClusterEventHandler) func() {
+	c.subscribersLock.Lock()
+	defer c.subscribersLock.Unlock()
+	if c.subscribers == nil {
+		c.subscribers = map[int]ClusterEventHandler{}
+	}
+	id := c.nextSubscriberID
+	c.nextSubscriberID++
+	c.subscribers[id] = handler
+	return func() {
+		c.subscribersLock.Lock()
+		defer c.subscribersLock.Unlock()
+		delete(c.subscribers, id)
+	}
+}
+
+// publishOrdered publishes the given events which are not related to any modification of the cache
+func (c *ClusterCache) publishOrdered(events ...ClusterEvent) {
+	c.publishLock.Lock()
+	defer c.publishLock.Unlock()
+	c.publish(events...)
+}
+
+// publish calls the subscribed handlers for each of the given events. The caller has to hold the publishLock.
+func (c *ClusterCache) publish(events ...ClusterEvent) {
+	if len(events) == 0 {
+		return
+	}
+	c.subscribersLock.RLock()
+	handlers := make([]ClusterEventHandler, 0, len(c.subscribers))
+	for _, handler := range c.subscribers {
+		handlers = append(handlers, handler)
+	}
+	c.subscribersLock.RUnlock()
+	for _, event := range events {
+		for _, handler := range handlers {
+			handler(event)
+		}
+	}
+}
+
+// newAddedOrUpdatedEvents returns the events describing the replacement of the old cluster with the new one.
+// When both the readiness and the config (or the client) changed, then both events are returned.
+func newAddedOrUpdatedEvents(oldCluster, newCluster *CachedToolchainCluster) []ClusterEvent {
+	if oldCluster == nil {
+		return []ClusterEvent{{Type: ClusterAdded, New: newCluster}}
+	}
+	var events []ClusterEvent
+	if isReady(oldCluster) != isReady(newCluster) {
+		events = append(events, ClusterEvent{Type: ClusterReadinessChanged, Old: oldCluster, New: newCluster})
+	}
+	if oldCluster.Client != newCluster.Client || !reflect.DeepEqual(oldCluster.Config, newCluster.Config) {
+		events = append(events, ClusterEvent{Type: ClusterUpdated, Old: oldCluster, New: newCluster})
+	}
+	return events
+}
+
+func isReady(cluster *CachedToolchainCluster) bool {
+	return cluster.ClusterStatus != nil && IsReady(cluster.ClusterStatus)
+}
+
+// ClusterEventMapFunc maps the given ClusterEvent to the requests that should be reconciled. When used by the source
+// returned by NewClusterEventSource, it's called asynchronously, so it can read (and refresh) the cache.
+type ClusterEventMapFunc = handler.TypedMapFunc[ClusterEvent, reconcile.Request]
+
+// NewClusterEventSource returns a source.Source that enqueues the requests returned by the given mapFn
+// for every change of the clusters in the given cache. Only the events of the given types are processed;
+// if no type is given, then all events are processed. The events are mapped by a goroutine of the source in the order
+// they were published, so unlike the handlers registered by Subscribe, the mapFn may block and use the cache freely
+// (eg. call GetMemberClusters, which refreshes the cache when it's empty).
+func NewClusterEventSource(cache *ClusterCache, mapFn ClusterEventMapFunc, types ...ClusterEventType) source.Source {
+	return &clusterEventSource{
+		cache: cache,
+		mapFn: mapFn,
+		types: types,
+	}
+}
+
+type clusterEventSource struct {
+	cache *ClusterCache
+	mapFn ClusterEventMapFunc
+	types []ClusterEventType
+}
+
+var _ source.Source = &clusterEventSource{}
+
+// Start subscribes to the cache and maps the received events until the given context is done
+func (s *clusterEventSource) Start(ctx context.Context, queue workqueue.TypedRateLimitingInterface[reconcile.Request]) error {
+	var pendingLock sync.Mutex
+	var pending []ClusterEvent
+	received := make(chan struct{}, 1)
+	unsubscribe := s.cache.Subscribe(func(event ClusterEvent) {
+		if !s.accepts(event.Type) {
+			return
+		}
+		// the handler only stores the event, because it's called while the cache is publishing the events
+		pendingLock.Lock()
+		pending = append(pending, event)
+		pendingLock.Unlock()
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+	})
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-received:
+			}
+			pendingLock.Lock()
+			events := pending
+			pending = nil
+			pendingLock.Unlock()
+			for _, event := range events {
+				for _, req := range s.mapFn(ctx, event) {
+					queue.Add(req)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *clusterEventSource) accepts(eventType ClusterEventType) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	for _, t := range s.types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *clusterEventSource) String() string {
+	return "ToolchainCluster cache events"
+}
@@ -0,0 +1,277 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestClusterEvents(t *testing.T) {
+	// given
+	cluster := newTestCachedToolchainCluster(t, "member", ready)
+
+	subscribe := func(t *testing.T, cache *ClusterCache) *[]ClusterEvent {
+		events := &[]ClusterEvent{}
+		unsubscribe := cache.Subscribe(func(event ClusterEvent) {
+			*events = append(*events, event)
+		})
+		t.Cleanup(unsubscribe)
+		return events
+	}
+
+	t.Run("added", func(t *testing.T) {
+		// given
+		cache := NewClusterCache()
+		events := subscribe(t, cache)
+
+		// when
+		cache.addCachedToolchainCluster(cluster)
+
+		// then
+		require.Len(t, *events, 1)
+		assert.Equal(t, ClusterEvent{Type: ClusterAdded, New: cluster}, (*events)[0])
+		assert.Equal(t, "member", (*events)[0].Name())
+	})
+
+	t.Run("nothing published when nothing changed", func(t *testing.T) {
+		// given
+		cache := NewClusterCache()
+		cache.addCachedToolchainCluster(cluster)
+		events := subscribe(t, cache)
+		same := *cluster
+
+		// when
+		cache.addCachedToolchainCluster(&same)
+
+		// then
+		assert.Empty(t, *events)
+	})
+
+	t.Run("updated when client changed", func(t *testing.T) {
+		// given
+		cache := NewClusterCache()
+		cache.addCachedToolchainCluster(cluster)
+		events := subscribe(t, cache)
+		updated := newTestCachedToolchainCluster(t, "member", ready)
+
+		// when
+		cache.addCachedToolchainCluster(updated)
+
+		// then
+		require.Len(t, *events, 1)
+		assert.Equal(t, ClusterEvent{Type: ClusterUpdated, Old: cluster, New: updated}, (*events)[0])
+	})
+
+	t.Run("updated when config changed", func(t *testing.T) {
+		// given
+		cache := NewClusterCache()
+		cache.addCachedToolchainCluster(cluster)
+		events := subscribe(t, cache)
+		updated := *cluster
+		updated.Config = &Config{Name: "member", OperatorNamespace: "another-namespace"}
+
+		// when
+		cache.addCachedToolchainCluster(&updated)
+
+		// then
+		require.Len(t, *events, 1)
+		assert.Equal(t, ClusterUpdated, (*events)[0].Type)
+	})
+
+	t.Run("readiness changed", func(t *testing.T) {
+		// given
+		cache := NewClusterCache()
+		cache.addCachedToolchainCluster(cluster)
+		events := subscribe(t, cache)
+		notReadyCluster := *cluster
+		notReadyCluster.ClusterStatus = newTestCachedToolchainCluster(t, "member", notReady).ClusterStatus
+
+		// when
+		cache.addCachedToolchainCluster(&notReadyCluster)
+
+		// then
+		require.Len(t, *events, 1)
+		assert.Equal(t, ClusterEvent{Type: ClusterReadinessChanged, Old: cluster, New: &notReadyCluster}, (*events)[0])
+	})
+
+	t.Run("readiness and config changed together", func(t *testing.T) {
+		// given
+		cache := NewClusterCache()
+		cache.addCachedToolchainCluster(cluster)
+		events := subscribe(t, cache)
+		updated := newTestCachedToolchainCluster(t, "member", notReady)
+
+		// when
+		cache.addCachedToolchainCluster(updated)
+
+		// then
+		assert.Equal(t, []ClusterEvent{
+			{Type: ClusterReadinessChanged, Old: cluster, New: updated},
+			{Type: ClusterUpdated, Old: cluster, New: updated},
+		}, *events)
+	})
+
+	t.Run("published in the order of the modifications", func(t *testing.T) {
+		// given
+		cache := NewClusterCache()
+		var lock sync.Mutex
+		var published []string
+		cache.Subscribe(func(event ClusterEvent) {
+			lock.Lock()
+			defer lock.Unlock()
+			published = append(published, fmt.Sprintf("%s:%s", event.Name(), event.Type))
+		})
+		var wg sync.WaitGroup
+
+		// when
+		for i := 0; i < 10; i++ {
+			member := newTestCachedToolchainCluster(t, fmt.Sprintf("member-%d", i), ready)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 10; j++ {
+					cache.addCachedToolchainCluster(member)
+					cache.deleteCachedToolchainCluster(member.Name)
+				}
+			}()
+		}
+		wg.Wait()
+
+		// then
+		lastTypes := map[string]string{}
+		for _, event := range published {
+			nameAndType := strings.SplitN(event, ":", 2)
+			previous := lastTypes[nameAndType[0]]
+			switch nameAndType[1] {
+			case string(ClusterAdded):
+				assert.NotEqual(t, string(ClusterAdded), previous, "added twice in a row: %s", nameAndType[0])
+			case string(ClusterRemoved):
+				assert.Equal(t, string(ClusterAdded), previous, "removed before added: %s", nameAndType[0])
+			}
+			lastTypes[nameAndType[0]] = nameAndType[1]
+		}
+		assert.Len(t, published, 200)
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		// given
+		cache := NewClusterCache()
+		cache.addCachedToolchainCluster(cluster)
+		events := subscribe(t, cache)
+
+		// when
+		cache.deleteCachedToolchainCluster("member")
+		cache.deleteCachedToolchainCluster("unknown")
+
+		// then
+		require.Len(t, *events, 1)
+		assert.Equal(t, ClusterEvent{Type: ClusterRemoved, Old: cluster}, (*events)[0])
+		assert.Equal(t, "member", (*events)[0].Name())
+	})
+
+	t.Run("unsubscribed", func(t *testing.T) {
+		// given
+		cache := NewClusterCache()
+		var events []ClusterEvent
+		unsubscribe := cache.Subscribe(func(event ClusterEvent) {
+			events = append(events, event)
+		})
+
+		// when
+		unsubscribe()
+		cache.addCachedToolchainCluster(cluster)
+
+		// then
+		assert.Empty(t, events)
+	})
+
+	t.Run("handler can read from the cache", func(t *testing.T) {
+		// given
+		cache := NewClusterCache()
+		var found bool
+		cache.Subscribe(func(event ClusterEvent) {
+			_, found = cache.getCachedToolchainCluster(event.Name(), false)
+		})
+
+		// when
+		cache.addCachedToolchainCluster(cluster)
+
+		// then
+		assert.True(t, found)
+	})
+}
+
+func TestClusterEventSource(t *testing.T) {
+	// given
+	cache := NewClusterCache()
+	queue := workqueue.NewTypedRateLimitingQueue[reconcile.Request](workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+	defer queue.ShutDown()
+	src := NewClusterEventSource(cache, func(_ context.Context, event ClusterEvent) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: event.Name()}}}
+	}, ClusterAdded, ClusterRemoved)
+	ctx, cancel := context.WithCancel(context.TODO())
+
+	// when
+	require.NoError(t, src.Start(ctx, queue))
+	cache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member-1", ready))
+	cache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member-1", notReady)) // filtered out
+	cache.deleteCachedToolchainCluster("member-1")
+
+	// then
+	require.Eventually(t, func() bool {
+		return queue.Len() == 1 // the same request is deduplicated by the queue
+	}, time.Second, 10*time.Millisecond)
+	req, _ := queue.Get()
+	assert.Equal(t, "member-1", req.Name)
+	queue.Done(req)
+
+	t.Run("mapFn can modify the cache", func(t *testing.T) {
+		// given
+		cache := NewClusterCache()
+		queue := workqueue.NewTypedRateLimitingQueue[reconcile.Request](workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+		defer queue.ShutDown()
+		replacement := newTestCachedToolchainCluster(t, "member-2", ready)
+		src := NewClusterEventSource(cache, func(_ context.Context, event ClusterEvent) []reconcile.Request {
+			// emulates the refresh of the cache which became empty
+			cache.addCachedToolchainCluster(replacement)
+			return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: event.Name()}}}
+		}, ClusterRemoved)
+		ctx, cancel := context.WithCancel(context.TODO())
+		defer cancel()
+		require.NoError(t, src.Start(ctx, queue))
+		cache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member-1", ready))
+
+		// when
+		cache.deleteCachedToolchainCluster("member-1")
+
+		// then
+		require.Eventually(t, func() bool {
+			return queue.Len() == 1
+		}, time.Second, 10*time.Millisecond)
+		_, found := cache.getCachedToolchainCluster("member-2", false)
+		assert.True(t, found)
+	})
+
+	t.Run("unsubscribed when the context is done", func(t *testing.T) {
+		// when
+		cancel()
+
+		// then
+		require.Eventually(t, func() bool {
+			cache.subscribersLock.RLock()
+			defer cache.subscribersLock.RUnlock()
+			return len(cache.subscribers) == 0
+		}, time.Second, 10*time.Millisecond)
+		cache.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member-2", ready))
+		assert.Equal(t, 0, queue.Len())
+	})
+}
@@ -0,0 +1,79 @@
+package cluster
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crcluster "sigs.k8s.io/controller-runtime/pkg/cluster"
+)
+
+// InformerCacheOptions configures the informer cache backing the clients of the cached clusters
+type InformerCacheOptions struct {
+	// Objects are the types of the objects kept in the informer cache. Reading any other type via the cached client fails
+	// with the cache.ErrResourceNotCached error.
+	Objects []client.Object
+	// Namespaces limit the informer cache to the given namespaces. If empty, then the objects from all namespaces are cached.
+	Namespaces []string
+}
+
+// NewCluster creates a controller-runtime Cluster for the given config
+type NewCluster func(config *rest.Config, opts ...crcluster.Option) (crcluster.Cluster, error)
+
+// WithInformerCache backs the client of each cached cluster by a controller-runtime Cluster with a shared informer cache,
+// so the reads are served from the cache and the cluster can be watched via CachedToolchainCluster.Cluster.
+// The Clusters are started with the given context (when the ToolchainCluster is added) and stopped when the ToolchainCluster
+// is deleted, when the client is rebuilt, or when the context is done.
+func WithInformerCache(ctx context.Context, options InformerCacheOptions) ServiceOption {
+	return func(s *ToolchainClusterService) {
+		s.informerCtx = ctx
+		s.informerOptions = &options
+	}
+}
+
+// clusterOptions returns the options of the controller-runtime Cluster limiting the informer cache to the configured objects and namespaces
+func (o InformerCacheOptions) clusterOptions(scheme *runtime.Scheme) crcluster.Option {
+	return func(options *crcluster.Options) {
+		options.Scheme = scheme
+		options.Cache.ReaderFailOnMissingInformer = true
+		if len(o.Namespaces) > 0 {
+			options.Cache.DefaultNamespaces = make(map[string]cache.Config, len(o.Namespaces))
+			for _, ns := range o.Namespaces {
+				options.Cache.DefaultNamespaces[ns] = cache.Config{}
+			}
+		}
+	}
+}
+
+// newInformerCluster creates a controller-runtime Cluster with the informers of all the configured objects (not started yet)
+func (s *ToolchainClusterService) newInformerCluster(config *rest.Config, scheme *runtime.Scheme) (crcluster.Cluster, error) {
+	newCluster := s.newCluster
+	if newCluster == nil {
+		newCluster = crcluster.New
+	}
+	cl, err := newCluster(config, s.informerOptions.clusterOptions(scheme))
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range s.informerOptions.Objects {
+		if _, err := cl.GetCache().GetInformer(s.informerCtx, obj, cache.BlockUntilSynced(false)); err != nil {
+			return nil, errors.Wrapf(err, "cannot create informer for %T", obj)
+		}
+	}
+	return cl, nil
+}
+
+// startInformerCluster starts the given Cluster in the background and returns the function stopping it
+func (s *ToolchainClusterService) startInformerCluster(log logr.Logger, cl crcluster.Cluster) context.CancelFunc {
+	ctx, cancel := context.WithCancel(s.informerCtx)
+	go func() {
+		if err := cl.Start(ctx); err != nil {
+			log.Error(err, "the informer cache of the cached ToolchainCluster failed")
+		}
+	}()
+	return cancel
+}
@@ -0,0 +1,211 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crcluster "sigs.k8s.io/controller-runtime/pkg/cluster"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestInformerCache(t *testing.T) {
+	// given
+	defer gock.Off()
+	status := test.NewClusterStatus(toolchainv1alpha1.ConditionReady, corev1.ConditionTrue)
+	member, sec := test.NewToolchainCluster(t, "member", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, false)
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	var created []*stubCluster
+	cache := NewClusterCache()
+	service := NewToolchainClusterServiceWithCache(cache, test.NewFakeClient(t, sec), logf.Log, test.HostOperatorNs, 0, nil,
+		WithInformerCache(ctx, InformerCacheOptions{Objects: []client.Object{&corev1.ConfigMap{}, &corev1.Secret{}}}))
+	service.newCluster = func(config *rest.Config, opts ...crcluster.Option) (crcluster.Cluster, error) {
+		stub := newStubCluster(t)
+		created = append(created, stub)
+		return stub, nil
+	}
+
+	// when
+	err := service.AddOrUpdateToolchainCluster(member)
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, created, 1)
+	first := created[0]
+	first.waitFor(t, first.started)
+	cachedCluster, ok := cache.GetCachedToolchainCluster("member")
+	require.True(t, ok)
+	assert.Same(t, first, cachedCluster.Cluster)
+	assert.Same(t, first.client, cachedCluster.Client)
+	assert.Len(t, first.informers.InformersByGVK, 2)
+
+	t.Run("cluster is reused when the config didn't change", func(t *testing.T) {
+		// when
+		err := service.AddOrUpdateToolchainCluster(member)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, created, 1)
+		cachedCluster, ok := cache.GetCachedToolchainCluster("member")
+		require.True(t, ok)
+		assert.Same(t, first, cachedCluster.Cluster)
+		first.assertRunning(t)
+	})
+
+	t.Run("cluster is replaced when the config changed", func(t *testing.T) {
+		// given
+		cachedCluster, _ := cache.GetCachedToolchainCluster("member")
+		cachedCluster.RestConfig.BearerToken = "old-token"
+
+		// when
+		err := service.AddOrUpdateToolchainCluster(member)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, created, 2)
+		first.waitFor(t, first.stopped)
+		created[1].waitFor(t, created[1].started)
+		cachedCluster, ok := cache.GetCachedToolchainCluster("member")
+		require.True(t, ok)
+		assert.Same(t, created[1], cachedCluster.Cluster)
+	})
+
+	t.Run("cluster is stopped when the ToolchainCluster is deleted", func(t *testing.T) {
+		// when
+		service.DeleteToolchainCluster("member")
+
+		// then
+		created[1].waitFor(t, created[1].stopped)
+	})
+
+	t.Run("cluster is not added when the informer cannot be created", func(t *testing.T) {
+		// given
+		service.newCluster = func(config *rest.Config, opts ...crcluster.Option) (crcluster.Cluster, error) {
+			stub := newStubCluster(t)
+			stub.informers.Error = fmt.Errorf("no informer")
+			return stub, nil
+		}
+
+		// when
+		err := service.AddOrUpdateToolchainCluster(member)
+
+		// then
+		require.EqualError(t, err, "the cluster was not added nor updated: cannot create ToolchainCluster client: "+
+			"cannot create informer for *v1.ConfigMap: no informer")
+		_, ok := cache.GetCachedToolchainCluster("member")
+		require.False(t, ok)
+	})
+}
+
+func TestInformerCacheStoppedWithContext(t *testing.T) {
+	// given
+	status := test.NewClusterStatus(toolchainv1alpha1.ConditionReady, corev1.ConditionTrue)
+	member, sec := test.NewToolchainCluster(t, "member", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, false)
+	ctx, cancel := context.WithCancel(context.TODO())
+	stub := newStubCluster(t)
+	service := NewToolchainClusterServiceWithCache(NewClusterCache(), test.NewFakeClient(t, sec), logf.Log, test.HostOperatorNs, 0, nil,
+		WithInformerCache(ctx, InformerCacheOptions{}))
+	service.newCluster = func(config *rest.Config, opts ...crcluster.Option) (crcluster.Cluster, error) {
+		return stub, nil
+	}
+	require.NoError(t, service.AddOrUpdateToolchainCluster(member))
+	stub.waitFor(t, stub.started)
+
+	// when
+	cancel()
+
+	// then
+	stub.waitFor(t, stub.stopped)
+}
+
+func TestInformerCacheClusterOptions(t *testing.T) {
+	// given
+	s := runtime.NewScheme()
+
+	t.Run("all namespaces", func(t *testing.T) {
+		// given
+		options := &crcluster.Options{}
+
+		// when
+		InformerCacheOptions{}.clusterOptions(s)(options)
+
+		// then
+		assert.Same(t, s, options.Scheme)
+		assert.True(t, options.Cache.ReaderFailOnMissingInformer)
+		assert.Empty(t, options.Cache.DefaultNamespaces)
+	})
+
+	t.Run("selected namespaces", func(t *testing.T) {
+		// given
+		options := &crcluster.Options{}
+
+		// when
+		InformerCacheOptions{Namespaces: []string{"toolchain-member-operator", "user-ns"}}.clusterOptions(s)(options)
+
+		// then
+		assert.Equal(t, map[string]cache.Config{"toolchain-member-operator": {}, "user-ns": {}}, options.Cache.DefaultNamespaces)
+	})
+}
+
+// stubCluster is a Cluster that only records when it's started and stopped
+type stubCluster struct {
+	crcluster.Cluster
+	informers *informertest.FakeInformers
+	client    client.Client
+	started   chan struct{}
+	stopped   chan struct{}
+}
+
+func newStubCluster(t *testing.T) *stubCluster {
+	return &stubCluster{
+		informers: &informertest.FakeInformers{Scheme: scheme.Scheme},
+		client:    test.NewFakeClient(t),
+		started:   make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+}
+
+func (c *stubCluster) GetCache() cache.Cache {
+	return c.informers
+}
+
+func (c *stubCluster) GetClient() client.Client {
+	return c.client
+}
+
+func (c *stubCluster) Start(ctx context.Context) error {
+	close(c.started)
+	<-ctx.Done()
+	close(c.stopped)
+	return nil
+}
+
+func (c *stubCluster) waitFor(t *testing.T, event chan struct{}) {
+	select {
+	case <-event:
+	case <-time.After(time.Second):
+		require.Fail(t, "the cluster was not started or stopped in time")
+	}
+}
+
+func (c *stubCluster) assertRunning(t *testing.T) {
+	select {
+	case <-c.stopped:
+		require.Fail(t, "the cluster is not running")
+	default:
+	}
+}
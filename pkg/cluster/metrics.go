@@ -0,0 +1,108 @@
+package cluster
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	k8smetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const metricsPrefix = "toolchain_cluster_cache_"
+
+var (
+	// CacheSizeGauge is the number of clusters in the default cluster cache
+	CacheSizeGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: metricsPrefix + "size",
+		Help: "Number of ToolchainClusters in the cache",
+	})
+
+	// CacheRefreshCounter is the number of the refreshes of the cluster caches
+	CacheRefreshCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: metricsPrefix + "refresh_total",
+		Help: "Number of the refreshes of the ToolchainCluster cache",
+	})
+
+	// CacheRefreshDurationHistogram is the duration of the refreshes of the cluster caches
+	CacheRefreshDurationHistogram = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    metricsPrefix + "refresh_duration_seconds",
+		Help:    "Duration of the refreshes of the ToolchainCluster cache",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 12),
+	})
+
+	// ClientRebuildCounterVec is the number of the cached clients replaced with new ones (eg. because of the rotated credentials), per cluster.
+	// Neither the client created when the cluster is added nor the client rejected by the rotation probe are counted.
+	ClientRebuildCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + "client_rebuild_total",
+		Help: "Number of the cached clients of the ToolchainClusters replaced with new ones",
+	}, []string{"cluster_name"})
+
+	// CapacityAllocatableCPUGaugeVec is the allocatable CPU (in cores) of the schedulable nodes, per cluster
+	CapacityAllocatableCPUGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: metricsPrefix + "capacity_allocatable_cpu_cores",
+		Help: "Allocatable CPU of the schedulable nodes of the cached ToolchainClusters",
+	}, []string{"cluster_name"})
+
+	// CapacityRequestedCPUGaugeVec is the CPU (in cores) requested by the pods, per cluster
+	CapacityRequestedCPUGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: metricsPrefix + "capacity_requested_cpu_cores",
+		Help: "CPU requested by the pods of the cached ToolchainClusters",
+	}, []string{"cluster_name"})
+
+	// CapacityAllocatableMemoryGaugeVec is the allocatable memory (in bytes) of the schedulable nodes, per cluster
+	CapacityAllocatableMemoryGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: metricsPrefix + "capacity_allocatable_memory_bytes",
+		Help: "Allocatable memory of the schedulable nodes of the cached ToolchainClusters",
+	}, []string{"cluster_name"})
+
+	// CapacityRequestedMemoryGaugeVec is the memory (in bytes) requested by the pods, per cluster
+	CapacityRequestedMemoryGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: metricsPrefix + "capacity_requested_memory_bytes",
+		Help: "Memory requested by the pods of the cached ToolchainClusters",
+	}, []string{"cluster_name"})
+
+	// CapacityNamespacesGaugeVec is the number of the namespaces, per cluster
+	CapacityNamespacesGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: metricsPrefix + "capacity_namespaces",
+		Help: "Number of the namespaces in the cached ToolchainClusters",
+	}, []string{"cluster_name"})
+
+	allMetrics = []prometheus.Collector{
+		CacheSizeGauge,
+		CacheRefreshCounter,
+		CacheRefreshDurationHistogram,
+		ClientRebuildCounterVec,
+		CapacityAllocatableCPUGaugeVec,
+		CapacityRequestedCPUGaugeVec,
+		CapacityAllocatableMemoryGaugeVec,
+		CapacityRequestedMemoryGaugeVec,
+		CapacityNamespacesGaugeVec,
+	}
+
+	registerMetrics sync.Once
+)
+
+// RegisterMetrics registers the metrics of the cluster cache in the controller-runtime metrics registry.
+// It's safe to call it multiple times - the metrics are registered only once.
+func RegisterMetrics() {
+	registerMetrics.Do(func() {
+		k8smetrics.Registry.MustRegister(allMetrics...)
+	})
+}
+
+// recordCapacity sets the given capacity snapshot of the cluster with the given name to the capacity metrics
+func recordCapacity(clusterName string, capacity *Capacity) {
+	CapacityAllocatableCPUGaugeVec.WithLabelValues(clusterName).Set(capacity.AllocatableCPU.AsApproximateFloat64())
+	CapacityRequestedCPUGaugeVec.WithLabelValues(clusterName).Set(capacity.RequestedCPU.AsApproximateFloat64())
+	CapacityAllocatableMemoryGaugeVec.WithLabelValues(clusterName).Set(capacity.AllocatableMemory.AsApproximateFloat64())
+	CapacityRequestedMemoryGaugeVec.WithLabelValues(clusterName).Set(capacity.RequestedMemory.AsApproximateFloat64())
+	CapacityNamespacesGaugeVec.WithLabelValues(clusterName).Set(float64(capacity.Namespaces))
+}
+
+// forgetCapacity deletes the capacity metrics of the cluster with the given name
+func forgetCapacity(clusterName string) {
+	CapacityAllocatableCPUGaugeVec.DeleteLabelValues(clusterName)
+	CapacityRequestedCPUGaugeVec.DeleteLabelValues(clusterName)
+	CapacityAllocatableMemoryGaugeVec.DeleteLabelValues(clusterName)
+	CapacityRequestedMemoryGaugeVec.DeleteLabelValues(clusterName)
+	CapacityNamespacesGaugeVec.DeleteLabelValues(clusterName)
+}
@@ -0,0 +1,170 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	commonclient "github.com/codeready-toolchain/toolchain-common/pkg/client"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// MultiClusterClient executes the same operation in multiple clusters concurrently and reports the result of every cluster,
+// so the failure in one cluster doesn't prevent the operation from being executed in the others
+type MultiClusterClient struct {
+	clusters       []*CachedToolchainCluster
+	maxConcurrency int
+	timeout        time.Duration
+}
+
+// MultiClusterClientOption an option to configure the MultiClusterClient
+type MultiClusterClientOption func(*MultiClusterClient)
+
+// WithMaxConcurrency limits the number of the clusters the operation is executed in at the same time.
+// If not set (or zero), then the operation is executed in all the clusters at the same time.
+func WithMaxConcurrency(maxConcurrency int) MultiClusterClientOption {
+	return func(c *MultiClusterClient) {
+		c.maxConcurrency = maxConcurrency
+	}
+}
+
+// WithClusterTimeout sets the timeout of the operation executed in one cluster. If not set (or zero), then only the deadline
+// of the context given to the operation applies.
+func WithClusterTimeout(timeout time.Duration) MultiClusterClientOption {
+	return func(c *MultiClusterClient) {
+		c.timeout = timeout
+	}
+}
+
+// NewMultiClusterClient returns a MultiClusterClient executing the operations in the given clusters,
+// eg. NewMultiClusterClient(GetMemberClusters(Ready))
+func NewMultiClusterClient(clusters []*CachedToolchainCluster, opts ...MultiClusterClientOption) *MultiClusterClient {
+	c := &MultiClusterClient{
+		clusters: clusters,
+	}
+	for _, apply := range opts {
+		apply(c)
+	}
+	return c
+}
+
+// ClusterResult is the result of an operation executed in one cluster
+type ClusterResult struct {
+	ClusterName string
+	// Object is the copy of the object (or of the list) the operation was executed with in the cluster
+	Object runtime.Object
+	Err    error
+}
+
+// MultiClusterResults are the results of an operation executed in multiple clusters, sorted by the cluster names
+type MultiClusterResults []ClusterResult
+
+// Succeeded returns the results of the clusters the operation succeeded in
+func (r MultiClusterResults) Succeeded() MultiClusterResults {
+	return r.filter(func(result ClusterResult) bool {
+		return result.Err == nil
+	})
+}
+
+// Failed returns the results of the clusters the operation failed in
+func (r MultiClusterResults) Failed() MultiClusterResults {
+	return r.filter(func(result ClusterResult) bool {
+		return result.Err != nil
+	})
+}
+
+func (r MultiClusterResults) filter(match func(ClusterResult) bool) MultiClusterResults {
+	filtered := MultiClusterResults{}
+	for _, result := range r {
+		if match(result) {
+			filtered = append(filtered, result)
+		}
+	}
+	return filtered
+}
+
+// Err returns an error aggregating the errors of all the clusters the operation failed in, or nil if it succeeded everywhere
+func (r MultiClusterResults) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(failed))
+	for i, result := range failed {
+		msgs[i] = fmt.Sprintf("%s: %s", result.ClusterName, result.Err)
+	}
+	return fmt.Errorf("the operation failed in %d of %d cluster(s): %s", len(failed), len(r), strings.Join(msgs, "; "))
+}
+
+// Get gets the object with the given key from all the clusters. Every cluster gets its own copy of the given obj.
+func (c *MultiClusterClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) MultiClusterResults {
+	return c.execute(ctx, obj, func(ctx context.Context, cluster *CachedToolchainCluster, obj runtime.Object) error {
+		return cluster.Client.Get(ctx, key, obj.(client.Object), opts...)
+	})
+}
+
+// List lists the objects in all the clusters. Every cluster gets its own copy of the given list.
+func (c *MultiClusterClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) MultiClusterResults {
+	return c.execute(ctx, list, func(ctx context.Context, cluster *CachedToolchainCluster, list runtime.Object) error {
+		return cluster.Client.List(ctx, list.(client.ObjectList), opts...)
+	})
+}
+
+// Delete deletes the given object from all the clusters
+func (c *MultiClusterClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) MultiClusterResults {
+	return c.execute(ctx, obj, func(ctx context.Context, cluster *CachedToolchainCluster, obj runtime.Object) error {
+		return cluster.Client.Delete(ctx, obj.(client.Object), opts...)
+	})
+}
+
+// Apply applies the given object to all the clusters using the server-side apply with the given field owner.
+// Every cluster gets its own copy of the given obj.
+func (c *MultiClusterClient) Apply(ctx context.Context, obj client.Object, fieldOwner string, opts ...commonclient.SSAApplyObjectOption) MultiClusterResults {
+	return c.execute(ctx, obj, func(ctx context.Context, cluster *CachedToolchainCluster, obj runtime.Object) error {
+		return commonclient.NewSSAApplyClient(cluster.Client, fieldOwner).ApplyObject(ctx, obj.(client.Object), opts...)
+	})
+}
+
+// execute runs the given operation in all the clusters (with the bounded parallelism) and waits for all the results
+func (c *MultiClusterClient) execute(ctx context.Context, obj runtime.Object, operation func(context.Context, *CachedToolchainCluster, runtime.Object) error) MultiClusterResults {
+	sorted := make([]*CachedToolchainCluster, len(c.clusters))
+	copy(sorted, c.clusters)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	results := make(MultiClusterResults, len(sorted))
+	limit := c.maxConcurrency
+	if limit <= 0 {
+		limit = len(sorted)
+	}
+	slots := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, cluster := range sorted {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			copied := obj.DeepCopyObject()
+			results[i] = ClusterResult{ClusterName: cluster.Name, Object: copied}
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				results[i].Err = ctx.Err()
+				return
+			}
+			clusterCtx := ctx
+			if c.timeout > 0 {
+				var cancel context.CancelFunc
+				clusterCtx, cancel = context.WithTimeout(ctx, c.timeout)
+				defer cancel()
+			}
+			results[i].Err = operation(clusterCtx, cluster, copied)
+		}()
+	}
+	wg.Wait()
+	return results
+}
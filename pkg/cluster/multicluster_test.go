@@ -0,0 +1,201 @@
+package cluster_test
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestMultiClusterClient(t *testing.T) {
+	// given
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: test.MemberOperatorNs},
+		Data:       map[string]string{"key": "value"},
+	}
+	newClusters := func(t *testing.T) (*test.FakeClient, *test.FakeClient, *test.FakeClient, []*cluster.CachedToolchainCluster) {
+		member1Client := test.NewFakeClient(t, cm.DeepCopy())
+		member2Client := test.NewFakeClient(t)
+		brokenClient := test.NewFakeClient(t, cm.DeepCopy())
+		brokenClient.MockGet = func(context.Context, runtimeclient.ObjectKey, runtimeclient.Object, ...runtimeclient.GetOption) error {
+			return fmt.Errorf("connection refused")
+		}
+		brokenClient.MockList = func(context.Context, runtimeclient.ObjectList, ...runtimeclient.ListOption) error {
+			return fmt.Errorf("connection refused")
+		}
+		brokenClient.MockDelete = func(context.Context, runtimeclient.Object, ...runtimeclient.DeleteOption) error {
+			return fmt.Errorf("connection refused")
+		}
+		return member1Client, member2Client, brokenClient, []*cluster.CachedToolchainCluster{
+			newCluster("member2", withClient(member2Client)),
+			newCluster("broken", withClient(brokenClient)),
+			newCluster("member1", withClient(member1Client)),
+		}
+	}
+
+	t.Run("get", func(t *testing.T) {
+		// given
+		_, _, _, clusters := newClusters(t)
+
+		// when
+		results := cluster.NewMultiClusterClient(clusters).Get(context.TODO(), runtimeclient.ObjectKeyFromObject(cm), &corev1.ConfigMap{})
+
+		// then
+		assertResultClusters(t, results, "broken", "member1", "member2")
+		require.EqualError(t, results[0].Err, "connection refused")
+		require.NoError(t, results[1].Err)
+		assert.Equal(t, "value", results[1].Object.(*corev1.ConfigMap).Data["key"])
+		require.True(t, apierrors.IsNotFound(results[2].Err))
+		assertResultClusters(t, results.Succeeded(), "member1")
+		assertResultClusters(t, results.Failed(), "broken", "member2")
+		require.EqualError(t, results.Err(), `the operation failed in 2 of 3 cluster(s): broken: connection refused; member2: configmaps "cm" not found`)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		// given
+		_, _, _, clusters := newClusters(t)
+
+		// when
+		results := cluster.NewMultiClusterClient(clusters).List(context.TODO(), &corev1.ConfigMapList{}, runtimeclient.InNamespace(test.MemberOperatorNs))
+
+		// then
+		assertResultClusters(t, results, "broken", "member1", "member2")
+		require.EqualError(t, results[0].Err, "connection refused")
+		assert.Len(t, results[1].Object.(*corev1.ConfigMapList).Items, 1)
+		assert.Empty(t, results[2].Object.(*corev1.ConfigMapList).Items)
+		assertResultClusters(t, results.Failed(), "broken")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		// given
+		member1Client, _, _, clusters := newClusters(t)
+
+		// when
+		results := cluster.NewMultiClusterClient(clusters).Delete(context.TODO(), cm)
+
+		// then
+		assertResultClusters(t, results.Failed(), "broken", "member2")
+		err := member1Client.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(cm), &corev1.ConfigMap{})
+		require.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		// given
+		member1Client, member2Client, _, clusters := newClusters(t)
+		updated := cm.DeepCopy()
+		updated.Data["key"] = "updated"
+
+		// when
+		results := cluster.NewMultiClusterClient(clusters).Apply(context.TODO(), updated, "test-owner")
+
+		// then
+		assertResultClusters(t, results.Failed(), "broken")
+		for _, cl := range []*test.FakeClient{member1Client, member2Client} {
+			inCluster := &corev1.ConfigMap{}
+			require.NoError(t, cl.Get(context.TODO(), runtimeclient.ObjectKeyFromObject(cm), inCluster))
+			assert.Equal(t, "updated", inCluster.Data["key"])
+		}
+		assert.Empty(t, updated.GetObjectKind().GroupVersionKind().Kind, "the given object should not be modified")
+	})
+
+	t.Run("no clusters", func(t *testing.T) {
+		// when
+		results := cluster.NewMultiClusterClient(nil).Get(context.TODO(), runtimeclient.ObjectKeyFromObject(cm), &corev1.ConfigMap{})
+
+		// then
+		assert.Empty(t, results)
+		require.NoError(t, results.Err())
+	})
+}
+
+func TestMultiClusterClientConcurrency(t *testing.T) {
+	// given
+	var inFlight, maxInFlight int32
+	slowClient := func(t *testing.T) *test.FakeClient {
+		cl := test.NewFakeClient(t)
+		cl.MockGet = func(ctx context.Context, _ runtimeclient.ObjectKey, _ runtimeclient.Object, _ ...runtimeclient.GetOption) error {
+			current := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				observed := atomic.LoadInt32(&maxInFlight)
+				if current <= observed || atomic.CompareAndSwapInt32(&maxInFlight, observed, current) {
+					break
+				}
+			}
+			select {
+			case <-time.After(20 * time.Millisecond):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return cl
+	}
+	var clusters []*cluster.CachedToolchainCluster
+	for i := 0; i < 6; i++ {
+		clusters = append(clusters, newCluster(fmt.Sprintf("member%d", i), withClient(slowClient(t))))
+	}
+
+	t.Run("bounded parallelism", func(t *testing.T) {
+		// when
+		results := cluster.NewMultiClusterClient(clusters, cluster.WithMaxConcurrency(2)).
+			Get(context.TODO(), runtimeclient.ObjectKey{Name: "cm"}, &corev1.ConfigMap{})
+
+		// then
+		require.NoError(t, results.Err())
+		assert.Len(t, results, 6)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&maxInFlight))
+	})
+
+	t.Run("per-cluster timeout", func(t *testing.T) {
+		// when
+		results := cluster.NewMultiClusterClient(clusters, cluster.WithClusterTimeout(time.Millisecond)).
+			Get(context.TODO(), runtimeclient.ObjectKey{Name: "cm"}, &corev1.ConfigMap{})
+
+		// then
+		require.Len(t, results.Failed(), 6)
+		for _, result := range results {
+			require.ErrorIs(t, result.Err, context.DeadlineExceeded)
+		}
+	})
+
+	t.Run("canceled context", func(t *testing.T) {
+		// given
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+
+		// when
+		results := cluster.NewMultiClusterClient(clusters, cluster.WithMaxConcurrency(1)).
+			Get(ctx, runtimeclient.ObjectKey{Name: "cm"}, &corev1.ConfigMap{})
+
+		// then
+		require.Len(t, results.Failed(), 6)
+		for _, result := range results {
+			require.ErrorIs(t, result.Err, context.Canceled)
+		}
+	})
+}
+
+func withClient(cl runtimeclient.Client) clusterModifier {
+	return func(c *cluster.CachedToolchainCluster) {
+		c.Client = cl
+	}
+}
+
+func assertResultClusters(t *testing.T, results cluster.MultiClusterResults, expectedNames ...string) {
+	names := make([]string, len(results))
+	for i, result := range results {
+		names[i] = result.ClusterName
+	}
+	assert.Equal(t, expectedNames, names)
+}
@@ -0,0 +1,165 @@
+package cluster
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// OfflineSource defines the clusters loaded from local kubeconfig files instead of the ToolchainClusters and their Secrets
+type OfflineSource struct {
+	// Path is either a kubeconfig file or a directory of kubeconfig files.
+	// In the case of a single file, every context defines one cluster (named after the context).
+	// In the case of a directory, the current context of every file defines one cluster (named after the file without the extension).
+	Path string
+	// Labels are the labels of the clusters (indexed by the cluster names), eg. the RoleLabel(Host) or RoleLabel(Member) labels
+	Labels map[string]map[string]string
+}
+
+// NewOfflineToolchainClusterService creates a ToolchainClusterService that stores the clusters defined by the given source in the given cache.
+// The clusters are loaded right away and reloaded every time the cache is refreshed. As there is nothing that would check
+// the health of the clusters, they are considered Ready. The clusters whose kubeconfig file (or context) was removed
+// are removed from the cache with the next reload.
+func NewOfflineToolchainClusterService(cache *ClusterCache, log logr.Logger, source OfflineSource, timeout time.Duration, opts ...ServiceOption) (ToolchainClusterService, error) {
+	opts = append(opts, func(s *ToolchainClusterService) {
+		s.offline = &offlineClusters{source: source}
+	})
+	service := NewToolchainClusterServiceWithCache(cache, nil, log, "", timeout, nil, opts...)
+	return service, service.loadOfflineClusters()
+}
+
+// offlineClusters keeps the names of the clusters loaded from the offline source, so the removed ones can be evicted from the cache
+type offlineClusters struct {
+	sync.Mutex
+	source OfflineSource
+	loaded map[string]bool
+}
+
+// loadOfflineClusters adds (or updates) all the clusters defined by the offline source to the cache and removes the clusters
+// that are not defined by the source anymore. The clusters that cannot be loaded are skipped - if they were loaded before,
+// then the previous version is kept in the cache.
+func (s *ToolchainClusterService) loadOfflineClusters() error {
+	s.offline.Lock()
+	defer s.offline.Unlock()
+	source := s.offline.source
+	clusters, err := source.load()
+	if err != nil {
+		return err
+	}
+	var failed []string
+	defined := make(map[string]bool, len(clusters))
+	for _, cluster := range clusters {
+		defined[cluster.name] = true
+		log := s.log.WithValues("Request.Name", cluster.name, "Path", cluster.path)
+		if cluster.err != nil {
+			log.Error(cluster.err, "the cluster was not loaded")
+			failed = append(failed, cluster.name)
+			continue
+		}
+		toolchainCluster := &toolchainv1alpha1.ToolchainCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   cluster.name,
+				Labels: source.Labels[cluster.name],
+			},
+			Status: toolchainv1alpha1.ToolchainClusterStatus{
+				Conditions: []toolchainv1alpha1.Condition{{
+					Type:    toolchainv1alpha1.ConditionReady,
+					Status:  corev1.ConditionTrue,
+					Reason:  toolchainv1alpha1.ToolchainClusterClusterReadyReason,
+					Message: "the cluster is loaded from a kubeconfig file",
+				}},
+			},
+		}
+		if err := s.addToolchainClusterWithConfig(log, toolchainCluster, newConfig(toolchainCluster, cluster.credentials, s.timeout)); err != nil {
+			log.Error(err, "the cluster was not added")
+			failed = append(failed, cluster.name)
+		}
+	}
+	for name := range s.offline.loaded {
+		if !defined[name] {
+			s.DeleteToolchainCluster(name)
+		}
+	}
+	s.offline.loaded = defined
+	if len(failed) > 0 {
+		return fmt.Errorf("the cluster(s) loaded from %s were not added: %s", source.Path, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// offlineCluster is a cluster defined by the offline source. The err is set if its credentials couldn't be loaded.
+type offlineCluster struct {
+	name        string
+	path        string
+	credentials *Credentials
+	err         error
+}
+
+// load reads the credentials of all the clusters defined by the kubeconfig file(s)
+func (o *OfflineSource) load() ([]offlineCluster, error) {
+	info, err := os.Stat(o.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load the offline clusters")
+	}
+	if !info.IsDir() {
+		return loadKubeConfigContexts(o.Path)
+	}
+	entries, err := os.ReadDir(o.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot load the offline clusters")
+	}
+	var clusters []offlineCluster
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(o.Path, entry.Name())
+		cluster := offlineCluster{
+			name: strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())),
+			path: path,
+		}
+		if cfg, err := clientcmd.LoadFromFile(path); err != nil {
+			cluster.err = errors.Wrapf(err, "cannot load the kubeconfig file %s", path)
+		} else if cluster.credentials, err = kubeConfigCredentials(*cfg, ""); err != nil {
+			cluster.err = errors.Wrapf(err, "cannot load the credentials from the kubeconfig file %s", path)
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters, nil
+}
+
+// loadKubeConfigContexts reads the credentials of all the contexts of the given kubeconfig file
+func loadKubeConfigContexts(path string) ([]offlineCluster, error) {
+	cfg, err := clientcmd.LoadFromFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot load the kubeconfig file %s", path)
+	}
+	contexts := make([]string, 0, len(cfg.Contexts))
+	for name := range cfg.Contexts {
+		contexts = append(contexts, name)
+	}
+	sort.Strings(contexts)
+	clusters := make([]offlineCluster, 0, len(contexts))
+	for _, name := range contexts {
+		cluster := offlineCluster{
+			name: name,
+			path: path,
+		}
+		if cluster.credentials, err = kubeConfigCredentials(*cfg, name); err != nil {
+			cluster.err = errors.Wrapf(err, "cannot load the credentials of the context %s from the kubeconfig file %s", name, path)
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters, nil
+}
@@ -0,0 +1,188 @@
+package cluster_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestOfflineToolchainClusterService(t *testing.T) {
+	// given
+	labels := map[string]map[string]string{
+		"host":    {cluster.RoleLabel(cluster.Host): ""},
+		"member1": {cluster.RoleLabel(cluster.Member): ""},
+		"member2": {cluster.RoleLabel(cluster.Member): ""},
+	}
+
+	t.Run("from a multi-context kubeconfig file", func(t *testing.T) {
+		// given
+		path := filepath.Join(t.TempDir(), "kubeconfig")
+		writeKubeConfig(t, path,
+			kubeConfigContext{name: "host", server: "https://host.com", namespace: "toolchain-host-operator"},
+			kubeConfigContext{name: "member1", server: "https://member1.com", namespace: "toolchain-member-operator"},
+			kubeConfigContext{name: "member2", server: "https://member2.com", namespace: "toolchain-member-operator"})
+		cache := cluster.NewClusterCache()
+
+		// when
+		_, err := cluster.NewOfflineToolchainClusterService(cache, logf.Log, cluster.OfflineSource{Path: path, Labels: labels}, 3*time.Second)
+
+		// then
+		require.NoError(t, err)
+		host, err := cache.LookupHostCluster()
+		require.NoError(t, err)
+		assert.Equal(t, "https://host.com", host.APIEndpoint)
+		assert.Equal(t, "toolchain-host-operator", host.OperatorNamespace)
+		assert.Equal(t, 3*time.Second, host.RestConfig.Timeout)
+		assert.NotNil(t, host.Client)
+		members := cache.GetMemberClusters(cluster.Ready, cluster.HasRole(cluster.Member))
+		require.Len(t, members, 2)
+		assert.Equal(t, "member1", members[0].Name)
+		assert.Equal(t, "https://member1.com", members[0].APIEndpoint)
+		assert.Equal(t, "member2", members[1].Name)
+		assert.Equal(t, "toolchain-member-operator", members[1].OperatorNamespace)
+	})
+
+	t.Run("from a directory of kubeconfig files", func(t *testing.T) {
+		// given
+		dir := t.TempDir()
+		writeKubeConfig(t, filepath.Join(dir, "host.yaml"), kubeConfigContext{name: "ctx", server: "https://host.com", namespace: "toolchain-host-operator"})
+		writeKubeConfig(t, filepath.Join(dir, "member1"), kubeConfigContext{name: "ctx", server: "https://member1.com", namespace: "toolchain-member-operator"})
+		require.NoError(t, os.WriteFile(filepath.Join(dir, ".hidden"), []byte("not a kubeconfig"), 0600))
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "subdir"), 0700))
+		cache := cluster.NewClusterCache()
+
+		// when
+		_, err := cluster.NewOfflineToolchainClusterService(cache, logf.Log, cluster.OfflineSource{Path: dir, Labels: labels}, 0)
+
+		// then
+		require.NoError(t, err)
+		host, err := cache.LookupHostCluster()
+		require.NoError(t, err)
+		assert.Equal(t, "host", host.Name)
+		members := cache.GetMemberClusters(cluster.Ready, cluster.HasRole(cluster.Member))
+		require.Len(t, members, 1)
+		assert.Equal(t, "member1", members[0].Name)
+		assert.Equal(t, "https://member1.com", members[0].APIEndpoint)
+
+		t.Run("clusters are reloaded when the cache is refreshed", func(t *testing.T) {
+			// given
+			writeKubeConfig(t, filepath.Join(dir, "member2.kubeconfig"), kubeConfigContext{name: "ctx", server: "https://member2.com", namespace: "toolchain-member-operator"})
+
+			// when
+			member2, found := cache.GetCachedToolchainCluster("member2")
+
+			// then
+			require.True(t, found)
+			assert.Equal(t, "https://member2.com", member2.APIEndpoint)
+		})
+
+		t.Run("clusters are removed when their kubeconfig files are removed", func(t *testing.T) {
+			// given
+			require.NoError(t, os.Remove(filepath.Join(dir, "member1")))
+
+			// when
+			_, found := cache.GetCachedToolchainCluster("unknown") // triggers the refresh
+
+			// then
+			assert.False(t, found)
+			_, found = cache.GetCachedToolchainCluster("member1")
+			assert.False(t, found)
+			_, found = cache.GetCachedToolchainCluster("member2")
+			assert.True(t, found)
+		})
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		t.Run("missing path", func(t *testing.T) {
+			// when
+			_, err := cluster.NewOfflineToolchainClusterService(cluster.NewClusterCache(), logf.Log, cluster.OfflineSource{Path: filepath.Join(t.TempDir(), "missing")}, 0)
+
+			// then
+			require.ErrorContains(t, err, "cannot load the offline clusters")
+		})
+
+		t.Run("invalid kubeconfig file is skipped", func(t *testing.T) {
+			// given
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "member1"), []byte("not a kubeconfig"), 0600))
+			writeKubeConfig(t, filepath.Join(dir, "member2"), kubeConfigContext{name: "ctx", server: "https://member2.com", namespace: "toolchain-member-operator"})
+			cache := cluster.NewClusterCache()
+
+			// when
+			_, err := cluster.NewOfflineToolchainClusterService(cache, logf.Log, cluster.OfflineSource{Path: dir}, 0)
+
+			// then
+			require.EqualError(t, err, "the cluster(s) loaded from "+dir+" were not added: member1")
+			_, found := cache.GetCachedToolchainCluster("member1")
+			assert.False(t, found)
+			_, found = cache.GetCachedToolchainCluster("member2")
+			assert.True(t, found)
+		})
+
+		t.Run("previously loaded cluster is kept when its kubeconfig file becomes invalid", func(t *testing.T) {
+			// given
+			dir := t.TempDir()
+			writeKubeConfig(t, filepath.Join(dir, "member1"), kubeConfigContext{name: "ctx", server: "https://member1.com", namespace: "toolchain-member-operator"})
+			cache := cluster.NewClusterCache()
+			_, err := cluster.NewOfflineToolchainClusterService(cache, logf.Log, cluster.OfflineSource{Path: dir}, 0)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "member1"), []byte("not a kubeconfig"), 0600))
+
+			// when
+			_, found := cache.GetCachedToolchainCluster("unknown") // triggers the refresh
+
+			// then
+			assert.False(t, found)
+			member1, found := cache.GetCachedToolchainCluster("member1")
+			require.True(t, found)
+			assert.Equal(t, "https://member1.com", member1.APIEndpoint)
+		})
+
+		t.Run("context without cluster is skipped", func(t *testing.T) {
+			// given
+			path := filepath.Join(t.TempDir(), "kubeconfig")
+			require.NoError(t, clientcmd.WriteToFile(clientcmdapi.Config{
+				Clusters:  map[string]*clientcmdapi.Cluster{"valid": {Server: "https://valid.com"}},
+				AuthInfos: map[string]*clientcmdapi.AuthInfo{"valid": {Token: "mycooltoken"}},
+				Contexts: map[string]*clientcmdapi.Context{
+					"broken": {Cluster: "missing", AuthInfo: "missing"},
+					"valid":  {Cluster: "valid", AuthInfo: "valid", Namespace: "toolchain-member-operator"},
+				},
+			}, path))
+			cache := cluster.NewClusterCache()
+
+			// when
+			_, err := cluster.NewOfflineToolchainClusterService(cache, logf.Log, cluster.OfflineSource{Path: path}, 0)
+
+			// then
+			require.EqualError(t, err, "the cluster(s) loaded from "+path+" were not added: broken")
+			_, found := cache.GetCachedToolchainCluster("valid")
+			assert.True(t, found)
+		})
+	})
+}
+
+type kubeConfigContext struct {
+	name      string
+	server    string
+	namespace string
+}
+
+func writeKubeConfig(t *testing.T, path string, contexts ...kubeConfigContext) {
+	cfg := clientcmdapi.NewConfig()
+	for _, c := range contexts {
+		cfg.Clusters[c.name] = &clientcmdapi.Cluster{Server: c.server}
+		cfg.AuthInfos[c.name] = &clientcmdapi.AuthInfo{Token: "mycooltoken"}
+		cfg.Contexts[c.name] = &clientcmdapi.Context{Cluster: c.name, AuthInfo: c.name, Namespace: c.namespace}
+		cfg.CurrentContext = c.name
+	}
+	require.NoError(t, clientcmd.WriteToFile(*cfg, path))
+}
@@ -0,0 +1,237 @@
+package cluster
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+)
+
+// CapacityUsageAnnotationKey is the annotation of the ToolchainCluster containing the percentage (0-100) of the used capacity of the cluster.
+// It's used by the LeastLoaded scorer.
+const CapacityUsageAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "capacity-usage"
+
+// MaxScore is the maximum score a Scorer should give to a cluster. The minimum score is 0.
+const MaxScore = 100.0
+
+// Score is a score given by a Scorer to a single cluster, along with the reason of the score
+type Score struct {
+	Value  float64
+	Reason string
+}
+
+// Scorer scores all the candidate clusters of a single placement. It returns the scores in the same order as the candidates.
+// The scores are expected to be in the range from 0 to MaxScore, the higher the better.
+type Scorer func(candidates []*CachedToolchainCluster) []Score
+
+// ScoreExplanation explains the contribution of a single scorer to the total score of a cluster
+type ScoreExplanation struct {
+	Scorer string
+	Weight float64
+	Score
+}
+
+// RankedCluster is a cluster with its total score and the explanation how the score was computed
+type RankedCluster struct {
+	Cluster      *CachedToolchainCluster
+	Score        float64
+	Explanations []ScoreExplanation
+}
+
+// String returns a human-readable explanation of the score of the cluster
+func (c RankedCluster) String() string {
+	parts := make([]string, len(c.Explanations))
+	for i, e := range c.Explanations {
+		parts[i] = fmt.Sprintf("%s: %.2f*%.2f (%s)", e.Scorer, e.Weight, e.Value, e.Reason)
+	}
+	return fmt.Sprintf("%s=%.2f [%s]", c.Cluster.Name, c.Score, strings.Join(parts, ", "))
+}
+
+type weightedScorer struct {
+	name   string
+	weight float64
+	score  Scorer
+}
+
+// Placement ranks the candidate clusters using a set of weighted scorers
+type Placement struct {
+	scorers []weightedScorer
+}
+
+// NewPlacement returns a new Placement without any scorer. Such a placement ranks the clusters by their names only.
+func NewPlacement() *Placement {
+	return &Placement{}
+}
+
+// With adds the given scorer with the given name (used in the explanations) and weight to the placement
+func (p *Placement) With(name string, weight float64, scorer Scorer) *Placement {
+	p.scorers = append(p.scorers, weightedScorer{name: name, weight: weight, score: scorer})
+	return p
+}
+
+// Rank scores the given candidates and returns them ordered from the best to the worst one.
+// The clusters having the same total score are ordered by their names.
+func (p *Placement) Rank(candidates []*CachedToolchainCluster) []RankedCluster {
+	ranked := make([]RankedCluster, len(candidates))
+	for i, cluster := range candidates {
+		ranked[i] = RankedCluster{Cluster: cluster}
+	}
+	for _, scorer := range p.scorers {
+		scores := scorer.score(candidates)
+		for i := range ranked {
+			score := Score{Reason: "not scored"}
+			if i < len(scores) {
+				score = scores[i]
+			}
+			ranked[i].Score += scorer.weight * score.Value
+			ranked[i].Explanations = append(ranked[i].Explanations, ScoreExplanation{
+				Scorer: scorer.name,
+				Weight: scorer.weight,
+				Score:  score,
+			})
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Cluster.Name < ranked[j].Cluster.Name
+	})
+	return ranked
+}
+
+// Place ranks the clusters from the given cache matching all the given conditions
+func (p *Placement) Place(cache *ClusterCache, conditions ...Condition) []RankedCluster {
+	return p.Rank(cache.GetMemberClusters(conditions...))
+}
+
+// perCluster converts the given function scoring a single cluster to a Scorer
+func perCluster(score func(cluster *CachedToolchainCluster) Score) Scorer {
+	return func(candidates []*CachedToolchainCluster) []Score {
+		scores := make([]Score, len(candidates))
+		for i, cluster := range candidates {
+			scores[i] = score(cluster)
+		}
+		return scores
+	}
+}
+
+// LeastLoaded prefers the clusters with the lowest capacity usage defined by the CapacityUsageAnnotationKey annotation.
+// The clusters without a valid annotation get the score 0.
+func LeastLoaded() Scorer {
+	return perCluster(func(cluster *CachedToolchainCluster) Score {
+		usage, err := capacityUsage(cluster)
+		if err != nil {
+			return Score{Reason: err.Error()}
+		}
+		return Score{Value: MaxScore - usage, Reason: fmt.Sprintf("capacity usage is %.2f%%", usage)}
+	})
+}
+
+func capacityUsage(cluster *CachedToolchainCluster) (float64, error) {
+	if cluster.Config == nil {
+		return 0, fmt.Errorf("no config available")
+	}
+	value, found := cluster.Annotations[CapacityUsageAnnotationKey]
+	if !found {
+		return 0, fmt.Errorf("the annotation %s is not set", CapacityUsageAnnotationKey)
+	}
+	usage, err := strconv.ParseFloat(value, 64)
+	if err != nil || usage < 0 || usage > 100 {
+		return 0, fmt.Errorf("invalid value of the annotation %s: '%s'", CapacityUsageAnnotationKey, value)
+	}
+	return usage, nil
+}
+
+// RoundRobin rotates the preference over the candidates (ordered by name) with every placement,
+// so the consecutive placements prefer different clusters
+func RoundRobin() Scorer {
+	var lock sync.Mutex
+	next := 0
+	return func(candidates []*CachedToolchainCluster) []Score {
+		scores := make([]Score, len(candidates))
+		if len(candidates) == 0 {
+			return scores
+		}
+		lock.Lock()
+		offset := next % len(candidates)
+		next++
+		lock.Unlock()
+
+		byName := make([]int, len(candidates))
+		for i := range byName {
+			byName[i] = i
+		}
+		sort.SliceStable(byName, func(i, j int) bool {
+			return candidates[byName[i]].Name < candidates[byName[j]].Name
+		})
+		for position, index := range byName {
+			turn := (position - offset + len(candidates)) % len(candidates)
+			scores[index] = Score{
+				Value:  MaxScore * float64(len(candidates)-turn) / float64(len(candidates)),
+				Reason: fmt.Sprintf("round-robin turn %d of %d", turn+1, len(candidates)),
+			}
+		}
+		return scores
+	}
+}
+
+// LabelAffinity prefers the clusters having the given label with the given value
+func LabelAffinity(key, value string) Scorer {
+	return perCluster(func(cluster *CachedToolchainCluster) Score {
+		if hasLabel(cluster, key, value) {
+			return Score{Value: MaxScore, Reason: fmt.Sprintf("has label %s=%s", key, value)}
+		}
+		return Score{Reason: fmt.Sprintf("does not have label %s=%s", key, value)}
+	})
+}
+
+// LabelAntiAffinity prefers the clusters not having the given label with the given value
+func LabelAntiAffinity(key, value string) Scorer {
+	return perCluster(func(cluster *CachedToolchainCluster) Score {
+		if hasLabel(cluster, key, value) {
+			return Score{Reason: fmt.Sprintf("has label %s=%s", key, value)}
+		}
+		return Score{Value: MaxScore, Reason: fmt.Sprintf("does not have label %s=%s", key, value)}
+	})
+}
+
+func hasLabel(cluster *CachedToolchainCluster, key, value string) bool {
+	if cluster.Config == nil {
+		return false
+	}
+	actual, found := cluster.Labels[key]
+	return found && actual == value
+}
+
+// WeightedRandom orders the candidates randomly so that the probability of a cluster to get the highest score
+// is proportional to its weight. The clusters with a non-positive weight get the score 0.
+func WeightedRandom(random *rand.Rand, weight func(cluster *CachedToolchainCluster) float64) Scorer {
+	var lock sync.Mutex
+	return perCluster(func(cluster *CachedToolchainCluster) Score {
+		w := weight(cluster)
+		if w <= 0 {
+			return Score{Reason: fmt.Sprintf("weight %.2f", w)}
+		}
+		lock.Lock()
+		r := random.Float64()
+		lock.Unlock()
+		// weighted random sampling (Efraimidis-Spirakis): the key r^(1/w) favors clusters with higher weights
+		return Score{Value: MaxScore * math.Pow(r, 1/w), Reason: fmt.Sprintf("weight %.2f", w)}
+	})
+}
+
+// OwnerClusterLocality prefers the clusters owned by the cluster with the given name (see Config.OwnerClusterName)
+func OwnerClusterLocality(ownerClusterName string) Scorer {
+	return perCluster(func(cluster *CachedToolchainCluster) Score {
+		if cluster.Config != nil && cluster.OwnerClusterName == ownerClusterName {
+			return Score{Value: MaxScore, Reason: fmt.Sprintf("owned by %s", ownerClusterName)}
+		}
+		return Score{Reason: fmt.Sprintf("not owned by %s", ownerClusterName)}
+	})
+}
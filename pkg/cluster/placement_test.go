@@ -0,0 +1,214 @@
+package cluster_test
+
+import (
+	"math/rand"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlacementWithoutScorers(t *testing.T) {
+	// given
+	c := newCluster("c")
+	a := newCluster("a")
+	b := newCluster("b")
+
+	// when
+	ranked := cluster.NewPlacement().Rank([]*cluster.CachedToolchainCluster{c, a, b})
+
+	// then
+	assertRanking(t, ranked, "a", "b", "c")
+	assert.Empty(t, ranked[0].Explanations)
+}
+
+func TestLeastLoaded(t *testing.T) {
+	// given
+	full := newCluster("full", withAnnotation(cluster.CapacityUsageAnnotationKey, "90"))
+	empty := newCluster("empty", withAnnotation(cluster.CapacityUsageAnnotationKey, "10.5"))
+	unknown := newCluster("unknown")
+	invalid := newCluster("invalid", withAnnotation(cluster.CapacityUsageAnnotationKey, "120"))
+
+	// when
+	ranked := cluster.NewPlacement().
+		With("least-loaded", 1, cluster.LeastLoaded()).
+		Rank([]*cluster.CachedToolchainCluster{full, unknown, empty, invalid})
+
+	// then
+	assertRanking(t, ranked, "empty", "full", "invalid", "unknown")
+	assert.InDelta(t, 89.5, ranked[0].Score, 0.001)
+	assert.Equal(t, "capacity usage is 10.50%", ranked[0].Explanations[0].Reason)
+	assert.InDelta(t, 10, ranked[1].Score, 0.001)
+	assert.Equal(t, "invalid value of the annotation toolchain.dev.openshift.com/capacity-usage: '120'", ranked[2].Explanations[0].Reason)
+	assert.Equal(t, "the annotation toolchain.dev.openshift.com/capacity-usage is not set", ranked[3].Explanations[0].Reason)
+}
+
+func TestRoundRobin(t *testing.T) {
+	// given
+	candidates := []*cluster.CachedToolchainCluster{newCluster("c"), newCluster("a"), newCluster("b")}
+	placement := cluster.NewPlacement().With("round-robin", 1, cluster.RoundRobin())
+
+	// when & then
+	assertRanking(t, placement.Rank(candidates), "a", "b", "c")
+	assertRanking(t, placement.Rank(candidates), "b", "c", "a")
+	assertRanking(t, placement.Rank(candidates), "c", "a", "b")
+	assertRanking(t, placement.Rank(candidates), "a", "b", "c")
+	assert.Empty(t, placement.Rank(nil))
+}
+
+func TestLabelAffinity(t *testing.T) {
+	// given
+	gpu := newCluster("gpu", withLabel("gpu", "true"))
+	noGPU := newCluster("no-gpu", withLabel("gpu", "false"))
+	plain := newCluster("plain")
+
+	t.Run("affinity", func(t *testing.T) {
+		// when
+		ranked := cluster.NewPlacement().
+			With("gpu", 1, cluster.LabelAffinity("gpu", "true")).
+			Rank([]*cluster.CachedToolchainCluster{plain, noGPU, gpu})
+
+		// then
+		assertRanking(t, ranked, "gpu", "no-gpu", "plain")
+		assert.Equal(t, "has label gpu=true", ranked[0].Explanations[0].Reason)
+	})
+
+	t.Run("anti-affinity", func(t *testing.T) {
+		// when
+		ranked := cluster.NewPlacement().
+			With("no-gpu", 1, cluster.LabelAntiAffinity("gpu", "true")).
+			Rank([]*cluster.CachedToolchainCluster{gpu, plain, noGPU})
+
+		// then
+		assertRanking(t, ranked, "no-gpu", "plain", "gpu")
+		assert.Equal(t, "has label gpu=true", ranked[2].Explanations[0].Reason)
+	})
+}
+
+func TestWeightedRandom(t *testing.T) {
+	// given
+	heavy := newCluster("heavy", withLabel("weight", "heavy"))
+	light := newCluster("light", withLabel("weight", "light"))
+	disabled := newCluster("disabled")
+	weight := func(c *cluster.CachedToolchainCluster) float64 {
+		switch c.Labels["weight"] {
+		case "heavy":
+			return 9
+		case "light":
+			return 1
+		}
+		return 0
+	}
+	placement := cluster.NewPlacement().With("random", 1, cluster.WeightedRandom(rand.New(rand.NewSource(42)), weight)) // nolint:gosec
+
+	// when
+	first := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		ranked := placement.Rank([]*cluster.CachedToolchainCluster{light, disabled, heavy})
+		require.Len(t, ranked, 3)
+		assert.Equal(t, "disabled", ranked[2].Cluster.Name)
+		first[ranked[0].Cluster.Name]++
+	}
+
+	// then
+	assert.Greater(t, first["heavy"], 800)
+	assert.Greater(t, first["light"], 20)
+}
+
+func TestOwnerClusterLocality(t *testing.T) {
+	// given
+	local := newCluster("local", func(c *cluster.CachedToolchainCluster) {
+		c.OwnerClusterName = "host-1"
+	})
+	remote := newCluster("remote", func(c *cluster.CachedToolchainCluster) {
+		c.OwnerClusterName = "host-2"
+	})
+
+	// when
+	ranked := cluster.NewPlacement().
+		With("locality", 1, cluster.OwnerClusterLocality("host-1")).
+		Rank([]*cluster.CachedToolchainCluster{remote, local})
+
+	// then
+	assertRanking(t, ranked, "local", "remote")
+	assert.Equal(t, "owned by host-1", ranked[0].Explanations[0].Reason)
+}
+
+func TestComposedScorers(t *testing.T) {
+	// given
+	loadedLocal := newCluster("loaded-local", withAnnotation(cluster.CapacityUsageAnnotationKey, "80"), func(c *cluster.CachedToolchainCluster) {
+		c.OwnerClusterName = "host"
+	})
+	emptyRemote := newCluster("empty-remote", withAnnotation(cluster.CapacityUsageAnnotationKey, "0"))
+	emptyLocal := newCluster("empty-local", withAnnotation(cluster.CapacityUsageAnnotationKey, "5"), func(c *cluster.CachedToolchainCluster) {
+		c.OwnerClusterName = "host"
+	})
+
+	// when
+	ranked := cluster.NewPlacement().
+		With("least-loaded", 2, cluster.LeastLoaded()).
+		With("locality", 1, cluster.OwnerClusterLocality("host")).
+		Rank([]*cluster.CachedToolchainCluster{loadedLocal, emptyRemote, emptyLocal})
+
+	// then
+	assertRanking(t, ranked, "empty-local", "empty-remote", "loaded-local")
+	assert.InDelta(t, 290, ranked[0].Score, 0.001)
+	assert.InDelta(t, 200, ranked[1].Score, 0.001)
+	assert.InDelta(t, 140, ranked[2].Score, 0.001)
+	assert.Equal(t, "empty-local=290.00 [least-loaded: 2.00*95.00 (capacity usage is 5.00%), locality: 1.00*100.00 (owned by host)]", ranked[0].String())
+}
+
+func TestPlaceFromCache(t *testing.T) {
+	// given
+	cache := cluster.NewClusterCache()
+
+	// when
+	ranked := cluster.NewPlacement().With("round-robin", 1, cluster.RoundRobin()).Place(cache, cluster.Ready)
+
+	// then
+	assert.Empty(t, ranked)
+}
+
+func assertRanking(t *testing.T, ranked []cluster.RankedCluster, expectedNames ...string) {
+	t.Helper()
+	names := make([]string, len(ranked))
+	for i, r := range ranked {
+		names[i] = r.Cluster.Name
+	}
+	assert.Equal(t, expectedNames, names)
+}
+
+type clusterModifier func(*cluster.CachedToolchainCluster)
+
+func withLabel(key, value string) clusterModifier {
+	return func(c *cluster.CachedToolchainCluster) {
+		if c.Labels == nil {
+			c.Labels = map[string]string{}
+		}
+		c.Labels[key] = value
+	}
+}
+
+func withAnnotation(key, value string) clusterModifier {
+	return func(c *cluster.CachedToolchainCluster) {
+		if c.Annotations == nil {
+			c.Annotations = map[string]string{}
+		}
+		c.Annotations[key] = value
+	}
+}
+
+func newCluster(name string, modifiers ...clusterModifier) *cluster.CachedToolchainCluster {
+	c := &cluster.CachedToolchainCluster{
+		Config: &cluster.Config{
+			Name: name,
+		},
+		ClusterStatus: &toolchainv1alpha1.ToolchainClusterStatus{},
+	}
+	for _, modify := range modifiers {
+		modify(c)
+	}
+	return c
+}
@@ -0,0 +1,120 @@
+package cluster
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"k8s.io/client-go/rest"
+)
+
+// the annotations of the ToolchainCluster overriding the RateLimits of the cached client
+const (
+	// QPSAnnotationKey is the annotation of the ToolchainCluster containing the maximum QPS of the cached client
+	QPSAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "client-qps"
+	// BurstAnnotationKey is the annotation of the ToolchainCluster containing the maximum burst of the cached client
+	BurstAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "client-burst"
+	// MaxConcurrentRequestsAnnotationKey is the annotation of the ToolchainCluster containing the maximum number
+	// of the requests the cached client sends to the cluster at the same time
+	MaxConcurrentRequestsAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "client-max-concurrent-requests"
+)
+
+// RateLimits configures the client-side rate limiting of the client cached for a ToolchainCluster.
+// The zero values mean that the client-go defaults are used (QPS & Burst) and that the concurrency is not limited.
+//
+// The limits are applied only to the cached Client - the Config.RestConfig stays unlimited, so the health checks
+// (which create their own clients from the RestConfig) have a dedicated lane and are never starved by the bulk traffic
+// sent via the cached Client.
+type RateLimits struct {
+	// QPS is the maximum number of queries per second
+	QPS float32
+	// Burst is the maximum burst of the queries
+	Burst int
+	// MaxConcurrentRequests is the maximum number of the requests waiting for the response at the same time
+	MaxConcurrentRequests int
+}
+
+// WithDefaultRateLimits sets the RateLimits used for all the cached clients.
+// The individual limits can be overridden per ToolchainCluster by the QPSAnnotationKey, BurstAnnotationKey
+// and MaxConcurrentRequestsAnnotationKey annotations. If any of the annotations is invalid, then all of them are ignored.
+func WithDefaultRateLimits(limits RateLimits) ServiceOption {
+	return func(s *ToolchainClusterService) {
+		s.rateLimits = limits
+	}
+}
+
+// rateLimitsFor returns the given default limits overridden by the annotations of the given ToolchainCluster
+func rateLimitsFor(toolchainCluster *toolchainv1alpha1.ToolchainCluster, defaults RateLimits) (RateLimits, error) {
+	limits := defaults
+	if value, found := toolchainCluster.Annotations[QPSAnnotationKey]; found {
+		qps, err := strconv.ParseFloat(value, 32)
+		if err != nil || qps < 0 {
+			return limits, fmt.Errorf("invalid value of the annotation %s: '%s'", QPSAnnotationKey, value)
+		}
+		limits.QPS = float32(qps)
+	}
+	var err error
+	if limits.Burst, err = intAnnotation(toolchainCluster, BurstAnnotationKey, limits.Burst); err != nil {
+		return limits, err
+	}
+	if limits.MaxConcurrentRequests, err = intAnnotation(toolchainCluster, MaxConcurrentRequestsAnnotationKey, limits.MaxConcurrentRequests); err != nil {
+		return limits, err
+	}
+	return limits, nil
+}
+
+func intAnnotation(toolchainCluster *toolchainv1alpha1.ToolchainCluster, key string, defaultValue int) (int, error) {
+	value, found := toolchainCluster.Annotations[key]
+	if !found {
+		return defaultValue, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		return defaultValue, fmt.Errorf("invalid value of the annotation %s: '%s'", key, value)
+	}
+	return number, nil
+}
+
+// apply returns a copy of the given config with the limits applied
+func (l RateLimits) apply(config *rest.Config) *rest.Config {
+	limited := rest.CopyConfig(config)
+	if l.QPS > 0 {
+		limited.QPS = l.QPS
+	}
+	if l.Burst > 0 {
+		limited.Burst = l.Burst
+	}
+	if l.MaxConcurrentRequests > 0 {
+		// the limiter is created per config, so all the requests sent by the client share the same limit
+		requests := make(chan struct{}, l.MaxConcurrentRequests)
+		limited.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return &concurrencyLimiter{requests: requests, delegate: rt}
+		})
+	}
+	return limited
+}
+
+// concurrencyLimiter limits the number of the requests waiting for the response at the same time.
+// The slot is released as soon as the response headers are received, so the long-running watches don't block the other requests.
+type concurrencyLimiter struct {
+	requests chan struct{}
+	delegate http.RoundTripper
+}
+
+func (l *concurrencyLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case l.requests <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	defer func() {
+		<-l.requests
+	}()
+	return l.delegate.RoundTrip(req)
+}
+
+// WrappedRoundTripper returns the delegate so the limiter can be unwrapped by the client-go utilities
+func (l *concurrencyLimiter) WrappedRoundTripper() http.RoundTripper {
+	return l.delegate
+}
@@ -0,0 +1,235 @@
+package cluster
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func TestRateLimitsFor(t *testing.T) {
+	// given
+	defaults := RateLimits{QPS: 20, Burst: 30, MaxConcurrentRequests: 10}
+
+	t.Run("defaults are used when no annotation is set", func(t *testing.T) {
+		// when
+		limits, err := rateLimitsFor(&toolchainv1alpha1.ToolchainCluster{}, defaults)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, defaults, limits)
+	})
+
+	t.Run("annotations override the defaults", func(t *testing.T) {
+		// given
+		toolchainCluster := &toolchainv1alpha1.ToolchainCluster{}
+		toolchainCluster.Annotations = map[string]string{
+			QPSAnnotationKey:                   "2.5",
+			BurstAnnotationKey:                 "5",
+			MaxConcurrentRequestsAnnotationKey: "0",
+		}
+
+		// when
+		limits, err := rateLimitsFor(toolchainCluster, defaults)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, RateLimits{QPS: 2.5, Burst: 5}, limits)
+	})
+
+	t.Run("invalid annotations", func(t *testing.T) {
+		for key, value := range map[string]string{
+			QPSAnnotationKey:                   "fast",
+			BurstAnnotationKey:                 "-1",
+			MaxConcurrentRequestsAnnotationKey: "1.5",
+		} {
+			t.Run(key, func(t *testing.T) {
+				// given
+				toolchainCluster := &toolchainv1alpha1.ToolchainCluster{}
+				toolchainCluster.Annotations = map[string]string{key: value}
+
+				// when
+				_, err := rateLimitsFor(toolchainCluster, defaults)
+
+				// then
+				require.EqualError(t, err, "invalid value of the annotation "+key+": '"+value+"'")
+			})
+		}
+	})
+}
+
+func TestRateLimitsApply(t *testing.T) {
+	// given
+	config := &rest.Config{Host: "https://cluster.com"}
+
+	t.Run("no limits", func(t *testing.T) {
+		// when
+		limited := RateLimits{}.apply(config)
+
+		// then
+		assert.Equal(t, config, limited)
+		assert.NotSame(t, config, limited)
+	})
+
+	t.Run("with limits", func(t *testing.T) {
+		// when
+		limited := RateLimits{QPS: 50, Burst: 100, MaxConcurrentRequests: 5}.apply(config)
+
+		// then
+		assert.InDelta(t, 50, limited.QPS, 0.001)
+		assert.Equal(t, 100, limited.Burst)
+		assert.NotNil(t, limited.WrapTransport)
+		// the original config is not changed
+		assert.Zero(t, config.QPS)
+		assert.Zero(t, config.Burst)
+		assert.Nil(t, config.WrapTransport)
+	})
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	// given
+	release := make(chan struct{})
+	var inFlight, maxInFlight int32
+	delegate := roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		current := atomic.AddInt32(&inFlight, 1)
+		for {
+			observed := atomic.LoadInt32(&maxInFlight)
+			if current <= observed || atomic.CompareAndSwapInt32(&maxInFlight, observed, current) {
+				break
+			}
+		}
+		<-release
+		atomic.AddInt32(&inFlight, -1)
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	})
+	limiter := &concurrencyLimiter{requests: make(chan struct{}, 2), delegate: delegate}
+
+	t.Run("limits the number of the requests in flight", func(t *testing.T) {
+		// given
+		done := make(chan struct{})
+		for i := 0; i < 5; i++ {
+			go func() {
+				defer func() { done <- struct{}{} }()
+				req, _ := http.NewRequest(http.MethodGet, "https://cluster.com", nil)
+				_, _ = limiter.RoundTrip(req) // nolint:bodyclose
+			}()
+		}
+
+		// when
+		require.Eventually(t, func() bool {
+			return atomic.LoadInt32(&inFlight) == 2
+		}, time.Second, time.Millisecond)
+		close(release)
+		for i := 0; i < 5; i++ {
+			<-done
+		}
+
+		// then
+		assert.Equal(t, int32(2), atomic.LoadInt32(&maxInFlight))
+	})
+
+	t.Run("waiting request is canceled", func(t *testing.T) {
+		// given
+		full := &concurrencyLimiter{requests: make(chan struct{}, 1), delegate: delegate}
+		full.requests <- struct{}{}
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "https://cluster.com", nil)
+
+		// when
+		_, err := full.RoundTrip(req) // nolint:bodyclose
+
+		// then
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func TestCachedClientRateLimits(t *testing.T) {
+	// given
+	defer gock.Off()
+	status := test.NewClusterStatus(toolchainv1alpha1.ConditionReady, corev1.ConditionTrue)
+	limited, sec := test.NewToolchainCluster(t, "limited", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, false)
+	var clientConfigs []*rest.Config
+	cache := NewClusterCache()
+	service := NewToolchainClusterServiceWithCache(cache, test.NewFakeClient(t, sec), logf.Log, test.HostOperatorNs, 0,
+		func(config *rest.Config, options client.Options) (client.Client, error) {
+			clientConfigs = append(clientConfigs, config)
+			return test.NewFakeClient(t), nil
+		}, WithDefaultRateLimits(RateLimits{QPS: 20, Burst: 30}))
+
+	// when
+	err := service.AddOrUpdateToolchainCluster(limited)
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, clientConfigs, 1)
+	assert.InDelta(t, 20, clientConfigs[0].QPS, 0.001)
+	assert.Equal(t, 30, clientConfigs[0].Burst)
+	cachedCluster, ok := cache.GetCachedToolchainCluster("limited")
+	require.True(t, ok)
+	assert.Equal(t, RateLimits{QPS: 20, Burst: 30}, cachedCluster.RateLimits)
+	// the rest config used for the health checks is not limited
+	assert.Zero(t, cachedCluster.RestConfig.QPS)
+	assert.Zero(t, cachedCluster.RestConfig.Burst)
+
+	t.Run("client is not rebuilt when the limits didn't change", func(t *testing.T) {
+		// when
+		err := service.AddOrUpdateToolchainCluster(limited)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, clientConfigs, 1)
+	})
+
+	t.Run("client is rebuilt when the limits changed", func(t *testing.T) {
+		// given
+		limited.Annotations = map[string]string{
+			BurstAnnotationKey:                 "100",
+			MaxConcurrentRequestsAnnotationKey: "3",
+		}
+
+		// when
+		err := service.AddOrUpdateToolchainCluster(limited)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, clientConfigs, 2)
+		assert.InDelta(t, 20, clientConfigs[1].QPS, 0.001)
+		assert.Equal(t, 100, clientConfigs[1].Burst)
+		assert.NotNil(t, clientConfigs[1].WrapTransport)
+	})
+
+	t.Run("defaults are used when an annotation is invalid", func(t *testing.T) {
+		// given
+		limited.Annotations = map[string]string{QPSAnnotationKey: "fast", BurstAnnotationKey: "100"}
+
+		// when
+		err := service.AddOrUpdateToolchainCluster(limited)
+
+		// then
+		require.NoError(t, err)
+		require.Len(t, clientConfigs, 3)
+		assert.InDelta(t, 20, clientConfigs[2].QPS, 0.001)
+		assert.Equal(t, 30, clientConfigs[2].Burst)
+		cachedCluster, ok := cache.GetCachedToolchainCluster("limited")
+		require.True(t, ok)
+		assert.Equal(t, RateLimits{QPS: 20, Burst: 30}, cachedCluster.RateLimits)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,57 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// ClientProbe checks that the cluster can be accessed using the given config.
+// It's used for verifying the rotated credentials before the cached client is replaced.
+type ClientProbe func(ctx context.Context, config *rest.Config) error
+
+// HealthzProbe is the default ClientProbe that checks that the "/healthz" endpoint of the cluster responds with "ok"
+func HealthzProbe(ctx context.Context, config *rest.Config) error {
+	clientset, err := kubeclientset.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+	body, err := clientset.DiscoveryClient.RESTClient().Get().AbsPath("/healthz").Do(ctx).Raw()
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(string(body), "ok") {
+		return fmt.Errorf("/healthz responded without ok")
+	}
+	return nil
+}
+
+// tokenExpiry returns the expiration time of the bearer token in the given config (if the token is a JWT containing the "exp" claim).
+// The signature of the token is not verified - the expiration time is used only for informational purposes.
+func tokenExpiry(config *rest.Config) *time.Time {
+	if config == nil || config.BearerToken == "" {
+		return nil
+	}
+	token, _, err := jwt.NewParser().ParseUnverified(config.BearerToken, jwt.MapClaims{})
+	if err != nil {
+		return nil
+	}
+	exp, err := token.Claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return nil
+	}
+	return &exp.Time
+}
+
+// TokenExpiresWithin checks that the bearer token used for accessing the cluster expires within the given duration,
+// so it can be refreshed ahead of the expiration. The clusters using a token without any expiration time don't match.
+func TokenExpiresWithin(d time.Duration) Condition {
+	return func(cluster *CachedToolchainCluster) bool {
+		return cluster.Config != nil && cluster.TokenExpiry != nil && time.Until(*cluster.TokenExpiry) <= d
+	}
+}
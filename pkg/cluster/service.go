@@ -14,8 +14,8 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	crcluster "sigs.k8s.io/controller-runtime/pkg/cluster"
 )
 
 const (
@@ -28,35 +28,69 @@ const (
 // ToolchainClusterService manages cached cluster kube clients and related ToolchainCluster CRDs
 // it's used for adding/updating/deleting
 type ToolchainClusterService struct {
-	client    client.Client
-	log       logr.Logger
-	namespace string
-	timeout   time.Duration
-	newClient NewClient
+	client     client.Client
+	log        logr.Logger
+	namespace  string
+	timeout    time.Duration
+	newClient  NewClient
+	cache      *ClusterCache
+	probe      ClientProbe
+	rateLimits RateLimits
+
+	informerCtx     context.Context
+	informerOptions *InformerCacheOptions
+	newCluster      NewCluster
+
+	offline *offlineClusters
 }
 
 type NewClient func(config *rest.Config, options client.Options) (client.Client, error)
 
+// ServiceOption an option to configure the ToolchainClusterService
+type ServiceOption func(*ToolchainClusterService)
+
+// WithClientProbe sets the probe that is used for verifying the new client when the credentials of an already cached
+// cluster are rotated, eg. HealthzProbe. The probe is bound by the timeout of the service.
+// By default, there is no probe and the new client is used without any verification.
+func WithClientProbe(probe ClientProbe) ServiceOption {
+	return func(s *ToolchainClusterService) {
+		s.probe = probe
+	}
+}
+
 // NewToolchainClusterServiceWithClient creates a new instance of ToolchainClusterService object and assigns the given newClient function to be used for creating a client
 func NewToolchainClusterServiceWithClient(client client.Client, log logr.Logger, namespace string, timeout time.Duration, newClient NewClient) ToolchainClusterService {
-	service := NewToolchainClusterService(client, log, namespace, timeout)
-	service.newClient = newClient
-	clusterCache.refreshCache = service.refreshCache
-	return service
+	return NewToolchainClusterServiceWithCache(clusterCache, client, log, namespace, timeout, newClient)
 }
 
-// NewToolchainClusterService creates a new instance of ToolchainClusterService object and assigns the refreshCache function to the cache instance
+// NewToolchainClusterService creates a new instance of ToolchainClusterService object and assigns the refreshCache function to the default cache instance
 func NewToolchainClusterService(client client.Client, log logr.Logger, namespace string, timeout time.Duration) ToolchainClusterService {
+	return NewToolchainClusterServiceWithCache(clusterCache, client, log, namespace, timeout, nil)
+}
+
+// NewToolchainClusterServiceWithCache creates a new instance of ToolchainClusterService object that stores the clusters in the given cache
+// and assigns the refreshCache function to that cache instance. The newClient function is optional - if nil, then client.New is used
+func NewToolchainClusterServiceWithCache(cache *ClusterCache, client client.Client, log logr.Logger, namespace string, timeout time.Duration, newClient NewClient, opts ...ServiceOption) ToolchainClusterService {
 	service := ToolchainClusterService{
 		client:    client,
 		log:       log,
 		namespace: namespace,
 		timeout:   timeout,
+		newClient: newClient,
+		cache:     cache,
+	}
+	for _, apply := range opts {
+		apply(&service)
 	}
-	clusterCache.refreshCache = service.refreshCache
+	cache.refreshCache = service.refreshCache
 	return service
 }
 
+// Cache returns the cache the service stores the clusters in
+func (s *ToolchainClusterService) Cache() *ClusterCache {
+	return s.cache
+}
+
 // AddOrUpdateToolchainCluster takes the ToolchainCluster CR object,
 // creates CachedToolchainCluster with a kube client and stores it in a cache
 func (s *ToolchainClusterService) AddOrUpdateToolchainCluster(cluster *toolchainv1alpha1.ToolchainCluster) error {
@@ -81,59 +115,141 @@ func (s *ToolchainClusterService) addToolchainCluster(log logr.Logger, toolchain
 	if err != nil {
 		return errors.Wrap(err, "cannot create ToolchainCluster Config")
 	}
+	return s.addToolchainClusterWithConfig(log, toolchainCluster, clusterConfig)
+}
+
+func (s *ToolchainClusterService) addToolchainClusterWithConfig(log logr.Logger, toolchainCluster *toolchainv1alpha1.ToolchainCluster, clusterConfig *Config) error {
+	var err error
+	if clusterConfig.RateLimits, err = rateLimitsFor(toolchainCluster, s.rateLimits); err != nil {
+		// a typo in the annotation should not make the cluster unavailable
+		log.Error(err, "ignoring the rate limit annotations, using the default limits")
+		clusterConfig.RateLimits = s.rateLimits
+	}
 
 	var cl client.Client
+	var informerCluster crcluster.Cluster
+	var stopCluster context.CancelFunc
+	var rotated bool
 	// check if there is already a cached ToolchainCluster so we could reuse the client
 	// we cannot allow to refresh the cache, because the refresh function calls this addToolchainCluster method which results in a recursive loop
-	cachedToolchainCluster, exists := clusterCache.getCachedToolchainCluster(toolchainCluster.Name, false)
+	cachedToolchainCluster, exists := s.cache.getCachedToolchainCluster(toolchainCluster.Name, false)
+	// the client is rebuilt also when only the rate limits changed, but that's not a rotation of the credentials
+	restConfigChanged := exists && cachedToolchainCluster.Client != nil && !reflect.DeepEqual(clusterConfig.RestConfig, cachedToolchainCluster.RestConfig)
 	if !exists ||
 		cachedToolchainCluster.Client == nil ||
-		!reflect.DeepEqual(clusterConfig.RestConfig, cachedToolchainCluster.RestConfig) {
+		restConfigChanged ||
+		clusterConfig.RateLimits != cachedToolchainCluster.RateLimits {
 
 		log.Info("creating new client for the cached ToolchainCluster")
 		scheme := runtime.NewScheme()
 		if err := apis.AddToScheme(scheme); err != nil {
 			return err
 		}
-		if s.newClient == nil {
-			cl, err = client.New(clusterConfig.RestConfig, client.Options{
+		// the limits are applied only to the config of the cached client, see RateLimits
+		clientConfig := clusterConfig.RateLimits.apply(clusterConfig.RestConfig)
+		if s.informerOptions != nil {
+			informerCluster, err = s.newInformerCluster(clientConfig, scheme)
+			if err == nil {
+				cl = informerCluster.GetClient()
+			}
+		} else if s.newClient == nil {
+			cl, err = client.New(clientConfig, client.Options{
 				Scheme: scheme,
 			})
 		} else {
-			cl, err = s.newClient(clusterConfig.RestConfig, client.Options{
+			cl, err = s.newClient(clientConfig, client.Options{
 				Scheme: scheme,
 			})
 		}
 		if err != nil {
 			return errors.Wrap(err, "cannot create ToolchainCluster client")
 		}
+		// the credentials (or the endpoint) of an already cached cluster have changed, so make sure that the new client works
+		// before replacing the previous one - until then, the previous client is kept in the cache
+		if restConfigChanged && s.probe != nil {
+			if err := s.probeClient(clusterConfig.RestConfig); err != nil {
+				log.Error(err, "the client created for the rotated credentials failed the health probe, keeping the previous client")
+				s.cache.publishOrdered(ClusterEvent{
+					Type: ClusterCredentialsRotationFailed,
+					Old:  cachedToolchainCluster,
+					New:  &CachedToolchainCluster{Config: clusterConfig, Client: cl, ClusterStatus: &toolchainCluster.Status},
+				})
+				s.cache.addCachedToolchainCluster(&CachedToolchainCluster{
+					Config:        cachedToolchainCluster.Config,
+					Client:        cachedToolchainCluster.Client,
+					ClusterStatus: &toolchainCluster.Status,
+					Cluster:       cachedToolchainCluster.Cluster,
+					stopCluster:   cachedToolchainCluster.stopCluster,
+				})
+				return errors.Wrap(err, "the client created for the rotated credentials failed the health probe")
+			}
+			rotated = true
+		}
 	} else {
 		// log.Info("reusing the client for the cached ToolchainCluster")
 		cl = cachedToolchainCluster.Client
+		informerCluster = cachedToolchainCluster.Cluster
+		stopCluster = cachedToolchainCluster.stopCluster
 	}
 
 	cluster := &CachedToolchainCluster{
 		Config:        clusterConfig,
 		Client:        cl,
 		ClusterStatus: &toolchainCluster.Status,
+		Cluster:       informerCluster,
+		stopCluster:   stopCluster,
 	}
 
 	if cluster.OperatorNamespace == "" {
 		return fmt.Errorf("the operator namespace is not set for the ToolchainCluster CR")
 	}
+	if cluster.Cluster != nil && cluster.stopCluster == nil {
+		cluster.stopCluster = s.startInformerCluster(log, cluster.Cluster)
+	}
 
-	clusterCache.addCachedToolchainCluster(cluster)
+	s.cache.addCachedToolchainCluster(cluster)
+	if exists && cachedToolchainCluster.Client != nil && cachedToolchainCluster.Client != cl {
+		ClientRebuildCounterVec.WithLabelValues(toolchainCluster.Name).Inc()
+	}
+	if rotated {
+		log.Info("the credentials of the cached ToolchainCluster were rotated")
+		s.cache.publishOrdered(ClusterEvent{Type: ClusterCredentialsRotated, Old: cachedToolchainCluster, New: cluster})
+	}
 	return nil
 }
 
+// probeClient runs the probe bound by the timeout of the service (if any)
+func (s *ToolchainClusterService) probeClient(config *rest.Config) error {
+	ctx := context.TODO()
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	return s.probe(ctx, config)
+}
+
 // DeleteToolchainCluster takes the ToolchainCluster CR object
 // and deletes CachedToolchainCluster instance that has same name from a cache (if exists)
 func (s *ToolchainClusterService) DeleteToolchainCluster(name string) {
 	s.log.WithValues("Request.Name", name).Info("observed a deleted cluster")
-	clusterCache.deleteCachedToolchainCluster(name)
+	s.cache.deleteCachedToolchainCluster(name)
+	ClientRebuildCounterVec.DeleteLabelValues(name)
+	forgetCapacity(name)
 }
 
 func (s *ToolchainClusterService) refreshCache() {
+	start := time.Now()
+	defer func() {
+		CacheRefreshCounter.Inc()
+		CacheRefreshDurationHistogram.Observe(time.Since(start).Seconds())
+	}()
+	if s.offline != nil {
+		if err := s.loadOfflineClusters(); err != nil {
+			s.log.Error(err, "the cluster cache was not refreshed")
+		}
+		return
+	}
 	toolchainClusters := &toolchainv1alpha1.ToolchainClusterList{}
 	if err := s.client.List(context.TODO(), toolchainClusters, &client.ListOptions{Namespace: s.namespace}); err != nil {
 		s.log.Error(err, "the cluster cache was not refreshed")
@@ -155,6 +271,15 @@ func (s *ToolchainClusterService) enrichLogger(cluster *toolchainv1alpha1.Toolch
 
 // NewClusterConfig generate a new cluster config by fetching the necessary info the given ToolchainCluster's associated Secret and taking all data from ToolchainCluster CR
 func NewClusterConfig(cl client.Client, toolchainCluster *toolchainv1alpha1.ToolchainCluster, timeout time.Duration) (*Config, error) {
+	secret, err := getSecret(context.TODO(), cl, toolchainCluster)
+	if err != nil {
+		return nil, err
+	}
+	return loadConfig(toolchainCluster, secret, timeout)
+}
+
+// getSecret returns the Secret referenced by the given ToolchainCluster
+func getSecret(ctx context.Context, cl client.Client, toolchainCluster *toolchainv1alpha1.ToolchainCluster) (*v1.Secret, error) {
 	secretName := toolchainCluster.Spec.SecretRef.Name
 	if secretName == "" {
 		return nil, errors.Errorf("cluster %s does not have a secret name", toolchainCluster.Name)
@@ -164,41 +289,41 @@ func NewClusterConfig(cl client.Client, toolchainCluster *toolchainv1alpha1.Tool
 		Namespace: toolchainCluster.Namespace,
 		Name:      secretName,
 	}
-	err := cl.Get(context.TODO(), name, secret)
-	if err != nil {
+	if err := cl.Get(ctx, name, secret); err != nil {
 		return nil, errors.Wrapf(err, "unable to get secret %s for cluster %s", name, toolchainCluster.Name)
 	}
-
-	return loadConfigFromKubeConfig(toolchainCluster, secret, timeout)
+	return secret, nil
 }
 
-func loadConfigFromKubeConfig(toolchainCluster *toolchainv1alpha1.ToolchainCluster, secret *v1.Secret, timeout time.Duration) (*Config, error) {
-	cfg, err := clientcmd.Load(secret.Data["kubeconfig"])
+// loadConfig loads the config using the CredentialLoader selected by the CredentialSourceAnnotationKey annotation of the given ToolchainCluster
+func loadConfig(toolchainCluster *toolchainv1alpha1.ToolchainCluster, secret *v1.Secret, timeout time.Duration) (*Config, error) {
+	source := credentialSource(toolchainCluster)
+	loader, err := GetCredentialLoader(source)
 	if err != nil {
 		return nil, err
 	}
-	clientCfg := clientcmd.NewDefaultClientConfig(*cfg, &clientcmd.ConfigOverrides{})
-	restCfg, err := clientCfg.ClientConfig()
+	credentials, err := loader.Load(secret)
 	if err != nil {
 		return nil, err
 	}
+	return newConfig(toolchainCluster, credentials, timeout), nil
+}
 
+func newConfig(toolchainCluster *toolchainv1alpha1.ToolchainCluster, credentials *Credentials, timeout time.Duration) *Config {
+	restCfg := credentials.RestConfig
 	// This is questionable, but the timeout is currently configurable in the member configuration so let's keep it here...
 	restCfg.Timeout = timeout
 
-	operatorNamespace, _, err := clientCfg.Namespace()
-	if err != nil {
-		return nil, fmt.Errorf("could not determine the operator namespace from the current context in the provided kubeconfig because of: %w", err)
-	}
-
 	return &Config{
 		Name:              toolchainCluster.Name,
 		APIEndpoint:       restCfg.Host,
 		RestConfig:        restCfg,
-		OperatorNamespace: operatorNamespace,
+		OperatorNamespace: credentials.OperatorNamespace,
 		OwnerClusterName:  toolchainCluster.Labels[labelOwnerClusterName],
 		Labels:            toolchainCluster.Labels,
-	}, nil
+		Annotations:       toolchainCluster.Annotations,
+		TokenExpiry:       tokenExpiry(restCfg),
+	}
 }
 
 func IsReady(clusterStatus *toolchainv1alpha1.ToolchainClusterStatus) bool {
@@ -16,6 +16,7 @@ import (
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -225,4 +226,188 @@ func TestNewClusterConfig(t *testing.T) {
 		assert.Equal(t, "operatorns", cfg.OperatorNamespace)
 		assert.Equal(t, "token", cfg.RestConfig.BearerToken)
 	})
+
+	withSource := func(source string) *toolchainv1alpha1.ToolchainCluster {
+		tc := tc()
+		tc.Annotations = map[string]string{cluster.CredentialSourceAnnotationKey: source}
+		return tc
+	}
+
+	secretWith := func(data map[string]string) *corev1.Secret {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "secret",
+				Namespace: "ns",
+			},
+			Data: map[string][]byte{},
+		}
+		for k, v := range data {
+			secret.Data[k] = []byte(v)
+		}
+		return secret
+	}
+
+	t.Run("using explicit kubeconfig source", func(t *testing.T) {
+		tc := withSource(cluster.KubeConfigCredentialSource)
+		cl := test.NewFakeClient(t, tc, kubeconfigSecret(t))
+
+		cfg, err := cluster.NewClusterConfig(cl, tc, 1*time.Second)
+		require.NoError(t, err)
+
+		assert.Equal(t, "https://over.the.rainbow", cfg.APIEndpoint)
+		assert.Equal(t, "operatorns", cfg.OperatorNamespace)
+	})
+
+	t.Run("kubeconfig key missing", func(t *testing.T) {
+		tc := tc()
+		cl := test.NewFakeClient(t, tc, secretWith(map[string]string{"token": "token"}))
+
+		_, err := cluster.NewClusterConfig(cl, tc, 1*time.Second)
+
+		require.EqualError(t, err, "the secret secret is missing the required key(s) for the 'kubeconfig' credential source: kubeconfig")
+	})
+
+	t.Run("using token and CA", func(t *testing.T) {
+		tc := withSource(cluster.TokenCredentialSource)
+		cl := test.NewFakeClient(t, tc, secretWith(map[string]string{
+			"token":        "my-token",
+			"ca.crt":       "my-ca",
+			"api-endpoint": "https://api.cluster.com:6443",
+			"namespace":    "operatorns",
+		}))
+
+		cfg, err := cluster.NewClusterConfig(cl, tc, 1*time.Second)
+		require.NoError(t, err)
+
+		assert.Equal(t, "https://api.cluster.com:6443", cfg.APIEndpoint)
+		assert.Equal(t, "operatorns", cfg.OperatorNamespace)
+		assert.Equal(t, "my-token", cfg.RestConfig.BearerToken)
+		assert.Equal(t, []byte("my-ca"), cfg.RestConfig.CAData)
+		assert.Equal(t, time.Second, cfg.RestConfig.Timeout)
+	})
+
+	t.Run("token keys missing", func(t *testing.T) {
+		tc := withSource(cluster.TokenCredentialSource)
+		cl := test.NewFakeClient(t, tc, secretWith(map[string]string{"ca.crt": "my-ca"}))
+
+		_, err := cluster.NewClusterConfig(cl, tc, 1*time.Second)
+
+		require.EqualError(t, err, "the secret secret is missing the required key(s) for the 'token' credential source: token, api-endpoint, namespace")
+	})
+
+	t.Run("invalid api endpoint", func(t *testing.T) {
+		tc := withSource(cluster.TokenCredentialSource)
+		cl := test.NewFakeClient(t, tc, secretWith(map[string]string{
+			"token":        "my-token",
+			"api-endpoint": "https://api cluster.com",
+			"namespace":    "operatorns",
+		}))
+
+		_, err := cluster.NewClusterConfig(cl, tc, 1*time.Second)
+
+		require.ErrorContains(t, err, "invalid value of the 'api-endpoint' key")
+	})
+
+	t.Run("using client certificate", func(t *testing.T) {
+		tc := withSource(cluster.ClientCertificateCredentialSource)
+		cl := test.NewFakeClient(t, tc, secretWith(map[string]string{
+			"tls.crt":      "my-cert",
+			"tls.key":      "my-key",
+			"api-endpoint": "https://api.cluster.com:6443",
+			"namespace":    "operatorns",
+		}))
+
+		cfg, err := cluster.NewClusterConfig(cl, tc, 1*time.Second)
+		require.NoError(t, err)
+
+		assert.Equal(t, "https://api.cluster.com:6443", cfg.APIEndpoint)
+		assert.Equal(t, []byte("my-cert"), cfg.RestConfig.CertData)
+		assert.Equal(t, []byte("my-key"), cfg.RestConfig.KeyData)
+		assert.Empty(t, cfg.RestConfig.BearerToken)
+	})
+
+	t.Run("client certificate keys missing", func(t *testing.T) {
+		tc := withSource(cluster.ClientCertificateCredentialSource)
+		cl := test.NewFakeClient(t, tc, secretWith(map[string]string{
+			"tls.crt":      "my-cert",
+			"api-endpoint": "https://api.cluster.com:6443",
+		}))
+
+		_, err := cluster.NewClusterConfig(cl, tc, 1*time.Second)
+
+		require.EqualError(t, err, "the secret secret is missing the required key(s) for the 'client-cert' credential source: tls.key, namespace")
+	})
+
+	t.Run("using projected token file", func(t *testing.T) {
+		tc := withSource(cluster.TokenFileCredentialSource)
+		cl := test.NewFakeClient(t, tc, secretWith(map[string]string{
+			"token-file":   "/var/run/secrets/tokens/member-token",
+			"api-endpoint": "https://api.cluster.com:6443",
+			"namespace":    "operatorns",
+		}))
+
+		cfg, err := cluster.NewClusterConfig(cl, tc, 1*time.Second)
+		require.NoError(t, err)
+
+		assert.Equal(t, "/var/run/secrets/tokens/member-token", cfg.RestConfig.BearerTokenFile)
+		assert.Equal(t, "operatorns", cfg.OperatorNamespace)
+	})
+
+	t.Run("token file outside of the allowed directory", func(t *testing.T) {
+		for _, path := range []string{"/etc/passwd", "/var/run/secrets/tokens/../../kubernetes.io/serviceaccount/token", "/var/run/secrets/tokens", "member-token"} {
+			t.Run(path, func(t *testing.T) {
+				tc := withSource(cluster.TokenFileCredentialSource)
+				cl := test.NewFakeClient(t, tc, secretWith(map[string]string{
+					"token-file":   path,
+					"api-endpoint": "https://api.cluster.com:6443",
+					"namespace":    "operatorns",
+				}))
+
+				_, err := cluster.NewClusterConfig(cl, tc, 1*time.Second)
+
+				require.EqualError(t, err, "invalid value of the 'token-file' key: the token file has to be in the /var/run/secrets/tokens directory")
+			})
+		}
+	})
+
+	t.Run("unknown source", func(t *testing.T) {
+		tc := withSource("unknown")
+		cl := test.NewFakeClient(t, tc, kubeconfigSecret(t))
+
+		_, err := cluster.NewClusterConfig(cl, tc, 1*time.Second)
+
+		require.EqualError(t, err, "unknown credential source 'unknown', supported sources are: client-cert, kubeconfig, token, token-file")
+	})
+
+	t.Run("using custom loader", func(t *testing.T) {
+		cluster.RegisterCredentialLoader("custom", cluster.CredentialLoaderFunc(func(secret *corev1.Secret) (*cluster.Credentials, error) {
+			return &cluster.Credentials{
+				RestConfig:        &rest.Config{Host: "https://" + string(secret.Data["host"])},
+				OperatorNamespace: "custom-ns",
+			}, nil
+		}))
+		tc := withSource("custom")
+		cl := test.NewFakeClient(t, tc, secretWith(map[string]string{"host": "custom.com"}))
+
+		cfg, err := cluster.NewClusterConfig(cl, tc, 1*time.Second)
+		require.NoError(t, err)
+
+		assert.Equal(t, "https://custom.com", cfg.APIEndpoint)
+		assert.Equal(t, "custom-ns", cfg.OperatorNamespace)
+	})
+
+	t.Run("token file in a custom directory", func(t *testing.T) {
+		cluster.RegisterCredentialLoader("custom-token-file", cluster.NewTokenFileCredentialLoader("/tokens/"))
+		tc := withSource("custom-token-file")
+		cl := test.NewFakeClient(t, tc, secretWith(map[string]string{
+			"token-file":   "/tokens/member/token",
+			"api-endpoint": "https://api.cluster.com:6443",
+			"namespace":    "operatorns",
+		}))
+
+		cfg, err := cluster.NewClusterConfig(cl, tc, 1*time.Second)
+		require.NoError(t, err)
+
+		assert.Equal(t, "/tokens/member/token", cfg.RestConfig.BearerTokenFile)
+	})
 }
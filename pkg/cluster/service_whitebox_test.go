@@ -1,11 +1,15 @@
 package cluster
 
 import (
+	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	metricstest "github.com/codeready-toolchain/toolchain-common/pkg/test/metrics"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/h2non/gock.v1"
@@ -102,6 +106,10 @@ func TestUpdateClientBasedOnRestConfig(t *testing.T) {
 
 	t.Run("update when RestConfig is not the same", func(t *testing.T) {
 		// given
+		gock.New("https://cluster.com").
+			Get("healthz").
+			Reply(200).
+			BodyString("ok")
 		cl := test.NewFakeClient(t, sec1)
 		service := newToolchainClusterService(cl, 3*time.Second, test.HostOperatorNs)
 		defer service.DeleteToolchainCluster("east")
@@ -123,6 +131,286 @@ func TestUpdateClientBasedOnRestConfig(t *testing.T) {
 	})
 }
 
+func TestCredentialsRotation(t *testing.T) {
+	// given
+	defer gock.Off()
+	statusTrue := test.NewClusterStatus(toolchainv1alpha1.ConditionReady, corev1.ConditionTrue)
+	toolchainCluster, sec := test.NewToolchainCluster(t, "east", test.HostOperatorNs, test.HostOperatorNs, "secret", statusTrue, false)
+
+	setup := func(t *testing.T, probe ClientProbe) (*ClusterCache, ToolchainClusterService, *test.FakeClient, *[]ClusterEvent) {
+		cl := test.NewFakeClient(t, sec)
+		cache := NewClusterCache()
+		service := NewToolchainClusterServiceWithCache(cache, cl, logf.Log, test.HostOperatorNs, 3*time.Second, nil, WithClientProbe(probe))
+		require.NoError(t, service.AddOrUpdateToolchainCluster(toolchainCluster))
+		// let's pretend that the cached client uses an old token
+		cache.clusters["east"].Client = cl
+		cache.clusters["east"].RestConfig.BearerToken = "old-token"
+		events := &[]ClusterEvent{}
+		cache.Subscribe(func(event ClusterEvent) {
+			*events = append(*events, event)
+		})
+		return cache, service, cl, events
+	}
+
+	t.Run("client is replaced when the probe passes", func(t *testing.T) {
+		// given
+		var probedToken string
+		var probedWithDeadline bool
+		cache, service, cl, events := setup(t, func(ctx context.Context, config *rest.Config) error {
+			probedToken = config.BearerToken
+			_, probedWithDeadline = ctx.Deadline()
+			return nil
+		})
+
+		// when
+		err := service.AddOrUpdateToolchainCluster(toolchainCluster)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "mycooltoken", probedToken)
+		assert.True(t, probedWithDeadline)
+		cached, ok := cache.GetCachedToolchainCluster("east")
+		require.True(t, ok)
+		assert.NotEqual(t, cl, cached.Client)
+		assert.Equal(t, "mycooltoken", cached.RestConfig.BearerToken)
+		require.Len(t, *events, 2)
+		assert.Equal(t, ClusterUpdated, (*events)[0].Type)
+		assert.Equal(t, ClusterCredentialsRotated, (*events)[1].Type)
+		assert.Equal(t, cached, (*events)[1].New)
+	})
+
+	t.Run("previous client is kept when the probe fails", func(t *testing.T) {
+		// given
+		cache, service, cl, events := setup(t, func(_ context.Context, _ *rest.Config) error {
+			return fmt.Errorf("unauthorized")
+		})
+		updated := toolchainCluster.DeepCopy()
+		updated.Status = test.NewClusterStatus(toolchainv1alpha1.ConditionReady, corev1.ConditionFalse)
+		rebuilds := metricstest.GetCounterInt(ClientRebuildCounterVec.WithLabelValues("east"))
+
+		// when
+		err := service.AddOrUpdateToolchainCluster(updated)
+
+		// then
+		require.EqualError(t, err, "the cluster was not added nor updated: the client created for the rotated credentials failed the health probe: unauthorized")
+		metricstest.AssertMetricsCounterEquals(t, rebuilds, ClientRebuildCounterVec.WithLabelValues("east"))
+		cached, ok := cache.GetCachedToolchainCluster("east")
+		require.True(t, ok)
+		assert.Equal(t, cl, cached.Client)
+		assert.Equal(t, "old-token", cached.RestConfig.BearerToken)
+		assert.Equal(t, updated.Status, *cached.ClusterStatus)
+		require.Len(t, *events, 2)
+		assert.Equal(t, ClusterCredentialsRotationFailed, (*events)[0].Type)
+		assert.Equal(t, "mycooltoken", (*events)[0].New.RestConfig.BearerToken)
+		assert.Equal(t, ClusterReadinessChanged, (*events)[1].Type)
+	})
+
+	t.Run("no probe when only the rate limits changed", func(t *testing.T) {
+		// given
+		cache, service, cl, events := setup(t, func(_ context.Context, _ *rest.Config) error {
+			return fmt.Errorf("should not be called")
+		})
+		cache.clusters["east"].RestConfig.BearerToken = "mycooltoken"
+		limited := toolchainCluster.DeepCopy()
+		limited.Annotations = map[string]string{BurstAnnotationKey: "100"}
+
+		// when
+		err := service.AddOrUpdateToolchainCluster(limited)
+
+		// then
+		require.NoError(t, err)
+		cached, ok := cache.GetCachedToolchainCluster("east")
+		require.True(t, ok)
+		assert.NotEqual(t, cl, cached.Client)
+		assert.Equal(t, 100, cached.RateLimits.Burst)
+		require.Len(t, *events, 1)
+		assert.Equal(t, ClusterUpdated, (*events)[0].Type)
+	})
+
+	t.Run("no probe by default", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, sec)
+		cache := NewClusterCache()
+		service := NewToolchainClusterServiceWithCache(cache, cl, logf.Log, test.HostOperatorNs, 3*time.Second, nil)
+		require.NoError(t, service.AddOrUpdateToolchainCluster(toolchainCluster))
+		cache.clusters["east"].Client = cl
+		cache.clusters["east"].RestConfig.BearerToken = "old-token"
+		events := &[]ClusterEvent{}
+		cache.Subscribe(func(event ClusterEvent) {
+			*events = append(*events, event)
+		})
+
+		// when
+		err := service.AddOrUpdateToolchainCluster(toolchainCluster)
+
+		// then
+		require.NoError(t, err)
+		cached, ok := cache.GetCachedToolchainCluster("east")
+		require.True(t, ok)
+		assert.NotEqual(t, cl, cached.Client)
+		require.Len(t, *events, 1)
+		assert.Equal(t, ClusterUpdated, (*events)[0].Type)
+	})
+
+	t.Run("no probe when the cluster is added", func(t *testing.T) {
+		// given
+		cl := test.NewFakeClient(t, sec)
+		cache := NewClusterCache()
+		service := NewToolchainClusterServiceWithCache(cache, cl, logf.Log, test.HostOperatorNs, 3*time.Second, nil, WithClientProbe(func(_ context.Context, _ *rest.Config) error {
+			return fmt.Errorf("should not be called")
+		}))
+
+		// when
+		err := service.AddOrUpdateToolchainCluster(toolchainCluster)
+
+		// then
+		require.NoError(t, err)
+	})
+}
+
+func TestTokenExpiry(t *testing.T) {
+	// given
+	expiresAt := time.Now().Add(30 * time.Minute).Truncate(time.Second)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}).SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	t.Run("expiry of a JWT", func(t *testing.T) {
+		// when
+		expiry := tokenExpiry(&rest.Config{BearerToken: token})
+
+		// then
+		require.NotNil(t, expiry)
+		assert.True(t, expiresAt.Equal(*expiry))
+	})
+
+	t.Run("no expiry of an opaque token", func(t *testing.T) {
+		assert.Nil(t, tokenExpiry(&rest.Config{BearerToken: "mycooltoken"}))
+		assert.Nil(t, tokenExpiry(&rest.Config{}))
+	})
+
+	t.Run("token expires within", func(t *testing.T) {
+		// given
+		cluster := &CachedToolchainCluster{Config: &Config{TokenExpiry: &expiresAt}}
+
+		// then
+		assert.True(t, TokenExpiresWithin(time.Hour)(cluster))
+		assert.False(t, TokenExpiresWithin(10*time.Minute)(cluster))
+		assert.False(t, TokenExpiresWithin(time.Hour)(&CachedToolchainCluster{Config: &Config{}}))
+	})
+}
+
+func TestHealthzProbe(t *testing.T) {
+	// given
+	defer gock.Off()
+	gock.New("https://healthy.com").
+		Get("healthz").
+		Persist().
+		Reply(200).
+		BodyString("ok")
+	gock.New("https://broken.com").
+		Get("healthz").
+		Persist().
+		Reply(200).
+		BodyString("unhealthy")
+
+	// when & then
+	require.NoError(t, HealthzProbe(context.TODO(), &rest.Config{Host: "https://healthy.com"}))
+	require.EqualError(t, HealthzProbe(context.TODO(), &rest.Config{Host: "https://broken.com"}), "/healthz responded without ok")
+}
+
+func TestServicesWithDifferentCaches(t *testing.T) {
+	// given
+	defer gock.Off()
+	status := test.NewClusterStatus(toolchainv1alpha1.ConditionReady, corev1.ConditionTrue)
+	east, eastSecret := test.NewToolchainCluster(t, "east", test.HostOperatorNs, test.MemberOperatorNs, "east-secret", status, false)
+	west, westSecret := test.NewToolchainCluster(t, "west", test.MemberOperatorNs, test.HostOperatorNs, "west-secret", status, false)
+	eastCache := NewClusterCache()
+	westCache := NewClusterCache()
+	eastService := NewToolchainClusterServiceWithCache(eastCache, test.NewFakeClient(t, east, eastSecret), logf.Log, test.HostOperatorNs, 0, nil)
+	westService := NewToolchainClusterServiceWithCache(westCache, test.NewFakeClient(t, west, westSecret), logf.Log, test.MemberOperatorNs, 0, nil)
+
+	// when
+	eastClusters := eastService.Cache().GetMemberClusters()
+	westClusters := westService.Cache().GetMemberClusters()
+
+	// then
+	require.Len(t, eastClusters, 1)
+	assert.Equal(t, "east", eastClusters[0].Name)
+	require.Len(t, westClusters, 1)
+	assert.Equal(t, "west", westClusters[0].Name)
+	_, ok := clusterCache.clusters["east"]
+	assert.False(t, ok)
+	_, ok = clusterCache.clusters["west"]
+	assert.False(t, ok)
+}
+
+func TestCacheMetrics(t *testing.T) {
+	// given
+	defer gock.Off()
+	resetClusterCache()
+	defer resetClusterCache()
+	status := test.NewClusterStatus(toolchainv1alpha1.ConditionReady, corev1.ConditionTrue)
+	metered, sec := test.NewToolchainCluster(t, "metered", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, false)
+	service := newToolchainClusterService(test.NewFakeClient(t, metered, sec), 0, test.HostOperatorNs)
+	refreshes := metricstest.GetCounterInt(CacheRefreshCounter)
+
+	// when
+	_, ok := GetCachedToolchainCluster("metered")
+
+	// then
+	require.True(t, ok)
+	metricstest.AssertMetricsGaugeEquals(t, 1, CacheSizeGauge)
+	metricstest.AssertMetricsCounterEquals(t, refreshes+1, CacheRefreshCounter)
+	metricstest.AssertHistogramSampleCountEquals(t, uint64(refreshes+1), CacheRefreshDurationHistogram)
+	metricstest.AssertMetricsCounterEquals(t, 0, ClientRebuildCounterVec.WithLabelValues("metered"))
+
+	t.Run("client is not rebuilt when the config didn't change", func(t *testing.T) {
+		// when
+		err := service.AddOrUpdateToolchainCluster(metered)
+
+		// then
+		require.NoError(t, err)
+		metricstest.AssertMetricsCounterEquals(t, 0, ClientRebuildCounterVec.WithLabelValues("metered"))
+		metricstest.AssertMetricsGaugeEquals(t, 1, CacheSizeGauge)
+	})
+
+	t.Run("client is rebuilt when the config changed", func(t *testing.T) {
+		// given
+		clusterCache.clusters["metered"].RestConfig.BearerToken = "old-token"
+
+		// when
+		err := service.AddOrUpdateToolchainCluster(metered)
+
+		// then
+		require.NoError(t, err)
+		metricstest.AssertMetricsCounterEquals(t, 1, ClientRebuildCounterVec.WithLabelValues("metered"))
+	})
+
+	t.Run("the separate caches don't change the size", func(t *testing.T) {
+		// given
+		other := NewToolchainClusterServiceWithCache(NewClusterCache(), test.NewFakeClient(t, sec), logf.Log, test.HostOperatorNs, 0, nil)
+
+		// when
+		err := other.AddOrUpdateToolchainCluster(metered)
+
+		// then
+		require.NoError(t, err)
+		metricstest.AssertMetricsGaugeEquals(t, 1, CacheSizeGauge)
+		metricstest.AssertMetricsCounterEquals(t, 1, ClientRebuildCounterVec.WithLabelValues("metered"))
+	})
+
+	t.Run("series are removed when the cluster is deleted", func(t *testing.T) {
+		// when
+		service.DeleteToolchainCluster("metered")
+
+		// then
+		metricstest.AssertMetricsGaugeEquals(t, 0, CacheSizeGauge)
+		metricstest.AssertMetricsCounterEquals(t, 0, ClientRebuildCounterVec.WithLabelValues("metered"))
+	})
+}
+
 func newToolchainClusterService(cl client.Client, timeout time.Duration, tcNs string) ToolchainClusterService {
 	return NewToolchainClusterServiceWithClient(cl, logf.Log, tcNs, timeout, func(config *rest.Config, options client.Options) (client.Client, error) {
 		// make sure that insecure is false to make Gock mocking working properly
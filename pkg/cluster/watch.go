@@ -0,0 +1,221 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/apis"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crcluster "sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// ClusterRequest is a reconcile.Request annotated with the name of the member cluster the event originated in
+type ClusterRequest struct {
+	reconcile.Request
+	ClusterName string
+}
+
+// ClusterObjectMapFunc maps the given object from the given member cluster to the requests that should be reconciled
+type ClusterObjectMapFunc[request comparable] func(ctx context.Context, clusterName string, obj client.Object) []request
+
+// IgnoringClusterName adapts the given handler.MapFunc (eg. controllers.MapToOwnerByLabel) to ClusterObjectMapFunc,
+// so it can be used by the controllers reconciling plain reconcile.Requests
+func IgnoringClusterName(mapFn handler.MapFunc) ClusterObjectMapFunc[reconcile.Request] {
+	return func(ctx context.Context, _ string, obj client.Object) []reconcile.Request {
+		return mapFn(ctx, obj)
+	}
+}
+
+// NewMemberClusterSource returns a source.TypedSource that watches the objects of the same type as the given obj
+// in all the Ready clusters from the given cache that match the given conditions (eg. HasRole(Member)), and enqueues
+// the requests returned by the given mapFn. The watches are started and stopped dynamically as the clusters join
+// and leave the cache (or become ready and not ready), and they are restarted when the client of a cluster is rebuilt.
+//
+// The informer cache of the CachedToolchainCluster.Cluster is used if the clusters are backed by an informer cache
+// (see WithInformerCache), otherwise a dedicated informer cache is started for every watched cluster.
+func NewMemberClusterSource[request comparable](cache *ClusterCache, obj client.Object, mapFn ClusterObjectMapFunc[request], conditions ...Condition) source.TypedSource[request] {
+	return &memberClusterSource[request]{
+		cache:      cache,
+		obj:        obj,
+		mapFn:      mapFn,
+		conditions: append([]Condition{Ready}, conditions...),
+		watches:    map[string]*memberWatch{},
+	}
+}
+
+type memberClusterSource[request comparable] struct {
+	cache      *ClusterCache
+	obj        client.Object
+	mapFn      ClusterObjectMapFunc[request]
+	conditions []Condition
+	newCache   cache.NewCacheFunc
+
+	watchesLock sync.Mutex
+	watches     map[string]*memberWatch
+}
+
+// memberWatch is the watch of one cluster. The client and the cluster are used for detecting the rebuilt clients.
+type memberWatch struct {
+	client  client.Client
+	cluster crcluster.Cluster
+	stop    func()
+}
+
+// Start starts watching the matching clusters and subscribes to the cache to keep the watches in sync with the clusters.
+// The watches are started and stopped by a separate goroutine, because starting a watch may need to talk to the member
+// cluster (eg. to discover its REST mapping) and the cache handlers must not block. All the watches are stopped when
+// the given context is done.
+func (s *memberClusterSource[request]) Start(ctx context.Context, queue workqueue.TypedRateLimitingInterface[request]) error {
+	changed := make(chan struct{}, 1)
+	unsubscribe := s.cache.Subscribe(func(ClusterEvent) {
+		// the pending signal is enough, the sync always reads the current clusters from the cache
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+	go func() {
+		defer func() {
+			unsubscribe()
+			s.watchesLock.Lock()
+			defer s.watchesLock.Unlock()
+			for name, w := range s.watches {
+				w.stop()
+				delete(s.watches, name)
+			}
+		}()
+		s.sync(ctx, queue)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+				s.sync(ctx, queue)
+			}
+		}
+	}()
+	return nil
+}
+
+// sync starts the watches of the matching clusters which are not watched yet and stops the watches of the clusters
+// that don't match anymore (or whose client was rebuilt)
+func (s *memberClusterSource[request]) sync(ctx context.Context, queue workqueue.TypedRateLimitingInterface[request]) {
+	if ctx.Err() != nil {
+		return
+	}
+	members := map[string]*CachedToolchainCluster{}
+	for _, member := range s.cache.getCachedToolchainClusters(s.conditions...) {
+		members[member.Name] = member
+	}
+
+	s.watchesLock.Lock()
+	defer s.watchesLock.Unlock()
+	for name, w := range s.watches {
+		if member, found := members[name]; !found || member.Client != w.client || member.Cluster != w.cluster {
+			w.stop()
+			delete(s.watches, name)
+		}
+	}
+	for name, member := range members {
+		if _, found := s.watches[name]; found {
+			continue
+		}
+		w, err := s.watch(ctx, member, queue)
+		if err != nil {
+			// the watch is retried with the next change of the clusters in the cache
+			log.FromContext(ctx).Error(err, "unable to watch the cluster", "cluster", name, "kind", s.String())
+			continue
+		}
+		s.watches[name] = w
+	}
+}
+
+func (s *memberClusterSource[request]) watch(ctx context.Context, member *CachedToolchainCluster, queue workqueue.TypedRateLimitingInterface[request]) (*memberWatch, error) {
+	watchCtx, cancel := context.WithCancel(ctx)
+	informers, err := s.informerCache(watchCtx, member)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	informer, err := informers.GetInformer(watchCtx, s.obj, cache.BlockUntilSynced(false))
+	if err != nil {
+		cancel()
+		return nil, errors.Wrapf(err, "cannot create informer for %T", s.obj)
+	}
+	registration, err := informer.AddEventHandler(s.eventHandler(watchCtx, member.Name, queue))
+	if err != nil {
+		cancel()
+		return nil, errors.Wrapf(err, "cannot add event handler to the informer for %T", s.obj)
+	}
+	return &memberWatch{
+		client:  member.Client,
+		cluster: member.Cluster,
+		stop: func() {
+			_ = informer.RemoveEventHandler(registration)
+			cancel()
+		},
+	}, nil
+}
+
+// informerCache returns the informer cache of the given cluster, or starts a dedicated one if the cluster is not backed by an informer cache
+func (s *memberClusterSource[request]) informerCache(ctx context.Context, member *CachedToolchainCluster) (cache.Cache, error) {
+	if member.Cluster != nil {
+		return member.Cluster.GetCache(), nil
+	}
+	scheme := runtime.NewScheme()
+	if err := apis.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	newCache := s.newCache
+	if newCache == nil {
+		newCache = cache.New
+	}
+	informers, err := newCache(member.RestConfig, cache.Options{Scheme: scheme})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot create informer cache")
+	}
+	go func() {
+		if err := informers.Start(ctx); err != nil {
+			log.FromContext(ctx).Error(err, "the informer cache of the watched cluster failed", "cluster", member.Name)
+		}
+	}()
+	return informers, nil
+}
+
+func (s *memberClusterSource[request]) eventHandler(ctx context.Context, clusterName string, queue workqueue.TypedRateLimitingInterface[request]) toolscache.ResourceEventHandler {
+	enqueue := func(obj interface{}) {
+		if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		o, ok := obj.(client.Object)
+		// the informer may still deliver the events after the watch was stopped
+		if !ok || ctx.Err() != nil {
+			return
+		}
+		for _, req := range s.mapFn(ctx, clusterName, o) {
+			queue.Add(req)
+		}
+	}
+	return toolscache.ResourceEventHandlerFuncs{
+		AddFunc: enqueue,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			enqueue(oldObj)
+			enqueue(newObj)
+		},
+		DeleteFunc: enqueue,
+	}
+}
+
+func (s *memberClusterSource[request]) String() string {
+	return fmt.Sprintf("member cluster source: %T", s.obj)
+}
@@ -0,0 +1,195 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	crcluster "sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllertest"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestMemberClusterSource(t *testing.T) {
+	// given
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	member := withLabel(RoleLabel(Member), "")
+	member1Cluster := newStubCluster(t)
+	member2Cluster := newStubCluster(t)
+	clusters := NewClusterCache()
+	clusters.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member1", ready, member, withInformerCluster(member1Cluster)))
+	clusters.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member2", notReady, member, withInformerCluster(member2Cluster)))
+	clusters.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "host", ready, withLabel(RoleLabel(Host), ""), withInformerCluster(newStubCluster(t))))
+	src := NewMemberClusterSource(clusters, &corev1.ConfigMap{}, mapToClusterRequest, HasRole(Member))
+	queue := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[ClusterRequest]())
+	defer queue.ShutDown()
+	// watched returns a condition checking whether the cluster is watched using the given cluster (if any)
+	watched := func(name string, cluster crcluster.Cluster) func() bool {
+		return func() bool {
+			s := src.(*memberClusterSource[ClusterRequest])
+			s.watchesLock.Lock()
+			defer s.watchesLock.Unlock()
+			w, found := s.watches[name]
+			return found && (cluster == nil || w.cluster == cluster)
+		}
+	}
+
+	// when
+	err := src.Start(ctx, queue)
+
+	// then
+	require.NoError(t, err)
+	require.Eventually(t, watched("member1", member1Cluster), time.Second, 10*time.Millisecond)
+	fakeInformer(t, member1Cluster.informers).Add(configMap("cm-1"))
+	assert.Equal(t, []ClusterRequest{clusterRequest("member1", "cm-1")}, drain(queue))
+	assert.Empty(t, member2Cluster.informers.InformersByGVK)
+
+	t.Run("cluster that became ready is watched", func(t *testing.T) {
+		// when
+		clusters.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member2", ready, member, withInformerCluster(member2Cluster)))
+
+		// then
+		require.Eventually(t, watched("member2", member2Cluster), time.Second, 10*time.Millisecond)
+		fakeInformer(t, member2Cluster.informers).Update(configMap("cm-1"), configMap("cm-2"))
+		assert.ElementsMatch(t, []ClusterRequest{clusterRequest("member2", "cm-1"), clusterRequest("member2", "cm-2")}, drain(queue))
+	})
+
+	t.Run("watch is restarted when the cluster is rebuilt", func(t *testing.T) {
+		// given
+		rebuilt := newStubCluster(t)
+
+		// when
+		clusters.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member1", ready, member, withInformerCluster(rebuilt)))
+
+		// then
+		require.Eventually(t, watched("member1", rebuilt), time.Second, 10*time.Millisecond)
+		fakeInformer(t, member1Cluster.informers).Add(configMap("cm-old"))
+		fakeInformer(t, rebuilt.informers).Delete(configMap("cm-new"))
+		assert.Equal(t, []ClusterRequest{clusterRequest("member1", "cm-new")}, drain(queue))
+	})
+
+	t.Run("removed cluster is not watched", func(t *testing.T) {
+		// when
+		clusters.deleteCachedToolchainCluster("member2")
+
+		// then
+		require.Eventually(t, func() bool { return !watched("member2", nil)() }, time.Second, 10*time.Millisecond)
+		fakeInformer(t, member2Cluster.informers).Add(configMap("cm-3"))
+		assert.Empty(t, drain(queue))
+	})
+
+	t.Run("dedicated informer cache is used for the clusters without informer cache", func(t *testing.T) {
+		// given
+		dedicated := &informertest.FakeInformers{}
+		src.(*memberClusterSource[ClusterRequest]).newCache = func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+			assert.Equal(t, "https://member3.com", config.Host)
+			return dedicated, nil
+		}
+
+		// when
+		clusters.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member3", ready, member, func(c *CachedToolchainCluster) {
+			c.RestConfig = &rest.Config{Host: "https://member3.com"}
+		}))
+
+		// then
+		require.Eventually(t, watched("member3", nil), time.Second, 10*time.Millisecond)
+		fakeInformer(t, dedicated).Add(configMap("cm-4"))
+		assert.Equal(t, []ClusterRequest{clusterRequest("member3", "cm-4")}, drain(queue))
+	})
+
+	t.Run("the cache is not blocked by starting the watches", func(t *testing.T) {
+		// given
+		started := make(chan struct{})
+		release := make(chan struct{})
+		src.(*memberClusterSource[ClusterRequest]).newCache = func(_ *rest.Config, _ cache.Options) (cache.Cache, error) {
+			close(started)
+			<-release // emulates the unreachable member cluster
+			return &informertest.FakeInformers{}, nil
+		}
+
+		// when
+		clusters.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member4", ready, member, func(c *CachedToolchainCluster) {
+			c.RestConfig = &rest.Config{Host: "https://member4.com"}
+		}))
+
+		// then
+		<-started
+		clusters.addCachedToolchainCluster(newTestCachedToolchainCluster(t, "member5", notReady, member))
+		close(release)
+		require.Eventually(t, watched("member4", nil), time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("nothing is watched when the context is done", func(t *testing.T) {
+		// when
+		cancel()
+
+		// then
+		require.Eventually(t, func() bool {
+			fakeInformer(t, member1Cluster.informers).Add(configMap("cm-5"))
+			return queue.Len() == 0
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestIgnoringClusterName(t *testing.T) {
+	// given
+	mapFn := IgnoringClusterName(func(_ context.Context, obj client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}}}
+	})
+
+	// when
+	requests := mapFn(context.TODO(), "member1", configMap("cm-1"))
+
+	// then
+	assert.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: test.MemberOperatorNs, Name: "cm-1"}}}, requests)
+}
+
+func mapToClusterRequest(_ context.Context, clusterName string, obj client.Object) []ClusterRequest {
+	return []ClusterRequest{clusterRequest(clusterName, obj.GetName())}
+}
+
+func clusterRequest(clusterName, name string) ClusterRequest {
+	return ClusterRequest{
+		Request:     reconcile.Request{NamespacedName: types.NamespacedName{Namespace: test.MemberOperatorNs, Name: name}},
+		ClusterName: clusterName,
+	}
+}
+
+func configMap(name string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: test.MemberOperatorNs}}
+}
+
+func fakeInformer(t *testing.T, informers *informertest.FakeInformers) *controllertest.FakeInformer {
+	informer, err := informers.FakeInformerFor(context.TODO(), &corev1.ConfigMap{})
+	require.NoError(t, err)
+	return informer
+}
+
+func drain(queue workqueue.TypedRateLimitingInterface[ClusterRequest]) []ClusterRequest {
+	var requests []ClusterRequest
+	for queue.Len() > 0 {
+		req, _ := queue.Get()
+		queue.Done(req)
+		requests = append(requests, req)
+	}
+	return requests
+}
+
+// withInformerCluster an option to back the cluster by the given Cluster
+func withInformerCluster(cluster *stubCluster) clusterOption {
+	return func(c *CachedToolchainCluster) {
+		c.Cluster = cluster
+	}
+}
@@ -0,0 +1,172 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// AllowInsecureEndpointAnnotationKey is the annotation of the ToolchainCluster that allows (when set to "true")
+// a non-HTTPS API endpoint or skipping the verification of the TLS certificate of the API endpoint
+const AllowInsecureEndpointAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "allow-insecure-endpoint"
+
+// ToolchainClusterValidator validates the ToolchainClusters and the Secrets they reference on create and update,
+// so the misconfigured clusters are rejected right away instead of failing when they are reconciled
+type ToolchainClusterValidator struct {
+	Client client.Client
+}
+
+var _ admission.CustomValidator = &ToolchainClusterValidator{}
+
+// SetupWebhookWithManager registers the validating webhook for the ToolchainClusters in the given manager
+func (v *ToolchainClusterValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&toolchainv1alpha1.ToolchainCluster{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate validates the created ToolchainCluster
+func (v *ToolchainClusterValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	toolchainCluster, err := toToolchainCluster(obj)
+	if err != nil {
+		return nil, err
+	}
+	return nil, v.validate(ctx, nil, toolchainCluster)
+}
+
+// ValidateUpdate validates the updated ToolchainCluster
+func (v *ToolchainClusterValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldCluster, err := toToolchainCluster(oldObj)
+	if err != nil {
+		return nil, err
+	}
+	newCluster, err := toToolchainCluster(newObj)
+	if err != nil {
+		return nil, err
+	}
+	// the cluster being deleted must not be blocked (eg. when its finalizers are removed)
+	if newCluster.DeletionTimestamp != nil {
+		return nil, nil
+	}
+	return nil, v.validate(ctx, oldCluster, newCluster)
+}
+
+// ValidateDelete allows the deletion of any ToolchainCluster
+func (v *ToolchainClusterValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func toToolchainCluster(obj runtime.Object) (*toolchainv1alpha1.ToolchainCluster, error) {
+	toolchainCluster, ok := obj.(*toolchainv1alpha1.ToolchainCluster)
+	if !ok {
+		return nil, fmt.Errorf("expected a ToolchainCluster but got %T", obj)
+	}
+	return toolchainCluster, nil
+}
+
+// validate checks the given ToolchainCluster (the oldCluster is nil on create)
+func (v *ToolchainClusterValidator) validate(ctx context.Context, oldCluster, toolchainCluster *toolchainv1alpha1.ToolchainCluster) error {
+	var errs field.ErrorList
+	errs = append(errs, validateOwnerClusterName(oldCluster, toolchainCluster)...)
+	if oldCluster == nil || configChanged(oldCluster, toolchainCluster) {
+		errs = append(errs, v.validateConfig(ctx, toolchainCluster)...)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(toolchainv1alpha1.GroupVersion.WithKind("ToolchainCluster").GroupKind(), toolchainCluster.Name, errs)
+}
+
+// configChanged checks if any of the fields the config of the cluster is created from changed, so it has to be validated again
+func configChanged(oldCluster, toolchainCluster *toolchainv1alpha1.ToolchainCluster) bool {
+	if !equality.Semantic.DeepEqual(oldCluster.Spec, toolchainCluster.Spec) {
+		return true
+	}
+	for _, key := range []string{CredentialSourceAnnotationKey, AllowInsecureEndpointAnnotationKey} {
+		if oldCluster.Annotations[key] != toolchainCluster.Annotations[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// validateConfig checks the config created from the referenced Secret the same way as when the cluster is added to the cache
+func (v *ToolchainClusterValidator) validateConfig(ctx context.Context, toolchainCluster *toolchainv1alpha1.ToolchainCluster) field.ErrorList {
+	secretRefPath := field.NewPath("spec", "secretRef", "name")
+	secret, err := getSecret(ctx, v.Client, toolchainCluster)
+	if err != nil {
+		return field.ErrorList{field.Invalid(secretRefPath, toolchainCluster.Spec.SecretRef.Name, err.Error())}
+	}
+	clusterConfig, err := loadConfig(toolchainCluster, secret, 0)
+	if err != nil {
+		return field.ErrorList{field.Invalid(secretRefPath, toolchainCluster.Spec.SecretRef.Name, err.Error())}
+	}
+
+	var errs field.ErrorList
+	if credentialSource(toolchainCluster) == KubeConfigCredentialSource {
+		if err := validateKubeConfigNamespace(secret); err != nil {
+			errs = append(errs, field.Invalid(secretRefPath, toolchainCluster.Spec.SecretRef.Name, err.Error()))
+		}
+	} else if clusterConfig.OperatorNamespace == "" {
+		errs = append(errs, field.Invalid(secretRefPath, toolchainCluster.Spec.SecretRef.Name, "the operator namespace is not set"))
+	}
+
+	if toolchainCluster.Annotations[AllowInsecureEndpointAnnotationKey] != "true" {
+		annotationPath := field.NewPath("metadata", "annotations").Key(AllowInsecureEndpointAnnotationKey)
+		endpoint, _, err := rest.DefaultServerUrlFor(clusterConfig.RestConfig)
+		switch {
+		case err != nil:
+			errs = append(errs, field.Invalid(secretRefPath, toolchainCluster.Spec.SecretRef.Name, fmt.Sprintf("invalid API endpoint: %s", err)))
+		case endpoint.Scheme != "https":
+			errs = append(errs, field.Forbidden(annotationPath, fmt.Sprintf("the API endpoint '%s' doesn't use HTTPS", clusterConfig.APIEndpoint)))
+		case clusterConfig.RestConfig.Insecure:
+			errs = append(errs, field.Forbidden(annotationPath, fmt.Sprintf("the TLS certificate of the API endpoint '%s' is not verified", clusterConfig.APIEndpoint)))
+		}
+	}
+	return errs
+}
+
+// validateKubeConfigNamespace checks that the current context of the kubeconfig in the given Secret explicitly sets the namespace,
+// otherwise the "default" namespace would be used as the operator namespace
+func validateKubeConfigNamespace(secret *corev1.Secret) error {
+	cfg, err := clientcmd.Load(secret.Data[kubeConfigKey])
+	if err != nil {
+		return err
+	}
+	if kubeContext, found := cfg.Contexts[cfg.CurrentContext]; !found || kubeContext.Namespace == "" {
+		return fmt.Errorf("the current context '%s' of the kubeconfig doesn't set the operator namespace", cfg.CurrentContext)
+	}
+	return nil
+}
+
+// validateOwnerClusterName checks that the ownerClusterName label (if set) is a valid cluster name and that it doesn't change
+func validateOwnerClusterName(oldCluster, toolchainCluster *toolchainv1alpha1.ToolchainCluster) field.ErrorList {
+	labelPath := field.NewPath("metadata", "labels").Key(labelOwnerClusterName)
+	ownerClusterName, found := toolchainCluster.Labels[labelOwnerClusterName]
+	var errs field.ErrorList
+	if found {
+		if msgs := validation.IsDNS1123Subdomain(ownerClusterName); len(msgs) > 0 {
+			errs = append(errs, field.Invalid(labelPath, ownerClusterName, strings.Join(msgs, ", ")))
+		}
+	}
+	if oldCluster != nil {
+		if oldOwner, oldFound := oldCluster.Labels[labelOwnerClusterName]; oldFound && (oldOwner != ownerClusterName || !found) {
+			errs = append(errs, field.Forbidden(labelPath, fmt.Sprintf("the owner cluster name cannot be changed from '%s'", oldOwner)))
+		}
+	}
+	return errs
+}
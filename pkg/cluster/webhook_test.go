@@ -0,0 +1,291 @@
+package cluster_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestToolchainClusterValidator(t *testing.T) {
+	defer gock.Off()
+	status := test.NewClusterStatus(toolchainv1alpha1.ConditionReady, corev1.ConditionTrue)
+
+	t.Run("create", func(t *testing.T) {
+		t.Run("valid cluster", func(t *testing.T) {
+			// given
+			tc, sec := test.NewToolchainCluster(t, "east", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, false)
+			validator := &cluster.ToolchainClusterValidator{Client: test.NewFakeClient(t, sec)}
+
+			// when
+			warnings, err := validator.ValidateCreate(context.TODO(), tc)
+
+			// then
+			require.NoError(t, err)
+			assert.Empty(t, warnings)
+		})
+
+		t.Run("missing secret", func(t *testing.T) {
+			// given
+			tc, _ := test.NewToolchainCluster(t, "east", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, false)
+			validator := &cluster.ToolchainClusterValidator{Client: test.NewFakeClient(t)}
+
+			// when
+			_, err := validator.ValidateCreate(context.TODO(), tc)
+
+			// then
+			assertInvalid(t, err, "spec.secretRef.name: Invalid value: \"secret\": unable to get secret")
+		})
+
+		t.Run("invalid kubeconfig", func(t *testing.T) {
+			// given
+			tc, sec := test.NewToolchainCluster(t, "east", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, false)
+			sec.Data["kubeconfig"] = []byte("not a kubeconfig")
+			validator := &cluster.ToolchainClusterValidator{Client: test.NewFakeClient(t, sec)}
+
+			// when
+			_, err := validator.ValidateCreate(context.TODO(), tc)
+
+			// then
+			assertInvalid(t, err, "spec.secretRef.name: Invalid value: \"secret\"")
+		})
+
+		t.Run("kubeconfig without namespace", func(t *testing.T) {
+			// given
+			tc, sec := test.NewToolchainCluster(t, "east", test.HostOperatorNs, "", "secret", status, false)
+			validator := &cluster.ToolchainClusterValidator{Client: test.NewFakeClient(t, sec)}
+
+			// when
+			_, err := validator.ValidateCreate(context.TODO(), tc)
+
+			// then
+			assertInvalid(t, err, "doesn't set the operator namespace")
+		})
+
+		t.Run("insecure endpoint", func(t *testing.T) {
+			for name, tcAndSecret := range map[string]func() (*toolchainv1alpha1.ToolchainCluster, *corev1.Secret){
+				"http": func() (*toolchainv1alpha1.ToolchainCluster, *corev1.Secret) {
+					return test.NewToolchainClusterWithEndpoint(t, "east", test.HostOperatorNs, test.MemberOperatorNs, "secret", "http://cluster.com", status, false)
+				},
+				"skipped TLS verification": func() (*toolchainv1alpha1.ToolchainCluster, *corev1.Secret) {
+					return test.NewToolchainCluster(t, "east", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, true)
+				},
+			} {
+				t.Run(name, func(t *testing.T) {
+					t.Run("rejected by default", func(t *testing.T) {
+						// given
+						tc, sec := tcAndSecret()
+						validator := &cluster.ToolchainClusterValidator{Client: test.NewFakeClient(t, sec)}
+
+						// when
+						_, err := validator.ValidateCreate(context.TODO(), tc)
+
+						// then
+						assertInvalid(t, err, "metadata.annotations[toolchain.dev.openshift.com/allow-insecure-endpoint]: Forbidden")
+					})
+
+					t.Run("allowed by the annotation", func(t *testing.T) {
+						// given
+						tc, sec := tcAndSecret()
+						tc.Annotations = map[string]string{cluster.AllowInsecureEndpointAnnotationKey: "true"}
+						validator := &cluster.ToolchainClusterValidator{Client: test.NewFakeClient(t, sec)}
+
+						// when
+						_, err := validator.ValidateCreate(context.TODO(), tc)
+
+						// then
+						require.NoError(t, err)
+					})
+				})
+			}
+		})
+
+		t.Run("invalid owner cluster name", func(t *testing.T) {
+			// given
+			tc, sec := test.NewToolchainCluster(t, "east", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, false)
+			tc.Labels = map[string]string{"ownerClusterName": "Not_Valid"}
+			validator := &cluster.ToolchainClusterValidator{Client: test.NewFakeClient(t, sec)}
+
+			// when
+			_, err := validator.ValidateCreate(context.TODO(), tc)
+
+			// then
+			assertInvalid(t, err, "metadata.labels[ownerClusterName]: Invalid value: \"Not_Valid\"")
+		})
+
+		t.Run("not a ToolchainCluster", func(t *testing.T) {
+			// given
+			validator := &cluster.ToolchainClusterValidator{Client: test.NewFakeClient(t)}
+
+			// when
+			_, err := validator.ValidateCreate(context.TODO(), &corev1.Secret{})
+
+			// then
+			require.EqualError(t, err, "expected a ToolchainCluster but got *v1.Secret")
+		})
+	})
+
+	t.Run("update", func(t *testing.T) {
+		newClusters := func(t *testing.T, oldOwner, newOwner *string) (runtime.Object, runtime.Object, *cluster.ToolchainClusterValidator) {
+			tc, sec := test.NewToolchainCluster(t, "east", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, false)
+			oldCluster := tc.DeepCopy()
+			oldCluster.Labels = map[string]string{}
+			if oldOwner != nil {
+				oldCluster.Labels["ownerClusterName"] = *oldOwner
+			}
+			newCluster := tc.DeepCopy()
+			newCluster.Labels = map[string]string{}
+			if newOwner != nil {
+				newCluster.Labels["ownerClusterName"] = *newOwner
+			}
+			return oldCluster, newCluster, &cluster.ToolchainClusterValidator{Client: test.NewFakeClient(t, sec)}
+		}
+		owner := func(name string) *string {
+			return &name
+		}
+
+		t.Run("unchanged owner cluster name", func(t *testing.T) {
+			// given
+			oldCluster, newCluster, validator := newClusters(t, owner("member-1"), owner("member-1"))
+
+			// when
+			_, err := validator.ValidateUpdate(context.TODO(), oldCluster, newCluster)
+
+			// then
+			require.NoError(t, err)
+		})
+
+		t.Run("owner cluster name set for the first time", func(t *testing.T) {
+			// given
+			oldCluster, newCluster, validator := newClusters(t, nil, owner("member-1"))
+
+			// when
+			_, err := validator.ValidateUpdate(context.TODO(), oldCluster, newCluster)
+
+			// then
+			require.NoError(t, err)
+		})
+
+		t.Run("changed owner cluster name", func(t *testing.T) {
+			// given
+			oldCluster, newCluster, validator := newClusters(t, owner("member-1"), owner("member-2"))
+
+			// when
+			_, err := validator.ValidateUpdate(context.TODO(), oldCluster, newCluster)
+
+			// then
+			assertInvalid(t, err, "the owner cluster name cannot be changed from 'member-1'")
+		})
+
+		t.Run("removed owner cluster name", func(t *testing.T) {
+			// given
+			oldCluster, newCluster, validator := newClusters(t, owner("member-1"), nil)
+
+			// when
+			_, err := validator.ValidateUpdate(context.TODO(), oldCluster, newCluster)
+
+			// then
+			assertInvalid(t, err, "the owner cluster name cannot be changed from 'member-1'")
+		})
+
+		t.Run("cluster being deleted", func(t *testing.T) {
+			// given
+			oldCluster, newCluster, validator := newClusters(t, owner("member-1"), nil)
+			newCluster.(*toolchainv1alpha1.ToolchainCluster).DeletionTimestamp = &metav1.Time{Time: time.Now()}
+
+			// when
+			_, err := validator.ValidateUpdate(context.TODO(), oldCluster, newCluster)
+
+			// then
+			require.NoError(t, err)
+		})
+
+		t.Run("config", func(t *testing.T) {
+			t.Run("not validated when neither the spec nor the annotations changed", func(t *testing.T) {
+				// given
+				oldCluster, newCluster, _ := newClusters(t, nil, nil)
+				newCluster.(*toolchainv1alpha1.ToolchainCluster).Labels["other"] = "label"
+				validator := &cluster.ToolchainClusterValidator{Client: test.NewFakeClient(t)} // the secret is missing
+
+				// when
+				_, err := validator.ValidateUpdate(context.TODO(), oldCluster, newCluster)
+
+				// then
+				require.NoError(t, err)
+			})
+
+			t.Run("validated when the spec changed", func(t *testing.T) {
+				// given
+				oldCluster, newCluster, validator := newClusters(t, nil, nil)
+				newCluster.(*toolchainv1alpha1.ToolchainCluster).Spec.SecretRef.Name = "missing"
+
+				// when
+				_, err := validator.ValidateUpdate(context.TODO(), oldCluster, newCluster)
+
+				// then
+				assertInvalid(t, err, "spec.secretRef.name: Invalid value: \"missing\": unable to get secret")
+			})
+
+			t.Run("validated when the credential source changed", func(t *testing.T) {
+				// given
+				oldCluster, newCluster, validator := newClusters(t, nil, nil)
+				newCluster.(*toolchainv1alpha1.ToolchainCluster).Annotations = map[string]string{cluster.CredentialSourceAnnotationKey: cluster.TokenCredentialSource}
+
+				// when
+				_, err := validator.ValidateUpdate(context.TODO(), oldCluster, newCluster)
+
+				// then
+				assertInvalid(t, err, "is missing the required key(s) for the 'token' credential source")
+			})
+		})
+	})
+
+	t.Run("secret is read only once", func(t *testing.T) {
+		// given
+		tc, sec := test.NewToolchainCluster(t, "east", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, false)
+		cl := test.NewFakeClient(t, sec)
+		reads := 0
+		cl.MockGet = func(ctx context.Context, key runtimeclient.ObjectKey, obj runtimeclient.Object, opts ...runtimeclient.GetOption) error {
+			reads++
+			return cl.Client.Get(ctx, key, obj, opts...)
+		}
+		validator := &cluster.ToolchainClusterValidator{Client: cl}
+
+		// when
+		_, err := validator.ValidateCreate(context.TODO(), tc)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, 1, reads)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		// given
+		tc, _ := test.NewToolchainCluster(t, "east", test.HostOperatorNs, test.MemberOperatorNs, "secret", status, false)
+		validator := &cluster.ToolchainClusterValidator{Client: test.NewFakeClient(t)}
+
+		// when
+		_, err := validator.ValidateDelete(context.TODO(), tc)
+
+		// then
+		require.NoError(t, err)
+	})
+}
+
+func assertInvalid(t *testing.T, err error, expectedMsg string) {
+	t.Helper()
+	require.Error(t, err)
+	assert.True(t, apierrors.IsInvalid(err), "expected an Invalid error but got: %v", err)
+	assert.Contains(t, err.Error(), expectedMsg)
+}
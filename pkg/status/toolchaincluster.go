@@ -19,6 +19,8 @@ const (
 
 // ToolchainClusterAttributes required attributes for obtaining ToolchainCluster status
 type ToolchainClusterAttributes struct {
+	// GetClusterFunc returns the cluster to check the connection of, eg. cluster.GetHostCluster for the default cache
+	// or the GetHostCluster method of a specific *cluster.ClusterCache instance
 	GetClusterFunc func() (*cluster.CachedToolchainCluster, bool)
 	Period         time.Duration
 	Timeout        time.Duration
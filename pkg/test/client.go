@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"slices"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/stretchr/testify/require"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -263,6 +265,12 @@ func Patch(ctx context.Context, fakeClient *FakeClient, obj client.Object, patch
 	// if it doesn't exist.
 	if patch == client.Apply {
 		if !found {
+			patchOptions := &client.PatchOptions{}
+			patchOptions.ApplyOptions(opts)
+			if slices.Contains(patchOptions.DryRun, metav1.DryRunAll) {
+				// the object would be created, but not in the dry-run
+				return nil
+			}
 			if err := Create(ctx, fakeClient, obj); err != nil {
 				return err
 			}